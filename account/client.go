@@ -2,45 +2,74 @@ package account
 
 import (
 	"context"
+	"fmt"
 	"github.com/fabian-emmanuel/go-ms/account/pb"
+	"github.com/fabian-emmanuel/go-ms/auth"
+	"github.com/fabian-emmanuel/go-ms/errs"
+	"github.com/fabian-emmanuel/go-ms/healthcheck"
+	"github.com/fabian-emmanuel/go-ms/logging"
+	"github.com/fabian-emmanuel/go-ms/metrics"
+	"github.com/fabian-emmanuel/go-ms/mtls"
+	"github.com/fabian-emmanuel/go-ms/tracing"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
-	"log"
+	"log/slog"
+	"sync"
+	"time"
 )
 
 type Client struct {
 	conn    *grpc.ClientConn
 	service pb.AccountServiceClient
+	// stopReload stops watching the TLS files once the client is closed
+	stopReload context.CancelFunc
 }
 
-func NewClient(url string) (*Client, error) {
+func NewClient(url string, creds mtls.Config) (*Client, error) {
+	reloadCtx, stopReload := context.WithCancel(context.Background())
+	transport, err := creds.DialOption(reloadCtx)
+	if err != nil {
+		stopReload()
+		return nil, err
+	}
 
-	conn, err := grpc.NewClient(url, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.NewClient(url,
+		transport,
+		tracing.DialOption(),
+		grpc.WithChainUnaryInterceptor(
+			// Outermost, so the other interceptors still see gRPC status errors
+			errs.UnaryClientInterceptor(),
+			metrics.UnaryClientInterceptor(),
+			logging.UnaryClientInterceptor(),
+			auth.UnaryClientInterceptor(),
+		),
+	)
 	if err != nil {
-		log.Fatal(err)
+		stopReload()
 		return nil, err
 	}
 	client := pb.NewAccountServiceClient(conn)
-	return &Client{conn, client}, nil
+	return &Client{conn, client, stopReload}, nil
+}
+
+// Health fails unless the account service reports itself SERVING.
+func (c *Client) Health(ctx context.Context) error {
+	return healthcheck.Check(ctx, c.conn, pb.AccountService_ServiceDesc.ServiceName)
 }
 
 func (c *Client) Close() {
+	c.stopReload()
 	err := c.conn.Close()
 	if err != nil {
-		log.Fatal(err)
-		return
+		slog.Error("Error closing account client", "err", err)
 	}
 }
 
-func (c *Client) PostAccount(ctx context.Context, name string) (*Account, error) {
-	r, err := c.service.PostAccount(ctx, &pb.PostAccountRequest{Name: name})
+func (c *Client) PostAccount(ctx context.Context, name, email, password string) (*Account, error) {
+	r, err := c.service.PostAccount(ctx, &pb.PostAccountRequest{Name: name, Email: email, Password: password})
 	if err != nil {
 		return nil, err
 	}
-	return &Account{
-		ID:   r.Account.Id,
-		Name: r.Account.Name,
-	}, nil
+	return accountFromProto(r.Account), nil
 }
 
 func (c *Client) GetAccount(ctx context.Context, id string) (*Account, error) {
@@ -50,10 +79,7 @@ func (c *Client) GetAccount(ctx context.Context, id string) (*Account, error) {
 		return nil, err
 	}
 
-	return &Account{
-		ID:   r.Account.Id,
-		Name: r.Account.Name,
-	}, nil
+	return accountFromProto(r.Account), nil
 }
 
 func (c *Client) GetAccounts(ctx context.Context, skip, take uint64) ([]*Account, error) {
@@ -63,10 +89,167 @@ func (c *Client) GetAccounts(ctx context.Context, skip, take uint64) ([]*Account
 	}
 	var accounts []*Account
 	for _, a := range r.Accounts {
-		accounts = append(accounts, &Account{
-			ID:   a.Id,
-			Name: a.Name,
-		})
+		accounts = append(accounts, accountFromProto(a))
 	}
 	return accounts, nil
 }
+
+func (c *Client) ListAccounts(ctx context.Context, query ListAccountsQuery) (*AccountPage, error) {
+	r, err := c.service.ListAccounts(ctx, &pb.ListAccountsRequest{First: uint32(query.First), After: query.After})
+	if err != nil {
+		return nil, err
+	}
+
+	page := &AccountPage{
+		Accounts:    []*Account{},
+		Cursors:     r.Cursors,
+		HasNextPage: r.HasNextPage,
+		TotalCount:  int(r.TotalCount),
+	}
+	for _, a := range r.Accounts {
+		page.Accounts = append(page.Accounts, accountFromProto(a))
+	}
+	return page, nil
+}
+
+func (c *Client) UpdateAccount(ctx context.Context, id, name string) (*Account, error) {
+	r, err := c.service.UpdateAccount(ctx, &pb.UpdateAccountRequest{Id: id, Name: name})
+	if err != nil {
+		return nil, err
+	}
+	return accountFromProto(r.Account), nil
+}
+
+func (c *Client) DeactivateAccount(ctx context.Context, id string) (*Account, error) {
+	r, err := c.service.DeactivateAccount(ctx, &pb.DeactivateAccountRequest{Id: id})
+	if err != nil {
+		return nil, err
+	}
+	return accountFromProto(r.Account), nil
+}
+
+func (c *Client) DeleteAccount(ctx context.Context, id string) error {
+	_, err := c.service.DeleteAccount(ctx, &pb.DeleteAccountRequest{Id: id})
+	return err
+}
+
+func (c *Client) Login(ctx context.Context, email, password string) (*TokenPair, error) {
+	r, err := c.service.Login(ctx, &pb.LoginRequest{Email: email, Password: password})
+	if err != nil {
+		return nil, err
+	}
+	return tokenPairFromProto(r.Tokens), nil
+}
+
+func (c *Client) RefreshToken(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	r, err := c.service.RefreshToken(ctx, &pb.RefreshTokenRequest{RefreshToken: refreshToken})
+	if err != nil {
+		return nil, err
+	}
+	return tokenPairFromProto(r.Tokens), nil
+}
+
+// tokenRefreshMargin is how long before its access token expires a
+// TokenSource logs in again.
+const tokenRefreshMargin = 30 * time.Second
+
+// TokenSource logs in as a service account and hands out its access token,
+// logging in again shortly before the token expires.
+type TokenSource struct {
+	client   *Client
+	email    string
+	password string
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+func (c *Client) TokenSource(email, password string) *TokenSource {
+	return &TokenSource{client: c, email: email, password: password}
+}
+
+// Token returns an access token of the service account. The lock is not held
+// while logging in, so callers racing an expiry may each log in once.
+func (s *TokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	token, expiresAt := s.token, s.expiresAt
+	s.mu.Unlock()
+	if token != "" && time.Until(expiresAt) > tokenRefreshMargin {
+		return token, nil
+	}
+
+	pair, err := s.client.Login(ctx, s.email, s.password)
+	if err != nil {
+		return "", fmt.Errorf("failed to log in as service account %s: %w", s.email, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = pair.AccessToken
+	s.expiresAt = time.Now().Add(pair.ExpiresIn)
+	return s.token, nil
+}
+
+func (c *Client) GrantRole(ctx context.Context, accountId string, role auth.Role, reason string) (*Account, error) {
+	r, err := c.service.GrantRole(ctx, &pb.GrantRoleRequest{AccountId: accountId, Role: string(role), Reason: reason})
+	if err != nil {
+		return nil, err
+	}
+	return accountFromProto(r.Account), nil
+}
+
+func (c *Client) RevokeRole(ctx context.Context, accountId string, role auth.Role, reason string) (*Account, error) {
+	r, err := c.service.RevokeRole(ctx, &pb.RevokeRoleRequest{AccountId: accountId, Role: string(role), Reason: reason})
+	if err != nil {
+		return nil, err
+	}
+	return accountFromProto(r.Account), nil
+}
+
+func (c *Client) GetRoleChanges(ctx context.Context, accountId string, skip, take uint64) ([]*RoleChange, error) {
+	r, err := c.service.GetRoleChanges(ctx, &pb.GetRoleChangesRequest{AccountId: accountId, Skip: skip, Take: take})
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []*RoleChange
+	for _, rc := range r.RoleChanges {
+		change := &RoleChange{
+			ID:        rc.Id,
+			AccountId: rc.AccountId,
+			Role:      auth.Role(rc.Role),
+			Action:    RoleAction(rc.Action),
+			ActorId:   rc.ActorId,
+			Reason:    rc.Reason,
+		}
+		if err := change.ChangedAt.UnmarshalBinary(rc.ChangedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+func accountFromProto(a *pb.Account) *Account {
+	roles := make([]auth.Role, 0, len(a.Roles))
+	for _, role := range a.Roles {
+		roles = append(roles, auth.Role(role))
+	}
+
+	return &Account{
+		ID:     a.Id,
+		Name:   a.Name,
+		Email:  a.Email,
+		Status: AccountStatus(a.Status),
+		Roles:  roles,
+	}
+}
+
+func tokenPairFromProto(t *pb.TokenPair) *TokenPair {
+	return &TokenPair{
+		AccessToken:  t.AccessToken,
+		RefreshToken: t.RefreshToken,
+		ExpiresIn:    time.Duration(t.ExpiresIn) * time.Second,
+	}
+}
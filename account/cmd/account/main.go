@@ -1,36 +1,132 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"github.com/fabian-emmanuel/go-ms/account"
+	"github.com/fabian-emmanuel/go-ms/auth"
+	"github.com/fabian-emmanuel/go-ms/events"
+	"github.com/fabian-emmanuel/go-ms/idempotency"
+	"github.com/fabian-emmanuel/go-ms/lifecycle"
+	"github.com/fabian-emmanuel/go-ms/logging"
+	"github.com/fabian-emmanuel/go-ms/metrics"
+	"github.com/fabian-emmanuel/go-ms/mtls"
+	"github.com/fabian-emmanuel/go-ms/tracing"
 	"github.com/kelseyhightower/envconfig"
 	"github.com/tinrab/retry"
-	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
 	"time"
 )
 
 type Config struct {
-	DatabaseUrl        string `envconfig:"DATABASE_URL"`
-	AccountServicePort int    `envconfig:"ACCOUNT_SERVICE_PORT"`
+	DatabaseUrl        string        `envconfig:"DATABASE_URL"`
+	AccountServicePort int           `envconfig:"ACCOUNT_SERVICE_PORT"`
+	JwtPrivateKeyFile  string        `envconfig:"JWT_PRIVATE_KEY_FILE"`
+	RequestHashKeyFile string        `envconfig:"REQUEST_HASH_KEY_FILE"`
+	JwksPort           int           `envconfig:"JWKS_PORT"`
+	MetricsPort        int           `envconfig:"METRICS_PORT"`
+	ShutdownTimeout    time.Duration `envconfig:"SHUTDOWN_TIMEOUT"`
+	events.Config
+	TLS     mtls.Config
+	Tracing tracing.Config
+	Log     logging.Config
 }
 
 func main() {
+	if err := run(); err != nil {
+		slog.Error("Exiting", "err", err)
+		os.Exit(1)
+	}
+}
+
+// run serves until SIGINT or SIGTERM, then stops taking requests, lets
+// in-flight ones finish, and tears down the background workers, the event
+// publisher and the database in that order.
+func run() error {
 	var config Config
 	err := envconfig.Process("", &config)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+
+	logging.Setup("account", config.Log)
+
+	// Deferred first so spans from the rest of shutdown are still flushed
+	shutdownTracing, err := tracing.Setup(context.Background(), "account", config.Tracing)
+	if err != nil {
+		return err
 	}
+	defer shutdownTracing()
+
+	ctx, stop := lifecycle.SignalContext()
+	defer stop()
 
 	var repo account.Repository
 	retry.ForeverSleep(2*time.Second, func(_ int) (err error) {
 		repo, err = account.NewPostgresRepository(config.DatabaseUrl)
 		if err != nil {
-			log.Println(err)
+			slog.Error("Error connecting to database", "err", err)
 		}
 		return
 	})
-
 	defer repo.Close()
-	log.Printf("Listening on port :%v...\n", config.AccountServicePort)
-	s := account.NewAccountService(repo)
-	log.Fatal(account.ListenGRPC(s, config.AccountServicePort))
+
+	publisher, err := events.NewPublisher(config.Config)
+	if err != nil {
+		return err
+	}
+	defer publisher.Close()
+
+	// Workers outlive ctx so events written by draining requests still get relayed
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	defer workers.Wait()
+	defer stopWorkers()
+	workers.Add(2)
+	go func() {
+		defer workers.Done()
+		events.NewRelay(repo, publisher).Run(workerCtx)
+	}()
+	go func() {
+		defer workers.Done()
+		idempotency.RunJanitor(workerCtx, repo, time.Hour)
+	}()
+
+	signer, err := auth.LoadSigner(config.JwtPrivateKeyFile)
+	if err != nil {
+		return err
+	}
+
+	hashKey, err := idempotency.LoadHashKey(config.RequestHashKeyFile)
+	if err != nil {
+		return err
+	}
+
+	if config.JwksPort != 0 {
+		mux := http.NewServeMux()
+		mux.Handle(auth.JWKSPath, auth.JWKSHandler(signer))
+		jwks := &http.Server{Addr: fmt.Sprintf(":%d", config.JwksPort), Handler: mux}
+		go func() {
+			slog.Info("Serving JWKS", "port", config.JwksPort)
+			if err := lifecycle.ServeHTTP(ctx, jwks, config.ShutdownTimeout); err != nil {
+				slog.ErrorContext(ctx, "Error serving JWKS", "err", err)
+			}
+		}()
+	}
+
+	if config.MetricsPort != 0 {
+		go func() {
+			slog.Info("Serving metrics", "port", config.MetricsPort)
+			if err := metrics.Serve(ctx, config.MetricsPort, config.ShutdownTimeout); err != nil {
+				slog.ErrorContext(ctx, "Error serving metrics", "err", err)
+			}
+		}()
+	}
+
+	slog.Info("Listening", "port", config.AccountServicePort)
+	s := account.NewAccountService(repo, signer)
+	return account.ListenGRPC(ctx, s, repo, signer, hashKey, config.TLS, config.AccountServicePort, config.ShutdownTimeout)
 }
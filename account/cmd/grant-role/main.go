@@ -0,0 +1,58 @@
+// Command grant-role grants a role to an existing account straight in the
+// database. It bootstraps a deployment that has no admin yet to grant roles,
+// and the service account the order service reserves stock as. The grant is
+// recorded in the role audit trail like any other. It reads DATABASE_URL like
+// the account service.
+//
+//	grant-role -account <account ID> [-role admin|service|...] [-reason <reason>]
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/fabian-emmanuel/go-ms/account"
+	"github.com/fabian-emmanuel/go-ms/auth"
+	"github.com/kelseyhightower/envconfig"
+	"log"
+	"time"
+)
+
+type Config struct {
+	DatabaseUrl string `envconfig:"DATABASE_URL"`
+}
+
+func main() {
+	accountId := flag.String("account", "", "ID of the account to grant the role to")
+	roleName := flag.String("role", string(auth.RoleAdmin), "role to grant")
+	reason := flag.String("reason", "bootstrap", "reason recorded in the role audit trail")
+	flag.Parse()
+	if *accountId == "" {
+		flag.Usage()
+		log.Fatal("-account is required")
+	}
+	role, err := auth.ParseRole(*roleName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var config Config
+	if err := envconfig.Process("", &config); err != nil {
+		log.Fatal(err)
+	}
+
+	repo, err := account.NewPostgresRepository(config.DatabaseUrl)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer repo.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// No signer is needed, as granting a role issues no tokens
+	a, err := account.NewAccountService(repo, nil).GrantRole(ctx, *accountId, role, *reason)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Granted %s to %s (%s)", role, a.ID, a.Email)
+}
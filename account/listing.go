@@ -0,0 +1,51 @@
+package account
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/fabian-emmanuel/go-ms/errs"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+var ErrInvalidCursor = errs.InvalidArgument("invalid cursor")
+
+type ListAccountsQuery struct {
+	First int
+	After string
+}
+
+// AccountPage is one page of accounts, newest first. Cursors holds the cursor
+// of each account, in the same order.
+type AccountPage struct {
+	Accounts    []*Account
+	Cursors     []string
+	HasNextPage bool
+	TotalCount  int
+}
+
+// accountCursor marks an account by its ID. IDs are KSUIDs, so ordering by ID
+// orders by creation time.
+type accountCursor struct {
+	ID string `json:"i"`
+}
+
+func newAccountCursor(a *Account) string {
+	body, _ := json.Marshal(accountCursor{ID: a.ID})
+	return base64.RawURLEncoding.EncodeToString(body)
+}
+
+func parseAccountCursor(s string) (*accountCursor, error) {
+	body, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c accountCursor
+	if err := json.Unmarshal(body, &c); err != nil || c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
@@ -0,0 +1,16 @@
+-- Stores responses by idempotency key so client retries replay instead of repeating side effects.
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    method VARCHAR(128) NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL,
+    request_hash BYTEA NOT NULL,
+    response BYTEA,
+    locked_until TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (method, idempotency_key)
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
+
+COMMIT;
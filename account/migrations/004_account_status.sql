@@ -0,0 +1,6 @@
+-- Lets accounts be deactivated without deleting them.
+BEGIN;
+
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'active';
+
+COMMIT;
@@ -0,0 +1,21 @@
+-- Adds login credentials and server-side refresh token records. Existing
+-- accounts keep NULL credentials and cannot log in until they are set.
+BEGIN;
+
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS email VARCHAR(254) UNIQUE;
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS password_hash TEXT;
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id CHAR(27) PRIMARY KEY,
+    account_id CHAR(30) NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    family_id CHAR(27) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+CREATE INDEX IF NOT EXISTS refresh_tokens_account_id_idx ON refresh_tokens (account_id);
+
+COMMIT;
@@ -0,0 +1,28 @@
+-- Adds role assignments and an append-only audit trail of role changes.
+-- Existing accounts become customers.
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS account_roles (
+    account_id CHAR(30) NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    role VARCHAR(16) NOT NULL,
+    granted_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (account_id, role)
+);
+
+CREATE TABLE IF NOT EXISTS role_changes (
+    id CHAR(27) PRIMARY KEY,
+    account_id VARCHAR(30) NOT NULL,
+    role VARCHAR(16) NOT NULL,
+    action VARCHAR(16) NOT NULL,
+    actor_id VARCHAR(30) NOT NULL DEFAULT '',
+    reason TEXT NOT NULL DEFAULT '',
+    changed_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS role_changes_account_id_idx ON role_changes (account_id, changed_at);
+
+INSERT INTO account_roles (account_id, role, granted_at)
+SELECT id, 'customer', now() FROM accounts
+ON CONFLICT DO NOTHING;
+
+COMMIT;
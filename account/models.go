@@ -1,6 +1,70 @@
 package account
 
+import (
+	"github.com/fabian-emmanuel/go-ms/auth"
+	"slices"
+	"time"
+)
+
+type AccountStatus string
+
+const (
+	StatusActive      AccountStatus = "active"
+	StatusDeactivated AccountStatus = "deactivated"
+)
+
 type Account struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID           string        `json:"id"`
+	Name         string        `json:"name"`
+	Email        string        `json:"email"`
+	PasswordHash string        `json:"-"`
+	Status       AccountStatus `json:"status"`
+	Roles        []auth.Role   `json:"roles"`
+}
+
+type RoleAction string
+
+const (
+	RoleGranted RoleAction = "granted"
+	RoleRevoked RoleAction = "revoked"
+)
+
+// RoleChange is one entry in the audit trail of role grants and revocations.
+// ActorId is empty for changes the service made itself, such as bootstrapping
+// the first admin.
+type RoleChange struct {
+	ID        string     `json:"id"`
+	AccountId string     `json:"account_id"`
+	Role      auth.Role  `json:"role"`
+	Action    RoleAction `json:"action"`
+	ActorId   string     `json:"actor_id"`
+	Reason    string     `json:"reason"`
+	ChangedAt time.Time  `json:"changed_at"`
+}
+
+// RefreshToken is the server-side record of an issued refresh token. Each
+// refresh consumes the token and issues a successor in the same family.
+type RefreshToken struct {
+	ID        string
+	AccountId string
+	FamilyId  string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// TokenPair is what a successful login or refresh returns.
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration
+}
+
+// IsActive reports whether the account may place orders. Accounts from
+// servers that predate statuses carry no status and count as active.
+func (a *Account) IsActive() bool {
+	return a.Status != StatusDeactivated
+}
+
+func (a *Account) HasRole(role auth.Role) bool {
+	return slices.Contains(a.Roles, role)
 }
@@ -3,24 +3,62 @@ package account
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	_ "github.com/lib/pq"
-	"log"
+	"github.com/fabian-emmanuel/go-ms/auth"
+	"github.com/fabian-emmanuel/go-ms/errs"
+	"github.com/fabian-emmanuel/go-ms/events"
+	"github.com/fabian-emmanuel/go-ms/idempotency"
+	"github.com/fabian-emmanuel/go-ms/metrics"
+	"github.com/fabian-emmanuel/go-ms/tracing"
+	"github.com/lib/pq"
+	"github.com/segmentio/ksuid"
+	"log/slog"
+	"slices"
+	"time"
 )
 
 type Repository interface {
 	Close()
+	Ping(ctx context.Context) error
 	PutAccount(ctx context.Context, a Account) error
 	GetAccountById(ctx context.Context, id string) (*Account, error)
+	GetAccountByEmail(ctx context.Context, email string) (*Account, error)
 	ListAccounts(ctx context.Context, skip, take uint64) ([]*Account, error)
+	PageAccounts(ctx context.Context, query ListAccountsQuery) (*AccountPage, error)
+	UpdateAccount(ctx context.Context, a Account) error
+	SetAccountStatus(ctx context.Context, id string, status AccountStatus) (*Account, error)
+	DeleteAccount(ctx context.Context, id string) error
+	CreateRefreshToken(ctx context.Context, t RefreshToken) error
+	ConsumeRefreshToken(ctx context.Context, id string) (*RefreshToken, error)
+	ChangeRole(ctx context.Context, change RoleChange) (*Account, error)
+	ListRoleChanges(ctx context.Context, accountId string, skip, take uint64) ([]*RoleChange, error)
+	events.Outbox
+	idempotency.Store
 }
 
+var (
+	ErrAccountNotFound = errs.NotFound("account not found")
+	ErrEmailTaken      = errs.AlreadyExists("an account with this email already exists")
+	// ErrRefreshTokenReused means a refresh token was presented after it had
+	// already been exchanged, i.e. it was probably stolen.
+	ErrRefreshTokenReused  = errs.Unauthenticated("refresh token was already used")
+	ErrRefreshTokenInvalid = errs.Unauthenticated("refresh token is invalid or revoked")
+)
+
+// uniqueViolation is the Postgres error code for a unique constraint failure.
+const uniqueViolation = "23505"
+
+// rolesColumn selects an account's roles alongside its row.
+const rolesColumn = "ARRAY(SELECT r.role FROM account_roles r WHERE r.account_id = accounts.id ORDER BY r.role)"
+
 type postgresRepository struct {
 	db *sql.DB
+	idempotency.Store
 }
 
 func NewPostgresRepository(url string) (Repository, error) {
-	db, err := sql.Open("postgres", url)
+	db, err := tracing.OpenPostgres(url)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database::{%s}::%w", url, err)
 	}
@@ -29,43 +67,94 @@ func NewPostgresRepository(url string) (Repository, error) {
 	if err := db.Ping(); err != nil {
 		return nil, fmt.Errorf("failed to connect to database::{%s}::%w", url, err)
 	}
+	if err := metrics.RegisterDB(db, "account"); err != nil {
+		return nil, err
+	}
 
-	return &postgresRepository{db: db}, nil
+	return &postgresRepository{db: db, Store: idempotency.NewPostgresStore(db)}, nil
 }
 
 func (r *postgresRepository) Close() {
 	if r.db == nil {
-		log.Println("Warning: Attempted to close a nil database connection")
+		slog.Warn("Attempted to close a nil database connection")
 		return
 	}
 
 	if err := r.db.Close(); err != nil {
-		log.Printf("Error closing database connection: %v", err)
+		slog.Error("Error closing database connection", "err", err)
 	}
 }
 
-func (r *postgresRepository) Ping() error {
-	return r.db.Ping()
+func (r *postgresRepository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
 }
 
 func (r *postgresRepository) PutAccount(ctx context.Context, a Account) error {
-	_, err := r.db.ExecContext(ctx, "INSERT INTO accounts(id, name) VALUES($1, $2)", a.ID, a.Name)
-	return err
+	return r.withEvent(ctx, events.AccountCreated, a.ID, a, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO accounts(id, name, email, password_hash, status) VALUES($1, $2, $3, $4, $5)",
+			a.ID, a.Name, a.Email, a.PasswordHash, a.Status)
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return ErrEmailTaken
+		}
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		for _, role := range a.Roles {
+			err := applyRoleChange(ctx, tx, RoleChange{
+				ID:        ksuid.New().String(),
+				AccountId: a.ID,
+				Role:      role,
+				Action:    RoleGranted,
+				Reason:    "assigned at sign-up",
+				ChangedAt: now,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
 func (r *postgresRepository) GetAccountById(ctx context.Context, id string) (*Account, error) {
-	row := r.db.QueryRowContext(ctx, "SELECT id, name FROM accounts WHERE id = $1", id)
+	row := r.db.QueryRowContext(ctx, "SELECT id, name, COALESCE(email, ''), status, "+rolesColumn+" FROM accounts WHERE id = $1", id)
 	a := &Account{}
+	var roles pq.StringArray
 
-	if err := row.Scan(&a.ID, &a.Name); err != nil {
+	if err := row.Scan(&a.ID, &a.Name, &a.Email, &a.Status, &roles); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrAccountNotFound.WithResource("account", id)
+		}
 		return nil, err
 	}
+	a.Roles = toRoles(roles)
+
+	return a, nil
+}
+
+// GetAccountByEmail is the only lookup that returns the password hash.
+func (r *postgresRepository) GetAccountByEmail(ctx context.Context, email string) (*Account, error) {
+	row := r.db.QueryRowContext(ctx, "SELECT id, name, email, COALESCE(password_hash, ''), status, "+rolesColumn+" FROM accounts WHERE email = $1", email)
+	a := &Account{}
+	var roles pq.StringArray
+
+	if err := row.Scan(&a.ID, &a.Name, &a.Email, &a.PasswordHash, &a.Status, &roles); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrAccountNotFound
+		}
+		return nil, err
+	}
+	a.Roles = toRoles(roles)
 
 	return a, nil
 }
 
 func (r *postgresRepository) ListAccounts(ctx context.Context, skip, take uint64) ([]*Account, error) {
-	rows, err := r.db.QueryContext(ctx, "SELECT id, name FROM accounts ORDER BY id DESC OFFSET $1 LIMIT $2", skip, take)
+	rows, err := r.db.QueryContext(ctx, "SELECT id, name, COALESCE(email, ''), status, "+rolesColumn+" FROM accounts ORDER BY id DESC OFFSET $1 LIMIT $2", skip, take)
 
 	if err != nil {
 		return nil, err
@@ -73,7 +162,7 @@ func (r *postgresRepository) ListAccounts(ctx context.Context, skip, take uint64
 	defer func(rows *sql.Rows) {
 		err := rows.Close()
 		if err != nil {
-			log.Fatal(err)
+			slog.Warn("failed to close rows", "err", err)
 		}
 	}(rows)
 
@@ -81,7 +170,9 @@ func (r *postgresRepository) ListAccounts(ctx context.Context, skip, take uint64
 
 	for rows.Next() {
 		a := &Account{}
-		if err := rows.Scan(&a.ID, &a.Name); err == nil {
+		var roles pq.StringArray
+		if err := rows.Scan(&a.ID, &a.Name, &a.Email, &a.Status, &roles); err == nil {
+			a.Roles = toRoles(roles)
 			accounts = append(accounts, a)
 		}
 	}
@@ -92,3 +183,302 @@ func (r *postgresRepository) ListAccounts(ctx context.Context, skip, take uint64
 
 	return accounts, nil
 }
+
+// PageAccounts pages through accounts newest first with keyset pagination on
+// id. query must already be validated; First is the page size.
+func (r *postgresRepository) PageAccounts(ctx context.Context, query ListAccountsQuery) (*AccountPage, error) {
+	page := &AccountPage{Accounts: []*Account{}, Cursors: []string{}}
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM accounts").Scan(&page.TotalCount); err != nil {
+		return nil, fmt.Errorf("failed to count accounts: %w", err)
+	}
+
+	args := []interface{}{query.First + 1}
+	where := ""
+	if query.After != "" {
+		cursor, err := parseAccountCursor(query.After)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, cursor.ID)
+		where = "WHERE id < $2"
+	}
+
+	// Fetch one extra row to learn whether another page follows
+	rows, err := r.db.QueryContext(
+		ctx,
+		"SELECT id, name, COALESCE(email, ''), status, "+rolesColumn+" FROM accounts "+where+" ORDER BY id DESC LIMIT $1",
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query accounts: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Warn("failed to close rows", "err", err)
+		}
+	}()
+
+	for rows.Next() {
+		a := &Account{}
+		var roles pq.StringArray
+		if err := rows.Scan(&a.ID, &a.Name, &a.Email, &a.Status, &roles); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		a.Roles = toRoles(roles)
+		page.Accounts = append(page.Accounts, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	if len(page.Accounts) > query.First {
+		page.HasNextPage = true
+		page.Accounts = page.Accounts[:query.First]
+	}
+	for _, a := range page.Accounts {
+		page.Cursors = append(page.Cursors, newAccountCursor(a))
+	}
+
+	return page, nil
+}
+
+func (r *postgresRepository) UpdateAccount(ctx context.Context, a Account) error {
+	return r.withEvent(ctx, events.AccountUpdated, a.ID, a, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, "UPDATE accounts SET name = $2 WHERE id = $1", a.ID, a.Name)
+		if err != nil {
+			return err
+		}
+		return requireRow(res)
+	})
+}
+
+// SetAccountStatus moves an account to status and returns it. Setting the status
+// it already has changes nothing and records no event.
+func (r *postgresRepository) SetAccountStatus(ctx context.Context, id string, status AccountStatus) (*Account, error) {
+	a, err := r.GetAccountById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if a.Status == status {
+		return a, nil
+	}
+	a.Status = status
+
+	eventType := events.AccountUpdated
+	if status == StatusDeactivated {
+		eventType = events.AccountDeactivated
+	}
+
+	err = r.withEvent(ctx, eventType, a.ID, a, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, "UPDATE accounts SET status = $2 WHERE id = $1", id, status)
+		if err != nil {
+			return err
+		}
+		if err := requireRow(res); err != nil {
+			return err
+		}
+		if status == StatusDeactivated {
+			_, err = tx.ExecContext(ctx,
+				"UPDATE refresh_tokens SET revoked_at = $2 WHERE account_id = $1 AND revoked_at IS NULL",
+				id, time.Now().UTC())
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (r *postgresRepository) DeleteAccount(ctx context.Context, id string) error {
+	return r.withEvent(ctx, events.AccountDeleted, id, Account{ID: id}, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, "DELETE FROM accounts WHERE id = $1", id)
+		if err != nil {
+			return err
+		}
+		return requireRow(res)
+	})
+}
+
+func (r *postgresRepository) CreateRefreshToken(ctx context.Context, t RefreshToken) error {
+	_, err := r.db.ExecContext(ctx,
+		"INSERT INTO refresh_tokens(id, account_id, family_id, created_at, expires_at) VALUES($1, $2, $3, $4, $5)",
+		t.ID, t.AccountId, t.FamilyId, t.CreatedAt, t.ExpiresAt)
+	return err
+}
+
+// ConsumeRefreshToken marks a refresh token used and returns it. Presenting a
+// token that was already used revokes every token in its family, so both the
+// thief and the legitimate holder have to log in again.
+func (r *postgresRepository) ConsumeRefreshToken(ctx context.Context, id string) (*RefreshToken, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	t := &RefreshToken{ID: id}
+	var usedAt, revokedAt sql.NullTime
+	err = tx.QueryRowContext(ctx,
+		"SELECT account_id, family_id, created_at, expires_at, used_at, revoked_at FROM refresh_tokens WHERE id = $1 FOR UPDATE",
+		id,
+	).Scan(&t.AccountId, &t.FamilyId, &t.CreatedAt, &t.ExpiresAt, &usedAt, &revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if revokedAt.Valid {
+		return nil, ErrRefreshTokenInvalid
+	}
+	if usedAt.Valid {
+		_, err := tx.ExecContext(ctx,
+			"UPDATE refresh_tokens SET revoked_at = $2 WHERE family_id = $1 AND revoked_at IS NULL",
+			t.FamilyId, now)
+		if err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+	if t.ExpiresAt.Before(now) {
+		return nil, ErrRefreshTokenInvalid
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE refresh_tokens SET used_at = $2 WHERE id = $1", id, now); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// ChangeRole grants or revokes change.Role and records change in the audit
+// trail, returning the updated account. A grant of a role the account already
+// holds, or a revocation of one it lacks, changes nothing and records nothing.
+func (r *postgresRepository) ChangeRole(ctx context.Context, change RoleChange) (*Account, error) {
+	a, err := r.GetAccountById(ctx, change.AccountId)
+	if err != nil {
+		return nil, err
+	}
+	if a.HasRole(change.Role) == (change.Action == RoleGranted) {
+		return a, nil
+	}
+
+	err = r.withEvent(ctx, events.AccountRoleChanged, a.ID, change, func(tx *sql.Tx) error {
+		return applyRoleChange(ctx, tx, change)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if change.Action == RoleGranted {
+		a.Roles = append(a.Roles, change.Role)
+	} else {
+		a.Roles = slices.DeleteFunc(a.Roles, func(role auth.Role) bool { return role == change.Role })
+	}
+	return a, nil
+}
+
+// ListRoleChanges returns the audit trail newest first, for one account or,
+// with an empty accountId, for all of them.
+func (r *postgresRepository) ListRoleChanges(ctx context.Context, accountId string, skip, take uint64) ([]*RoleChange, error) {
+	query := "SELECT id, account_id, role, action, actor_id, reason, changed_at FROM role_changes"
+	args := []any{skip, take}
+	if accountId != "" {
+		query += " WHERE account_id = $3"
+		args = append(args, accountId)
+	}
+	query += " ORDER BY changed_at DESC, id DESC OFFSET $1 LIMIT $2"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []*RoleChange
+	for rows.Next() {
+		c := &RoleChange{}
+		if err := rows.Scan(&c.ID, &c.AccountId, &c.Role, &c.Action, &c.ActorId, &c.Reason, &c.ChangedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// applyRoleChange updates the account's roles and appends c to the audit trail.
+func applyRoleChange(ctx context.Context, tx *sql.Tx, c RoleChange) error {
+	var err error
+	if c.Action == RoleGranted {
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO account_roles(account_id, role, granted_at) VALUES($1, $2, $3) ON CONFLICT DO NOTHING",
+			c.AccountId, c.Role, c.ChangedAt)
+	} else {
+		_, err = tx.ExecContext(ctx,
+			"DELETE FROM account_roles WHERE account_id = $1 AND role = $2",
+			c.AccountId, c.Role)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO role_changes(id, account_id, role, action, actor_id, reason, changed_at) VALUES($1, $2, $3, $4, $5, $6, $7)",
+		c.ID, c.AccountId, c.Role, c.Action, c.ActorId, c.Reason, c.ChangedAt)
+	return err
+}
+
+func toRoles(roles pq.StringArray) []auth.Role {
+	res := make([]auth.Role, 0, len(roles))
+	for _, role := range roles {
+		res = append(res, auth.Role(role))
+	}
+	return res
+}
+
+// withEvent runs write and records an event for it in one transaction.
+func (r *postgresRepository) withEvent(ctx context.Context, eventType events.Type, aggregateId string, payload any, write func(tx *sql.Tx) error) error {
+	event, err := events.New(eventType, aggregateId, payload)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := write(tx); err != nil {
+		return err
+	}
+
+	if err := events.WriteOutbox(ctx, tx, event); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func requireRow(res sql.Result) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrAccountNotFound
+	}
+	return nil
+}
+
+func (r *postgresRepository) DispatchPendingEvents(ctx context.Context, limit int, publish func(ctx context.Context, e events.Event) error) (int, error) {
+	return events.DispatchPostgresOutbox(ctx, r.db, limit, publish)
+}
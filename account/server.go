@@ -6,9 +6,21 @@ import (
 	"context"
 	"fmt"
 	"github.com/fabian-emmanuel/go-ms/account/pb"
+	"github.com/fabian-emmanuel/go-ms/auth"
+	"github.com/fabian-emmanuel/go-ms/errs"
+	"github.com/fabian-emmanuel/go-ms/healthcheck"
+	"github.com/fabian-emmanuel/go-ms/idempotency"
+	"github.com/fabian-emmanuel/go-ms/lifecycle"
+	"github.com/fabian-emmanuel/go-ms/logging"
+	"github.com/fabian-emmanuel/go-ms/metrics"
+	"github.com/fabian-emmanuel/go-ms/mtls"
+	"github.com/fabian-emmanuel/go-ms/tracing"
+	"github.com/fabian-emmanuel/go-ms/validation"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
+	"log/slog"
 	"net"
+	"time"
 )
 
 type grpcServer struct {
@@ -16,27 +28,63 @@ type grpcServer struct {
 	pb.UnimplementedAccountServiceServer
 }
 
-func ListenGRPC(s Service, port int) error {
+// permissions are what callers need for the RPCs that are not open to everyone.
+var permissions = map[string]auth.Permission{
+	pb.AccountService_GetAccounts_FullMethodName:    auth.PermListAccounts,
+	pb.AccountService_ListAccounts_FullMethodName:   auth.PermListAccounts,
+	pb.AccountService_GrantRole_FullMethodName:      auth.PermManageRoles,
+	pb.AccountService_RevokeRole_FullMethodName:     auth.PermManageRoles,
+	pb.AccountService_GetRoleChanges_FullMethodName: auth.PermManageRoles,
+}
+
+// allowedPeers are the services that may call AccountService when it runs
+// over mutual TLS.
+var allowedPeers = []string{"order", "graphql"}
+
+func ListenGRPC(ctx context.Context, s Service, r Repository, verifier auth.TokenVerifier, hashKey []byte, creds mtls.Config, port int, shutdownTimeout time.Duration) error {
+	transport, err := creds.ServerOption(ctx)
+	if err != nil {
+		return err
+	}
+
+	interceptors := []grpc.UnaryServerInterceptor{
+		logging.UnaryServerInterceptor(),
+		metrics.UnaryServerInterceptor(),
+		errs.UnaryServerInterceptor(),
+	}
+	if creds.MutualTLS() {
+		interceptors = append(interceptors, mtls.RequirePeers(allowedPeers...))
+	} else {
+		slog.Warn("Mutual TLS is not configured, any client may call AccountService")
+	}
+	interceptors = append(interceptors,
+		auth.UnaryServerInterceptor(verifier, permissions),
+		validation.UnaryServerInterceptor(validators),
+		idempotency.UnaryServerInterceptor(r, idempotency.DefaultTTL, hashKey, pb.AccountService_PostAccount_FullMethodName),
+	)
+
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
 	if err != nil {
 		return err
 	}
-	serv := grpc.NewServer()
+	serv := grpc.NewServer(
+		transport,
+		tracing.ServerOption(),
+		grpc.ChainUnaryInterceptor(interceptors...),
+	)
+	h := healthcheck.Register(ctx, serv, pb.AccountService_ServiceDesc.ServiceName, r.Ping)
 	pb.RegisterAccountServiceServer(serv, &grpcServer{s, pb.UnimplementedAccountServiceServer{}})
 	reflection.Register(serv)
-	return serv.Serve(lis)
+	return lifecycle.ServeGRPC(ctx, serv, lis, h, shutdownTimeout)
 }
 
 func (s *grpcServer) PostAccount(ctx context.Context, r *pb.PostAccountRequest) (*pb.PostAccountResponse, error) {
-	a, err := s.service.PostAccount(ctx, r.Name)
+	a, err := s.service.PostAccount(ctx, r.Name, r.Email, r.Password)
 	if err != nil {
 		return nil, err
 	}
 
-	return &pb.PostAccountResponse{Account: &pb.Account{
-		Id:   a.ID,
-		Name: a.Name,
-	}}, nil
+	return &pb.PostAccountResponse{Account: accountToProto(a)}, nil
 }
 
 func (s *grpcServer) GetAccount(ctx context.Context, r *pb.GetAccountRequest) (*pb.GetAccountResponse, error) {
@@ -45,10 +93,7 @@ func (s *grpcServer) GetAccount(ctx context.Context, r *pb.GetAccountRequest) (*
 		return nil, err
 	}
 
-	return &pb.GetAccountResponse{Account: &pb.Account{
-		Id:   a.ID,
-		Name: a.Name,
-	}}, nil
+	return &pb.GetAccountResponse{Account: accountToProto(a)}, nil
 }
 
 func (s *grpcServer) GetAccounts(ctx context.Context, r *pb.GetAccountsRequest) (*pb.GetAccountsResponse, error) {
@@ -59,10 +104,146 @@ func (s *grpcServer) GetAccounts(ctx context.Context, r *pb.GetAccountsRequest)
 
 	var accounts []*pb.Account
 	for _, p := range a {
-		accounts = append(accounts, &pb.Account{
-			Id:   p.ID,
-			Name: p.Name,
-		})
+		accounts = append(accounts, accountToProto(p))
 	}
 	return &pb.GetAccountsResponse{Accounts: accounts}, nil
 }
+
+func (s *grpcServer) ListAccounts(ctx context.Context, r *pb.ListAccountsRequest) (*pb.ListAccountsResponse, error) {
+	page, err := s.service.ListAccounts(ctx, ListAccountsQuery{First: int(r.First), After: r.After})
+	if err != nil {
+		return nil, err
+	}
+
+	res := &pb.ListAccountsResponse{
+		Cursors:     page.Cursors,
+		HasNextPage: page.HasNextPage,
+		TotalCount:  uint64(page.TotalCount),
+	}
+	for _, a := range page.Accounts {
+		res.Accounts = append(res.Accounts, accountToProto(a))
+	}
+	return res, nil
+}
+
+func (s *grpcServer) UpdateAccount(ctx context.Context, r *pb.UpdateAccountRequest) (*pb.UpdateAccountResponse, error) {
+	if err := auth.RequireAccountAccess(ctx, r.Id); err != nil {
+		return nil, err
+	}
+
+	a, err := s.service.UpdateAccount(ctx, r.Id, r.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.UpdateAccountResponse{Account: accountToProto(a)}, nil
+}
+
+func (s *grpcServer) DeactivateAccount(ctx context.Context, r *pb.DeactivateAccountRequest) (*pb.DeactivateAccountResponse, error) {
+	if err := auth.RequireAccountAccess(ctx, r.Id); err != nil {
+		return nil, err
+	}
+
+	a, err := s.service.DeactivateAccount(ctx, r.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.DeactivateAccountResponse{Account: accountToProto(a)}, nil
+}
+
+func (s *grpcServer) DeleteAccount(ctx context.Context, r *pb.DeleteAccountRequest) (*pb.DeleteAccountResponse, error) {
+	if err := auth.RequireAccountAccess(ctx, r.Id); err != nil {
+		return nil, err
+	}
+
+	if err := s.service.DeleteAccount(ctx, r.Id); err != nil {
+		return nil, err
+	}
+
+	return &pb.DeleteAccountResponse{}, nil
+}
+
+func (s *grpcServer) Login(ctx context.Context, r *pb.LoginRequest) (*pb.LoginResponse, error) {
+	tokens, err := s.service.Login(ctx, r.Email, r.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.LoginResponse{Tokens: tokenPairToProto(tokens)}, nil
+}
+
+func (s *grpcServer) RefreshToken(ctx context.Context, r *pb.RefreshTokenRequest) (*pb.RefreshTokenResponse, error) {
+	tokens, err := s.service.RefreshToken(ctx, r.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.RefreshTokenResponse{Tokens: tokenPairToProto(tokens)}, nil
+}
+
+func (s *grpcServer) GrantRole(ctx context.Context, r *pb.GrantRoleRequest) (*pb.GrantRoleResponse, error) {
+	a, err := s.service.GrantRole(ctx, r.AccountId, auth.Role(r.Role), r.Reason)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.GrantRoleResponse{Account: accountToProto(a)}, nil
+}
+
+func (s *grpcServer) RevokeRole(ctx context.Context, r *pb.RevokeRoleRequest) (*pb.RevokeRoleResponse, error) {
+	a, err := s.service.RevokeRole(ctx, r.AccountId, auth.Role(r.Role), r.Reason)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.RevokeRoleResponse{Account: accountToProto(a)}, nil
+}
+
+func (s *grpcServer) GetRoleChanges(ctx context.Context, r *pb.GetRoleChangesRequest) (*pb.GetRoleChangesResponse, error) {
+	changes, err := s.service.GetRoleChanges(ctx, r.AccountId, r.Skip, r.Take)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &pb.GetRoleChangesResponse{}
+	for _, c := range changes {
+		changedAt, err := c.ChangedAt.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		res.RoleChanges = append(res.RoleChanges, &pb.RoleChange{
+			Id:        c.ID,
+			AccountId: c.AccountId,
+			Role:      string(c.Role),
+			Action:    string(c.Action),
+			ActorId:   c.ActorId,
+			Reason:    c.Reason,
+			ChangedAt: changedAt,
+		})
+	}
+	return res, nil
+}
+
+func accountToProto(a *Account) *pb.Account {
+	roles := make([]string, 0, len(a.Roles))
+	for _, role := range a.Roles {
+		roles = append(roles, string(role))
+	}
+
+	return &pb.Account{
+		Id:     a.ID,
+		Name:   a.Name,
+		Email:  a.Email,
+		Status: string(a.Status),
+		Roles:  roles,
+	}
+}
+
+func tokenPairToProto(t *TokenPair) *pb.TokenPair {
+	return &pb.TokenPair{
+		AccessToken:  t.AccessToken,
+		RefreshToken: t.RefreshToken,
+		ExpiresIn:    int64(t.ExpiresIn.Seconds()),
+	}
+}
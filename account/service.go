@@ -2,27 +2,93 @@ package account
 
 import (
 	"context"
+	"errors"
+	"github.com/fabian-emmanuel/go-ms/auth"
+	"github.com/fabian-emmanuel/go-ms/errs"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/segmentio/ksuid"
+	"log/slog"
+	"net/mail"
+	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 type Service interface {
-	PostAccount(ctx context.Context, name string) (*Account, error)
+	PostAccount(ctx context.Context, name, email, password string) (*Account, error)
 	GetAccount(ctx context.Context, id string) (*Account, error)
 	GetAccounts(ctx context.Context, skip, take uint64) ([]*Account, error)
+	ListAccounts(ctx context.Context, query ListAccountsQuery) (*AccountPage, error)
+	UpdateAccount(ctx context.Context, id, name string) (*Account, error)
+	DeactivateAccount(ctx context.Context, id string) (*Account, error)
+	DeleteAccount(ctx context.Context, id string) error
+	Login(ctx context.Context, email, password string) (*TokenPair, error)
+	RefreshToken(ctx context.Context, refreshToken string) (*TokenPair, error)
+	GrantRole(ctx context.Context, accountId string, role auth.Role, reason string) (*Account, error)
+	RevokeRole(ctx context.Context, accountId string, role auth.Role, reason string) (*Account, error)
+	GetRoleChanges(ctx context.Context, accountId string, skip, take uint64) ([]*RoleChange, error)
 }
 
+// maxNameLength and maxEmailLength match the accounts columns.
+const (
+	maxNameLength  = 30
+	maxEmailLength = 254
+)
+
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidName        = errs.InvalidArgument("name must be between 1 and 30 characters")
+	ErrInvalidEmail       = errs.InvalidArgument("invalid email address")
+	ErrInvalidCredentials = errs.Unauthenticated("invalid email or password")
+	ErrSelfRevokeAdmin    = errs.FailedPrecondition("admins cannot revoke their own admin role")
+)
+
 type accountService struct {
 	repository Repository
+	signer     *auth.Signer
+	// dummyHash is verified against when an email is unknown, so a login for a
+	// missing account takes as long as one with a wrong password.
+	dummyHash string
 }
 
-func NewAccountService(repository Repository) Service {
-	return &accountService{repository}
+func NewAccountService(repository Repository, signer *auth.Signer) Service {
+	dummyHash, err := hashPassword(ksuid.New().String())
+	if err != nil {
+		panic(err)
+	}
+	return &accountService{repository, signer, dummyHash}
 }
 
-func (s *accountService) PostAccount(ctx context.Context, name string) (*Account, error) {
+func (s *accountService) PostAccount(ctx context.Context, name, email, password string) (*Account, error) {
+	name, err := validateName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	email, err = normalizeEmail(email)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validatePassword(password); err != nil {
+		return nil, err
+	}
+	passwordHash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
 	a := &Account{
-		Name: name,
-		ID:   ksuid.New().String(),
+		Name:         name,
+		ID:           ksuid.New().String(),
+		Email:        email,
+		PasswordHash: passwordHash,
+		Status:       StatusActive,
+		Roles:        []auth.Role{auth.RoleCustomer},
 	}
 
 	if err := s.repository.PutAccount(ctx, *a); err != nil {
@@ -41,3 +107,219 @@ func (s *accountService) GetAccounts(ctx context.Context, skip, take uint64) ([]
 	}
 	return s.repository.ListAccounts(ctx, skip, take)
 }
+
+func (s *accountService) ListAccounts(ctx context.Context, query ListAccountsQuery) (*AccountPage, error) {
+	if query.First <= 0 {
+		query.First = defaultPageSize
+	}
+	if query.First > maxPageSize {
+		query.First = maxPageSize
+	}
+	return s.repository.PageAccounts(ctx, query)
+}
+
+func (s *accountService) UpdateAccount(ctx context.Context, id, name string) (*Account, error) {
+	name, err := validateName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := s.repository.GetAccountById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	a.Name = name
+
+	if err := s.repository.UpdateAccount(ctx, *a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// DeactivateAccount blocks new orders for the account but keeps it, and so the
+// history of orders placed against it, readable.
+func (s *accountService) DeactivateAccount(ctx context.Context, id string) (*Account, error) {
+	return s.repository.SetAccountStatus(ctx, id, StatusDeactivated)
+}
+
+// DeleteAccount removes the account for good. Orders placed by it stay in the
+// order service and still reference its ID.
+func (s *accountService) DeleteAccount(ctx context.Context, id string) error {
+	return s.repository.DeleteAccount(ctx, id)
+}
+
+func (s *accountService) Login(ctx context.Context, email, password string) (*TokenPair, error) {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	a, err := s.repository.GetAccountByEmail(ctx, email)
+	if errors.Is(err, ErrAccountNotFound) {
+		_, _ = verifyPassword(s.dummyHash, password)
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Accounts created before credentials existed have no password to match
+	if a.PasswordHash == "" {
+		_, _ = verifyPassword(s.dummyHash, password)
+		return nil, ErrInvalidCredentials
+	}
+
+	ok, err := verifyPassword(a.PasswordHash, password)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || !a.IsActive() {
+		return nil, ErrInvalidCredentials
+	}
+
+	return s.issueTokens(ctx, a, ksuid.New().String())
+}
+
+// RefreshToken exchanges a refresh token for a new pair. The old refresh token
+// is spent; presenting it again revokes its whole family.
+func (s *accountService) RefreshToken(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	claims, err := s.signer.Parse(refreshToken, auth.RefreshToken)
+	if err != nil {
+		return nil, ErrRefreshTokenInvalid
+	}
+
+	t, err := s.repository.ConsumeRefreshToken(ctx, claims.ID)
+	if errors.Is(err, ErrRefreshTokenReused) {
+		slog.WarnContext(ctx, "Refresh token reuse detected, revoked token family", "account_id", claims.Subject, "family", claims.Family)
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := s.repository.GetAccountById(ctx, t.AccountId)
+	if err != nil {
+		return nil, err
+	}
+	if !a.IsActive() {
+		return nil, ErrRefreshTokenInvalid
+	}
+
+	return s.issueTokens(ctx, a, t.FamilyId)
+}
+
+func (s *accountService) issueTokens(ctx context.Context, a *Account, familyId string) (*TokenPair, error) {
+	now := time.Now().UTC()
+
+	access, err := s.signer.Sign(&auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        ksuid.New().String(),
+			Issuer:    auth.Issuer,
+			Subject:   a.ID,
+			Audience:  jwt.ClaimStrings{auth.Audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(accessTokenTTL)),
+		},
+		TokenType: auth.AccessToken,
+		Email:     a.Email,
+		Roles:     a.Roles,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	t := RefreshToken{
+		ID:        ksuid.New().String(),
+		AccountId: a.ID,
+		FamilyId:  familyId,
+		CreatedAt: now,
+		ExpiresAt: now.Add(refreshTokenTTL),
+	}
+	refresh, err := s.signer.Sign(&auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        t.ID,
+			Issuer:    auth.Issuer,
+			Subject:   a.ID,
+			Audience:  jwt.ClaimStrings{auth.Audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(t.ExpiresAt),
+		},
+		TokenType: auth.RefreshToken,
+		Family:    familyId,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repository.CreateRefreshToken(ctx, t); err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresIn:    accessTokenTTL,
+	}, nil
+}
+
+// GrantRole gives the account role. The calling admin is recorded as the actor.
+func (s *accountService) GrantRole(ctx context.Context, accountId string, role auth.Role, reason string) (*Account, error) {
+	return s.changeRole(ctx, accountId, role, RoleGranted, actorId(ctx), reason)
+}
+
+// RevokeRole takes role away from the account. Tokens already issued keep the
+// role until they expire; the next refresh drops it.
+func (s *accountService) RevokeRole(ctx context.Context, accountId string, role auth.Role, reason string) (*Account, error) {
+	actor := actorId(ctx)
+	if role == auth.RoleAdmin && actor == accountId {
+		return nil, ErrSelfRevokeAdmin
+	}
+	return s.changeRole(ctx, accountId, role, RoleRevoked, actor, reason)
+}
+
+func (s *accountService) GetRoleChanges(ctx context.Context, accountId string, skip, take uint64) ([]*RoleChange, error) {
+	if take > 100 || (skip == 0 && take == 0) {
+		take = 100
+	}
+	return s.repository.ListRoleChanges(ctx, accountId, skip, take)
+}
+
+func (s *accountService) changeRole(ctx context.Context, accountId string, role auth.Role, action RoleAction, actor, reason string) (*Account, error) {
+	role, err := auth.ParseRole(string(role))
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repository.ChangeRole(ctx, RoleChange{
+		ID:        ksuid.New().String(),
+		AccountId: accountId,
+		Role:      role,
+		Action:    action,
+		ActorId:   actor,
+		Reason:    strings.TrimSpace(reason),
+		ChangedAt: time.Now().UTC(),
+	})
+}
+
+// actorId is the account making the request, or empty for internal callers.
+func actorId(ctx context.Context) string {
+	if p := auth.FromContext(ctx); p != nil {
+		return p.AccountId
+	}
+	return ""
+}
+
+func normalizeEmail(email string) (string, error) {
+	addr, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil || addr.Name != "" || len(addr.Address) > maxEmailLength {
+		return "", ErrInvalidEmail
+	}
+	return strings.ToLower(addr.Address), nil
+}
+
+func validateName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxNameLength {
+		return "", ErrInvalidName
+	}
+	return name, nil
+}
@@ -0,0 +1,120 @@
+package account
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"github.com/fabian-emmanuel/go-ms/auth"
+	"sync"
+	"testing"
+	"time"
+)
+
+type storedToken struct {
+	RefreshToken
+	used, revoked bool
+}
+
+// tokenRepo keeps accounts and refresh tokens in memory, spending and revoking
+// tokens the way the Postgres repository does.
+type tokenRepo struct {
+	Repository
+	mu       sync.Mutex
+	accounts map[string]*Account
+	tokens   map[string]*storedToken
+}
+
+func (r *tokenRepo) GetAccountById(_ context.Context, id string) (*Account, error) {
+	if a, ok := r.accounts[id]; ok {
+		return a, nil
+	}
+	return nil, ErrAccountNotFound
+}
+
+func (r *tokenRepo) GetAccountByEmail(_ context.Context, email string) (*Account, error) {
+	for _, a := range r.accounts {
+		if a.Email == email {
+			return a, nil
+		}
+	}
+	return nil, ErrAccountNotFound
+}
+
+func (r *tokenRepo) CreateRefreshToken(_ context.Context, t RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens[t.ID] = &storedToken{RefreshToken: t}
+	return nil
+}
+
+func (r *tokenRepo) ConsumeRefreshToken(_ context.Context, id string) (*RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.tokens[id]
+	if !ok || t.revoked || t.ExpiresAt.Before(time.Now()) {
+		return nil, ErrRefreshTokenInvalid
+	}
+	if t.used {
+		for _, other := range r.tokens {
+			if other.FamilyId == t.FamilyId {
+				other.revoked = true
+			}
+		}
+		return nil, ErrRefreshTokenReused
+	}
+	t.used = true
+	return &t.RefreshToken, nil
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := auth.NewSigner(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := hashPassword("correct horse battery")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo := &tokenRepo{
+		accounts: map[string]*Account{"account1": {
+			ID:           "account1",
+			Email:        "alice@example.com",
+			PasswordHash: hash,
+			Status:       StatusActive,
+		}},
+		tokens: map[string]*storedToken{},
+	}
+	s := NewAccountService(repo, signer)
+	ctx := context.Background()
+
+	login := func() *TokenPair {
+		pair, err := s.Login(ctx, "alice@example.com", "correct horse battery")
+		if err != nil {
+			t.Fatalf("Login: %v", err)
+		}
+		return pair
+	}
+
+	stolen := login()
+	other := login()
+
+	rotated, err := s.RefreshToken(ctx, stolen.RefreshToken)
+	if err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+	if _, err := s.RefreshToken(ctx, stolen.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("reused RefreshToken = %v, want ErrRefreshTokenReused", err)
+	}
+	if _, err := s.RefreshToken(ctx, rotated.RefreshToken); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Errorf("RefreshToken from a revoked family = %v, want ErrRefreshTokenInvalid", err)
+	}
+	if _, err := s.RefreshToken(ctx, other.RefreshToken); err != nil {
+		t.Errorf("RefreshToken from another login's family: %v", err)
+	}
+}
@@ -1,4 +1,65 @@
 CREATE TABLE IF NOT EXISTS accounts (
     id CHAR(30) PRIMARY KEY,
-    name VARCHAR(30) NOT NULL
-);
\ No newline at end of file
+    name VARCHAR(30) NOT NULL,
+    email VARCHAR(254) UNIQUE,
+    password_hash TEXT,
+    status VARCHAR(16) NOT NULL DEFAULT 'active'
+);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id CHAR(27) PRIMARY KEY,
+    account_id CHAR(30) NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    family_id CHAR(27) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+CREATE INDEX IF NOT EXISTS refresh_tokens_account_id_idx ON refresh_tokens (account_id);
+
+CREATE TABLE IF NOT EXISTS account_roles (
+    account_id CHAR(30) NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    role VARCHAR(16) NOT NULL,
+    granted_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (account_id, role)
+);
+
+-- role_changes outlives the accounts it refers to so the audit trail survives deletion
+CREATE TABLE IF NOT EXISTS role_changes (
+    id CHAR(27) PRIMARY KEY,
+    account_id VARCHAR(30) NOT NULL,
+    role VARCHAR(16) NOT NULL,
+    action VARCHAR(16) NOT NULL,
+    actor_id VARCHAR(30) NOT NULL DEFAULT '',
+    reason TEXT NOT NULL DEFAULT '',
+    changed_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS role_changes_account_id_idx ON role_changes (account_id, changed_at);
+
+CREATE TABLE IF NOT EXISTS outbox (
+    id CHAR(27) PRIMARY KEY,
+    event_type VARCHAR(64) NOT NULL,
+    aggregate_id VARCHAR(64) NOT NULL,
+    payload JSONB NOT NULL,
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    published_at TIMESTAMP WITH TIME ZONE,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (occurred_at, id) WHERE published_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    method VARCHAR(128) NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL,
+    request_hash BYTEA NOT NULL,
+    response BYTEA,
+    locked_until TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (method, idempotency_key)
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
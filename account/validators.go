@@ -0,0 +1,69 @@
+package account
+
+import (
+	"fmt"
+	"github.com/fabian-emmanuel/go-ms/account/pb"
+	"github.com/fabian-emmanuel/go-ms/auth"
+	"github.com/fabian-emmanuel/go-ms/validation"
+)
+
+const maxReasonLength = 500
+
+// validators check every AccountService request before it reaches the service.
+var validators = map[string]validation.Validator{
+	pb.AccountService_PostAccount_FullMethodName: validation.For(func(r *pb.PostAccountRequest, v *validation.Violations) {
+		v.Length("name", r.Name, 1, maxNameLength)
+		checkEmail(v, "email", r.Email)
+		checkPassword(v, "password", r.Password)
+	}),
+	pb.AccountService_GetAccount_FullMethodName: validation.For(func(r *pb.GetAccountRequest, v *validation.Violations) {
+		v.ID("id", r.Id)
+	}),
+	pb.AccountService_UpdateAccount_FullMethodName: validation.For(func(r *pb.UpdateAccountRequest, v *validation.Violations) {
+		v.ID("id", r.Id)
+		v.Length("name", r.Name, 1, maxNameLength)
+	}),
+	pb.AccountService_DeactivateAccount_FullMethodName: validation.For(func(r *pb.DeactivateAccountRequest, v *validation.Violations) {
+		v.ID("id", r.Id)
+	}),
+	pb.AccountService_DeleteAccount_FullMethodName: validation.For(func(r *pb.DeleteAccountRequest, v *validation.Violations) {
+		v.ID("id", r.Id)
+	}),
+	pb.AccountService_Login_FullMethodName: validation.For(func(r *pb.LoginRequest, v *validation.Violations) {
+		v.Required("email", r.Email)
+		v.Required("password", r.Password)
+	}),
+	pb.AccountService_RefreshToken_FullMethodName: validation.For(func(r *pb.RefreshTokenRequest, v *validation.Violations) {
+		v.Required("refreshToken", r.RefreshToken)
+	}),
+	pb.AccountService_GrantRole_FullMethodName: validation.For(func(r *pb.GrantRoleRequest, v *validation.Violations) {
+		checkRoleChange(v, r.AccountId, r.Role, r.Reason)
+	}),
+	pb.AccountService_RevokeRole_FullMethodName: validation.For(func(r *pb.RevokeRoleRequest, v *validation.Violations) {
+		checkRoleChange(v, r.AccountId, r.Role, r.Reason)
+	}),
+	pb.AccountService_GetRoleChanges_FullMethodName: validation.For(func(r *pb.GetRoleChangesRequest, v *validation.Violations) {
+		v.OptionalID("accountId", r.AccountId)
+	}),
+}
+
+func checkEmail(v *validation.Violations, field, email string) {
+	if _, err := normalizeEmail(email); err != nil {
+		v.Add(field, "must be a valid email address")
+	}
+}
+
+// checkPassword counts bytes rather than characters, as hashPassword does.
+func checkPassword(v *validation.Violations, field, password string) {
+	if n := len(password); n < minPasswordLength || n > maxPasswordLength {
+		v.Add(field, fmt.Sprintf("must be between %d and %d characters", minPasswordLength, maxPasswordLength))
+	}
+}
+
+func checkRoleChange(v *validation.Violations, accountId, role, reason string) {
+	v.ID("accountId", accountId)
+	if _, err := auth.ParseRole(role); err != nil {
+		v.Add("role", "must be one of admin, merchant or customer")
+	}
+	v.MaxLength("reason", reason, maxReasonLength)
+}
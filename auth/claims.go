@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"fmt"
+	"github.com/fabian-emmanuel/go-ms/errs"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	Issuer   = "go-ms/account"
+	Audience = "go-ms"
+)
+
+type TokenType string
+
+const (
+	AccessToken  TokenType = "access"
+	RefreshToken TokenType = "refresh"
+)
+
+var ErrInvalidToken = errs.Unauthenticated("invalid token")
+
+// Claims are the claims of tokens issued by the account service. The subject
+// is the account ID. Refresh tokens also carry the family they were rotated
+// from, so reuse of an old one can revoke the whole chain.
+type Claims struct {
+	jwt.RegisteredClaims
+	TokenType TokenType `json:"token_type"`
+	Email     string    `json:"email,omitempty"`
+	Roles     []Role    `json:"roles,omitempty"`
+	Family    string    `json:"family,omitempty"`
+}
+
+// ParseToken verifies a token's signature, issuer, audience and expiry using
+// the key returned by keyFunc for its "kid", and checks it is of type want.
+func ParseToken(token string, want TokenType, keyFunc jwt.Keyfunc) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(token, claims, keyFunc,
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithIssuer(Issuer),
+		jwt.WithAudience(Audience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	if claims.TokenType != want {
+		return nil, fmt.Errorf("%w: expected a %s token", ErrInvalidToken, want)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidToken)
+	}
+	return claims, nil
+}
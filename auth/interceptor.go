@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"strings"
+)
+
+// MetadataKey carries the caller's access token between services.
+const MetadataKey = "authorization"
+
+type TokenVerifier interface {
+	Verify(ctx context.Context, token string) (*Claims, error)
+}
+
+type tokenKey struct{}
+
+// WithToken keeps the caller's access token on ctx so clients built with
+// UnaryClientInterceptor forward it to the services they call.
+func WithToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, tokenKey{}, token)
+}
+
+func TokenFromContext(ctx context.Context) string {
+	token, _ := ctx.Value(tokenKey{}).(string)
+	return token
+}
+
+// UnaryServerInterceptor authenticates calls that carry an access token and
+// rejects calls to the methods in required unless the caller holds the
+// permission listed for it. Other methods stay open to anonymous callers.
+func UnaryServerInterceptor(verifier TokenVerifier, required map[string]Permission) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authorize(ctx, verifier, required, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is UnaryServerInterceptor for streaming RPCs.
+func StreamServerInterceptor(verifier TokenVerifier, required map[string]Permission) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authorize(ss.Context(), verifier, required, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// authorize adds the caller's principal and token to ctx and checks that they
+// may call fullMethod.
+func authorize(ctx context.Context, verifier TokenVerifier, required map[string]Permission, fullMethod string) (context.Context, error) {
+	var p *Principal
+	if token := tokenFromMetadata(ctx); token != "" {
+		claims, err := verifier.Verify(ctx, token)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, "invalid access token")
+		}
+		p = PrincipalFromClaims(claims)
+		ctx = WithToken(NewContext(ctx, p), token)
+	}
+
+	if perm, ok := required[fullMethod]; ok {
+		if err := RequirePermission(ctx, perm); err != nil {
+			return nil, err
+		}
+	}
+
+	return ctx, nil
+}
+
+// RequirePermission fails unless the caller authenticated by the server
+// interceptors holds perm.
+func RequirePermission(ctx context.Context, perm Permission) error {
+	p := FromContext(ctx)
+	if p == nil {
+		return status.Error(codes.Unauthenticated, "access token required")
+	}
+	if !p.Can(perm) {
+		return status.Errorf(codes.PermissionDenied, "missing permission %s", perm)
+	}
+	return nil
+}
+
+// RequireAccountAccess fails unless the caller authenticated by the server
+// interceptors may act on the account's data.
+func RequireAccountAccess(ctx context.Context, accountId string) error {
+	p := FromContext(ctx)
+	if p == nil {
+		return status.Error(codes.Unauthenticated, "access token required")
+	}
+	if !p.CanAccessAccount(accountId) {
+		return status.Error(codes.PermissionDenied, "not allowed to access this account")
+	}
+	return nil
+}
+
+// UnaryClientInterceptor forwards the access token on ctx, if any.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if token := TokenFromContext(ctx); token != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, MetadataKey, "Bearer "+token)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientInterceptor is UnaryClientInterceptor for streaming RPCs.
+func StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if token := TokenFromContext(ctx); token != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, MetadataKey, "Bearer "+token)
+		}
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
+
+func tokenFromMetadata(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	values := md.Get(MetadataKey)
+	if len(values) == 0 {
+		return ""
+	}
+	token, _ := strings.CutPrefix(values[0], "Bearer ")
+	return strings.TrimSpace(token)
+}
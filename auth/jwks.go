@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+)
+
+// JWKSPath is where the account service serves its public keys.
+const JWKSPath = "/.well-known/jwks.json"
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKSHandler serves the signer's public keys for other services to verify tokens against.
+func JWKSHandler(s *Signer) http.Handler {
+	body, err := json.Marshal(s.JWKS())
+	if err != nil {
+		panic(err)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		if _, err := w.Write(body); err != nil {
+			slog.ErrorContext(r.Context(), "Error writing JWKS", "err", err)
+		}
+	})
+}
+
+// publicJWK describes key as a JWK whose kid is its RFC 7638 thumbprint.
+func publicJWK(key crypto.PublicKey) (JWK, error) {
+	b64 := base64.RawURLEncoding.EncodeToString
+
+	var jwk JWK
+	var thumbprint string
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		jwk = JWK{Kty: "RSA", Alg: "RS256", N: b64(k.N.Bytes()), E: b64(big.NewInt(int64(k.E)).Bytes())}
+		thumbprint = fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`, jwk.E, jwk.N)
+	case ed25519.PublicKey:
+		jwk = JWK{Kty: "OKP", Alg: "EdDSA", Crv: "Ed25519", X: b64(k)}
+		thumbprint = fmt.Sprintf(`{"crv":"Ed25519","kty":"OKP","x":%q}`, jwk.X)
+	default:
+		return JWK{}, fmt.Errorf("unsupported public key type %T", key)
+	}
+
+	sum := sha256.Sum256([]byte(thumbprint))
+	jwk.Kid = b64(sum[:])
+	jwk.Use = "sig"
+	return jwk, nil
+}
+
+// PublicKey turns a JWK back into a key for verification.
+func (j JWK) PublicKey() (crypto.PublicKey, error) {
+	decode := base64.RawURLEncoding.DecodeString
+
+	switch j.Kty {
+	case "RSA":
+		n, err := decode(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(j.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "OKP":
+		if j.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", j.Crv)
+		}
+		x, err := decode(j.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key length %d", len(x))
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", j.Kty)
+	}
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"context"
+	"slices"
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	AccountId string
+	Email     string
+	Roles     []Role
+}
+
+type principalKey struct{}
+
+func PrincipalFromClaims(c *Claims) *Principal {
+	return &Principal{
+		AccountId: c.Subject,
+		Email:     c.Email,
+		Roles:     c.Roles,
+	}
+}
+
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal of the request, or nil for anonymous callers.
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// HasRole reports whether the principal holds role. Admins hold every role and
+// any signed-in account counts as a customer.
+func (p *Principal) HasRole(role Role) bool {
+	if p == nil {
+		return false
+	}
+	if role == RoleCustomer || slices.Contains(p.Roles, RoleAdmin) {
+		return true
+	}
+	return slices.Contains(p.Roles, role)
+}
+
+func (p *Principal) IsAdmin() bool {
+	return p.HasRole(RoleAdmin)
+}
+
+// Can reports whether any of the principal's roles grants perm.
+func (p *Principal) Can(perm Permission) bool {
+	if p == nil {
+		return false
+	}
+	for _, role := range p.Roles {
+		if slices.Contains(rolePermissions[role], perm) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAccessAccount reports whether the principal may act on the account's data:
+// it is their own account, or they are an admin.
+func (p *Principal) CanAccessAccount(accountId string) bool {
+	if p == nil {
+		return false
+	}
+	return p.AccountId == accountId || p.IsAdmin()
+}
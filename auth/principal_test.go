@@ -0,0 +1,35 @@
+package auth
+
+import "testing"
+
+func TestPrincipalCan(t *testing.T) {
+	tests := []struct {
+		name  string
+		roles []Role
+		perm  Permission
+		want  bool
+	}{
+		{name: "customer managing roles", roles: []Role{RoleCustomer}, perm: PermManageRoles, want: false},
+		{name: "customer managing stock", roles: []Role{RoleCustomer}, perm: PermManageStock, want: false},
+		{name: "merchant creating products", roles: []Role{RoleMerchant}, perm: PermCreateProducts, want: true},
+		{name: "merchant managing roles", roles: []Role{RoleMerchant}, perm: PermManageRoles, want: false},
+		{name: "service managing stock", roles: []Role{RoleService}, perm: PermManageStock, want: true},
+		{name: "service managing orders", roles: []Role{RoleService}, perm: PermManageOrders, want: false},
+		{name: "admin managing roles", roles: []Role{RoleAdmin}, perm: PermManageRoles, want: true},
+		{name: "no roles", perm: PermListAccounts, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Principal{AccountId: "account1", Roles: tt.roles}
+			if got := p.Can(tt.perm); got != tt.want {
+				t.Errorf("Can(%s) = %v, want %v", tt.perm, got, tt.want)
+			}
+		})
+	}
+
+	var anonymous *Principal
+	if anonymous.Can(PermCreateProducts) {
+		t.Error("anonymous principal Can(PermCreateProducts) = true")
+	}
+}
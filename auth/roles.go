@@ -0,0 +1,42 @@
+package auth
+
+import "fmt"
+
+type Role string
+
+const (
+	RoleAdmin    Role = "admin"
+	RoleMerchant Role = "merchant"
+	RoleCustomer Role = "customer"
+	// RoleService is held by the accounts other services call with, never by people.
+	RoleService Role = "service"
+)
+
+var Roles = []Role{RoleAdmin, RoleMerchant, RoleCustomer, RoleService}
+
+func ParseRole(s string) (Role, error) {
+	for _, r := range Roles {
+		if string(r) == s {
+			return r, nil
+		}
+	}
+	return "", fmt.Errorf("unknown role %q", s)
+}
+
+type Permission string
+
+const (
+	PermCreateProducts Permission = "products:create"
+	PermListAccounts   Permission = "accounts:list"
+	PermManageRoles    Permission = "roles:manage"
+	PermManageOrders   Permission = "orders:manage"
+	PermManageStock    Permission = "stock:manage"
+)
+
+// rolePermissions lists what each role may do beyond acting on its own account.
+var rolePermissions = map[Role][]Permission{
+	RoleAdmin:    {PermCreateProducts, PermListAccounts, PermManageRoles, PermManageOrders, PermManageStock},
+	RoleMerchant: {PermCreateProducts},
+	RoleCustomer: {},
+	RoleService:  {PermManageStock},
+}
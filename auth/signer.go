@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"log/slog"
+	"os"
+)
+
+// Signer signs tokens with the account service's private key. RSA keys sign
+// with RS256 and Ed25519 keys with EdDSA.
+type Signer struct {
+	key    crypto.Signer
+	method jwt.SigningMethod
+	kid    string
+}
+
+// LoadSigner reads a PEM encoded RSA or Ed25519 private key (PKCS#8, or PKCS#1
+// for RSA). With no path it generates a throwaway Ed25519 key, which is only
+// good for development: tokens stop verifying when the process restarts.
+func LoadSigner(path string) (*Signer, error) {
+	if path == "" {
+		slog.Warn("No signing key configured, generating a temporary one")
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return NewSigner(key)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("signing key is not PEM encoded")
+	}
+
+	var key any
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key: %w", err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported signing key type %T", key)
+	}
+	return NewSigner(signer)
+}
+
+func NewSigner(key crypto.Signer) (*Signer, error) {
+	s := &Signer{key: key}
+	switch key.(type) {
+	case *rsa.PrivateKey:
+		s.method = jwt.SigningMethodRS256
+	case ed25519.PrivateKey:
+		s.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported signing key type %T", key)
+	}
+
+	jwk, err := publicJWK(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	s.kid = jwk.Kid
+	return s, nil
+}
+
+func (s *Signer) Sign(claims *Claims) (string, error) {
+	token := jwt.NewWithClaims(s.method, claims)
+	token.Header["kid"] = s.kid
+	return token.SignedString(s.key)
+}
+
+// Parse verifies a token issued by this signer.
+func (s *Signer) Parse(token string, want TokenType) (*Claims, error) {
+	return ParseToken(token, want, func(t *jwt.Token) (any, error) {
+		if kid, _ := t.Header["kid"].(string); kid != s.kid {
+			return nil, fmt.Errorf("unknown key %q", kid)
+		}
+		return s.key.Public(), nil
+	})
+}
+
+// Verify checks an access token, so the issuing service needs no JWKS round trip.
+func (s *Signer) Verify(_ context.Context, token string) (*Claims, error) {
+	return s.Parse(token, AccessToken)
+}
+
+func (s *Signer) JWKS() JWKS {
+	jwk, _ := publicJWK(s.key.Public())
+	return JWKS{Keys: []JWK{jwk}}
+}
@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// jwksRefreshInterval is how long fetched keys are trusted before refetching.
+	jwksRefreshInterval = 5 * time.Minute
+	// jwksMinRefetch limits refetches triggered by tokens signed with unknown keys.
+	jwksMinRefetch = 30 * time.Second
+)
+
+// Verifier checks access tokens against the keys the account service publishes
+// at its JWKS endpoint. Keys are cached and refetched periodically, and early
+// when a token names a key the cache does not know yet (after a key rotation).
+// Only one fetch runs at a time, and tokens signed with a known key keep
+// verifying against the cached key while it runs.
+type Verifier struct {
+	url    string
+	client *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+	// fetching is closed when the fetch in flight finishes, nil when idle
+	fetching chan struct{}
+}
+
+func NewVerifier(jwksUrl string) *Verifier {
+	return &Verifier{
+		url:    jwksUrl,
+		client: &http.Client{Timeout: 5 * time.Second},
+		keys:   map[string]crypto.PublicKey{},
+	}
+}
+
+func (v *Verifier) Verify(ctx context.Context, token string) (*Claims, error) {
+	return ParseToken(token, AccessToken, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.key(ctx, kid)
+	})
+}
+
+func (v *Verifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	key, ok := v.keys[kid]
+	age := time.Since(v.fetchedAt)
+	if ok && age < jwksRefreshInterval {
+		v.mu.Unlock()
+		return key, nil
+	}
+	if !ok && age < jwksMinRefetch && v.fetching == nil {
+		v.mu.Unlock()
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	done := v.refresh(ctx)
+	v.mu.Unlock()
+
+	if ok {
+		return key, nil
+	}
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	key, ok = v.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	return key, nil
+}
+
+// refresh starts a fetch unless one is already running and returns a channel
+// closed once it finishes. v.mu must be held.
+func (v *Verifier) refresh(ctx context.Context) <-chan struct{} {
+	if v.fetching != nil {
+		return v.fetching
+	}
+
+	done := make(chan struct{})
+	v.fetching = done
+	v.fetchedAt = time.Now()
+
+	// The fetch is shared, so it must not end with the request that started it
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		defer close(done)
+		keys, err := v.fetch(ctx)
+
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		v.fetching = nil
+		if err != nil {
+			// Keep verifying with the keys we have if the account service is briefly unreachable
+			slog.ErrorContext(ctx, "Error fetching JWKS", "err", err)
+			return
+		}
+		v.keys = keys
+	}()
+	return done
+}
+
+func (v *Verifier) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := v.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			slog.Warn("failed to close response body", "err", err)
+		}
+	}(res.Body)
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected JWKS response: %s", res.Status)
+	}
+
+	var set JWKS
+	if err := json.NewDecoder(res.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, jwk := range set.Keys {
+		key, err := jwk.PublicKey()
+		if err != nil {
+			slog.Warn("Skipping JWK", "kid", jwk.Kid, "err", err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	return keys, nil
+}
@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestSigner(t *testing.T) *Signer {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := NewSigner(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func testClaims(tokenType TokenType, expiresIn time.Duration) *Claims {
+	now := time.Now()
+	return &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    Issuer,
+			Subject:   "account1",
+			Audience:  jwt.ClaimStrings{Audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
+		},
+		TokenType: tokenType,
+		Roles:     []Role{RoleCustomer},
+	}
+}
+
+func TestVerifierVerify(t *testing.T) {
+	signer := newTestSigner(t)
+	other := newTestSigner(t)
+	server := httptest.NewServer(JWKSHandler(signer))
+	defer server.Close()
+
+	// forged names the published key but is signed with another one
+	forged := &Signer{key: other.key, method: other.method, kid: signer.kid}
+
+	tests := []struct {
+		name   string
+		signer *Signer
+		claims *Claims
+		valid  bool
+	}{
+		{name: "valid", signer: signer, claims: testClaims(AccessToken, time.Minute), valid: true},
+		{name: "expired", signer: signer, claims: testClaims(AccessToken, -time.Minute)},
+		{name: "refresh token", signer: signer, claims: testClaims(RefreshToken, time.Minute)},
+		{name: "unknown key", signer: other, claims: testClaims(AccessToken, time.Minute)},
+		{name: "wrong key", signer: forged, claims: testClaims(AccessToken, time.Minute)},
+	}
+
+	verifier := NewVerifier(server.URL)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := tt.signer.Sign(tt.claims)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			claims, err := verifier.Verify(context.Background(), token)
+			if tt.valid {
+				if err != nil {
+					t.Fatalf("Verify: %v", err)
+				}
+				if claims.Subject != "account1" {
+					t.Errorf("subject = %q, want account1", claims.Subject)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Verify error = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestVerifierRefetchDoesNotBlockKnownKeys(t *testing.T) {
+	signer := newTestSigner(t)
+	jwks := JWKSHandler(signer)
+
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		<-release
+		jwks.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	var releaseOnce sync.Once
+	unblock := func() { releaseOnce.Do(func() { close(release) }) }
+	defer unblock()
+
+	// Start with the signer's key cached but due for a refresh
+	verifier := NewVerifier(server.URL)
+	verifier.keys[signer.kid] = signer.key.Public()
+	verifier.fetchedAt = time.Now().Add(-jwksRefreshInterval)
+
+	token, err := signer.Sign(testClaims(AccessToken, time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		_, err := verifier.Verify(ctx, token)
+		cancel()
+		if err != nil {
+			t.Fatalf("Verify with a cached key during a refetch: %v", err)
+		}
+	}
+
+	// A token with an unknown key waits for the fetch in flight instead of starting another
+	unknown, err := newTestSigner(t).Sign(testClaims(AccessToken, time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := verifier.Verify(ctx, unknown); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Verify with an unknown key = %v, want it to wait for the fetch", err)
+	}
+
+	unblock()
+	if _, err := verifier.Verify(context.Background(), unknown); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Verify with an unknown key after the fetch = %v, want ErrInvalidToken", err)
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("fetched JWKS %d times, want 1", n)
+	}
+}
@@ -2,49 +2,82 @@ package catalog
 
 import (
 	"context"
+	"github.com/fabian-emmanuel/go-ms/auth"
 	"github.com/fabian-emmanuel/go-ms/catalog/pb"
+	"github.com/fabian-emmanuel/go-ms/errs"
+	"github.com/fabian-emmanuel/go-ms/healthcheck"
+	"github.com/fabian-emmanuel/go-ms/logging"
+	"github.com/fabian-emmanuel/go-ms/metrics"
+	"github.com/fabian-emmanuel/go-ms/money"
+	"github.com/fabian-emmanuel/go-ms/mtls"
+	"github.com/fabian-emmanuel/go-ms/tracing"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
 type Client struct {
 	conn    *grpc.ClientConn
 	service pb.CatalogServiceClient
+	// stopReload stops watching the TLS files once the client is closed
+	stopReload context.CancelFunc
 }
 
-func NewClient(url string) (*Client, error) {
-	conn, err := grpc.NewClient(url, grpc.WithTransportCredentials(insecure.NewCredentials()))
+func NewClient(url string, creds mtls.Config) (*Client, error) {
+	reloadCtx, stopReload := context.WithCancel(context.Background())
+	transport, err := creds.DialOption(reloadCtx)
 	if err != nil {
+		stopReload()
+		return nil, err
+	}
+	conn, err := grpc.NewClient(url,
+		transport,
+		tracing.DialOption(),
+		grpc.WithChainUnaryInterceptor(
+			// Outermost, so the other interceptors still see gRPC status errors
+			errs.UnaryClientInterceptor(),
+			metrics.UnaryClientInterceptor(),
+			logging.UnaryClientInterceptor(),
+			auth.UnaryClientInterceptor(),
+		),
+	)
+	if err != nil {
+		stopReload()
 		return nil, err
 	}
 	client := pb.NewCatalogServiceClient(conn)
-	return &Client{conn, client}, nil
+	return &Client{conn, client, stopReload}, nil
+}
+
+// Health fails unless the catalog service reports itself SERVING.
+func (c *Client) Health(ctx context.Context) error {
+	return healthcheck.Check(ctx, c.conn, pb.CatalogService_ServiceDesc.ServiceName)
 }
 
 func (c *Client) Close() {
+	c.stopReload()
 	err := c.conn.Close()
 	if err != nil {
 		return
 	}
 }
 
-func (c *Client) CreateProduct(ctx context.Context, name, description string, price float64) (*Product, error) {
-	res, err := c.service.CreateProduct(ctx, &pb.CreateProductRequest{
+func (c *Client) CreateProduct(ctx context.Context, name, description string, price money.Money, prices []money.Money, stock uint32) (*Product, error) {
+	req := &pb.CreateProductRequest{
 		Name:        name,
 		Description: description,
-		Price:       price,
-	})
+		Price:       price.Proto(),
+		Stock:       stock,
+	}
+	for _, p := range prices {
+		req.Prices = append(req.Prices, p.Proto())
+	}
+
+	res, err := c.service.CreateProduct(ctx, req)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return &Product{
-		ID:          res.Product.Id,
-		Name:        res.Product.Name,
-		Description: res.Product.Description,
-		Price:       res.Product.Price,
-	}, err
+	return productFromProto(res.Product), err
 }
 
 func (c *Client) GetProduct(ctx context.Context, id string) (*Product, error) {
@@ -56,12 +89,7 @@ func (c *Client) GetProduct(ctx context.Context, id string) (*Product, error) {
 		return nil, err
 	}
 
-	return &Product{
-		ID:          res.Product.Id,
-		Name:        res.Product.Name,
-		Description: res.Product.Description,
-		Price:       res.Product.Price,
-	}, err
+	return productFromProto(res.Product), err
 }
 
 func (c *Client) GetProducts(ctx context.Context, skip, take uint64) ([]*Product, error) {
@@ -72,12 +100,7 @@ func (c *Client) GetProducts(ctx context.Context, skip, take uint64) ([]*Product
 
 	var products []*Product
 	for _, product := range res.Products {
-		products = append(products, &Product{
-			ID:          product.Id,
-			Name:        product.Name,
-			Description: product.Description,
-			Price:       product.Price,
-		})
+		products = append(products, productFromProto(product))
 	}
 
 	return products, err
@@ -91,12 +114,7 @@ func (c *Client) GetProductsByIds(ctx context.Context, ids []string, skip, take
 
 	var products []*Product
 	for _, product := range res.Products {
-		products = append(products, &Product{
-			ID:          product.Id,
-			Name:        product.Name,
-			Description: product.Description,
-			Price:       product.Price,
-		})
+		products = append(products, productFromProto(product))
 	}
 
 	return products, err
@@ -110,13 +128,64 @@ func (c *Client) SearchProducts(ctx context.Context, query string, skip, take ui
 
 	var products []*Product
 	for _, product := range res.Products {
-		products = append(products, &Product{
-			ID:          product.Id,
-			Name:        product.Name,
-			Description: product.Description,
-			Price:       product.Price,
-		})
+		products = append(products, productFromProto(product))
 	}
 
 	return products, err
 }
+
+func (c *Client) ListProducts(ctx context.Context, query ListProductsQuery) (*ProductPage, error) {
+	res, err := c.service.ListProducts(ctx, &pb.ListProductsRequest{
+		Query: query.Query,
+		First: uint32(query.First),
+		After: query.After,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page := &ProductPage{
+		Products:    []*Product{},
+		Cursors:     res.Cursors,
+		HasNextPage: res.HasNextPage,
+		TotalCount:  int(res.TotalCount),
+	}
+	for _, product := range res.Products {
+		page.Products = append(page.Products, productFromProto(product))
+	}
+	return page, nil
+}
+
+func (c *Client) ReserveStock(ctx context.Context, reservationId string, items []StockItem) error {
+	req := &pb.ReserveStockRequest{ReservationId: reservationId}
+	for _, item := range items {
+		req.Items = append(req.Items, &pb.StockItem{ProductId: item.ProductID, Quantity: item.Quantity})
+	}
+
+	_, err := c.service.ReserveStock(ctx, req)
+	return err
+}
+
+func (c *Client) ReleaseStock(ctx context.Context, reservationId string, productIds []string) error {
+	_, err := c.service.ReleaseStock(ctx, &pb.ReleaseStockRequest{ReservationId: reservationId, ProductIds: productIds})
+	return err
+}
+
+func (c *Client) CommitStock(ctx context.Context, reservationId string, productIds []string) error {
+	_, err := c.service.CommitStock(ctx, &pb.CommitStockRequest{ReservationId: reservationId, ProductIds: productIds})
+	return err
+}
+
+func productFromProto(p *pb.Product) *Product {
+	product := &Product{
+		ID:          p.Id,
+		Name:        p.Name,
+		Description: p.Description,
+		Price:       money.FromProto(p.Price),
+		Stock:       p.Stock,
+	}
+	for _, price := range p.Prices {
+		product.Prices = append(product.Prices, money.FromProto(price))
+	}
+	return product
+}
@@ -1,34 +1,102 @@
 package main
 
 import (
+	"context"
+	"github.com/fabian-emmanuel/go-ms/auth"
 	"github.com/fabian-emmanuel/go-ms/catalog"
+	"github.com/fabian-emmanuel/go-ms/events"
+	"github.com/fabian-emmanuel/go-ms/idempotency"
+	"github.com/fabian-emmanuel/go-ms/lifecycle"
+	"github.com/fabian-emmanuel/go-ms/logging"
+	"github.com/fabian-emmanuel/go-ms/metrics"
+	"github.com/fabian-emmanuel/go-ms/mtls"
+	"github.com/fabian-emmanuel/go-ms/tracing"
 	"github.com/kelseyhightower/envconfig"
 	"github.com/tinrab/retry"
-	"log"
+	"log/slog"
+	"os"
+	"sync"
 	"time"
 )
 
 type Config struct {
-	DatabaseUrl        string `envconfig:"DATABASE_URL"`
-	CatalogServicePort int    `envconfig:"CATALOG_SERVICE_PORT"`
+	DatabaseUrl        string        `envconfig:"DATABASE_URL"`
+	CatalogServicePort int           `envconfig:"CATALOG_SERVICE_PORT"`
+	JwksUrl            string        `envconfig:"JWKS_URL"`
+	MetricsPort        int           `envconfig:"METRICS_PORT"`
+	ShutdownTimeout    time.Duration `envconfig:"SHUTDOWN_TIMEOUT"`
+	events.Config
+	TLS     mtls.Config
+	Tracing tracing.Config
+	Log     logging.Config
 }
 
 func main() {
+	if err := run(); err != nil {
+		slog.Error("Exiting", "err", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
 	var config Config
 	if err := envconfig.Process("", &config); err != nil {
-		log.Fatalf("Failed to process env var: %s", err)
+		return err
+	}
+
+	logging.Setup("catalog", config.Log)
+
+	// Deferred first so spans from the rest of shutdown are still flushed
+	shutdownTracing, err := tracing.Setup(context.Background(), "catalog", config.Tracing)
+	if err != nil {
+		return err
 	}
+	defer shutdownTracing()
+
+	ctx, stop := lifecycle.SignalContext()
+	defer stop()
 
 	var repo catalog.Repository
 	retry.ForeverSleep(2*time.Second, func(_ int) (err error) {
-		_, err = catalog.NewElasticRepository(config.DatabaseUrl)
+		repo, err = catalog.NewElasticRepository(config.DatabaseUrl)
 		if err != nil {
-			log.Println(err)
+			slog.Error("Error connecting to Elasticsearch", "err", err)
 		}
 		return
 	})
+	defer repo.Close()
+
+	publisher, err := events.NewPublisher(config.Config)
+	if err != nil {
+		return err
+	}
+	defer publisher.Close()
+
+	// Workers outlive ctx so events written by draining requests still get relayed
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	defer workers.Wait()
+	defer stopWorkers()
+	workers.Add(2)
+	go func() {
+		defer workers.Done()
+		events.NewRelay(repo, publisher).Run(workerCtx)
+	}()
+	go func() {
+		defer workers.Done()
+		idempotency.RunJanitor(workerCtx, repo, time.Hour)
+	}()
+
+	if config.MetricsPort != 0 {
+		go func() {
+			slog.Info("Serving metrics", "port", config.MetricsPort)
+			if err := metrics.Serve(ctx, config.MetricsPort, config.ShutdownTimeout); err != nil {
+				slog.ErrorContext(ctx, "Error serving metrics", "err", err)
+			}
+		}()
+	}
 
-	log.Printf("Listening on port :%v...\n", config.CatalogServicePort)
+	slog.Info("Listening", "port", config.CatalogServicePort)
 	s := catalog.NewCatalogService(repo)
-	log.Fatal(catalog.ListenGRPC(s, config.CatalogServicePort))
+	return catalog.ListenGRPC(ctx, s, repo, auth.NewVerifier(config.JwksUrl), config.TLS, config.CatalogServicePort, config.ShutdownTimeout)
 }
@@ -0,0 +1,244 @@
+package catalog
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/fabian-emmanuel/go-ms/idempotency"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const idempotencyIndex = "idempotency_keys"
+
+type idempotencyDocument struct {
+	Method      string    `json:"method"`
+	Key         string    `json:"key"`
+	RequestHash []byte    `json:"request_hash"`
+	Response    []byte    `json:"response,omitempty"`
+	LockedUntil time.Time `json:"locked_until"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+type idempotencyGetResponse struct {
+	SeqNo       int                 `json:"_seq_no"`
+	PrimaryTerm int                 `json:"_primary_term"`
+	Source      idempotencyDocument `json:"_source"`
+}
+
+// idempotencyDocumentID hashes method and key into a URL-safe document ID.
+func idempotencyDocumentID(method, key string) string {
+	sum := sha256.Sum256([]byte(method + "\x00" + key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (r *elasticRepository) ReserveIdempotencyKey(ctx context.Context, rec idempotency.Record) (*idempotency.Record, bool, error) {
+	id := idempotencyDocumentID(rec.Method, rec.Key)
+	body, err := json.Marshal(idempotencyDocument{
+		Method:      rec.Method,
+		Key:         rec.Key,
+		RequestHash: rec.RequestHash,
+		LockedUntil: rec.LockedUntil,
+		ExpiresAt:   rec.ExpiresAt,
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to marshal idempotency key: %w", err)
+	}
+
+	// op_type=create fails with 409 if someone already holds the key
+	created, err := r.writeIdempotencyDocument(ctx, esapi.IndexRequest{
+		Index:      idempotencyIndex,
+		DocumentID: id,
+		Body:       bytes.NewReader(body),
+		OpType:     "create",
+	})
+	if err != nil || created {
+		return nil, created, err
+	}
+
+	doc, err := r.getIdempotencyDocument(ctx, id)
+	if err != nil {
+		return nil, false, err
+	}
+	if doc == nil {
+		return &idempotency.Record{RequestHash: rec.RequestHash}, false, nil
+	}
+
+	existing := doc.Source
+	now := time.Now().UTC()
+	abandoned := existing.Response == nil && existing.LockedUntil.Before(now) && bytes.Equal(existing.RequestHash, rec.RequestHash)
+	if existing.ExpiresAt.Before(now) || abandoned {
+		taken, err := r.writeIdempotencyDocument(ctx, esapi.IndexRequest{
+			Index:         idempotencyIndex,
+			DocumentID:    id,
+			Body:          bytes.NewReader(body),
+			IfSeqNo:       &doc.SeqNo,
+			IfPrimaryTerm: &doc.PrimaryTerm,
+		})
+		if err != nil || taken {
+			return nil, taken, err
+		}
+		return &idempotency.Record{RequestHash: rec.RequestHash}, false, nil
+	}
+
+	return &idempotency.Record{
+		Method:      existing.Method,
+		Key:         existing.Key,
+		RequestHash: existing.RequestHash,
+		Response:    existing.Response,
+		LockedUntil: existing.LockedUntil,
+		ExpiresAt:   existing.ExpiresAt,
+	}, false, nil
+}
+
+func (r *elasticRepository) CompleteIdempotencyKey(ctx context.Context, method, key string, response []byte) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"doc": map[string]interface{}{"response": response},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal idempotent response: %w", err)
+	}
+
+	req := esapi.UpdateRequest{
+		Index:      idempotencyIndex,
+		DocumentID: idempotencyDocumentID(method, key),
+		Body:       bytes.NewReader(body),
+	}
+
+	res, err := req.Do(ctx, r.client)
+	if err != nil {
+		return fmt.Errorf("failed to store idempotent response: %w", err)
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			slog.Warn("failed to close response body", "err", err)
+		}
+	}(res.Body)
+
+	if res.IsError() {
+		return fmt.Errorf("error storing idempotent response: %s", res.String())
+	}
+	return nil
+}
+
+func (r *elasticRepository) ReleaseIdempotencyKey(ctx context.Context, method, key string) error {
+	req := esapi.DeleteRequest{
+		Index:      idempotencyIndex,
+		DocumentID: idempotencyDocumentID(method, key),
+	}
+
+	res, err := req.Do(ctx, r.client)
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			slog.Warn("failed to close response body", "err", err)
+		}
+	}(res.Body)
+
+	if res.IsError() && res.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("error releasing idempotency key: %s", res.String())
+	}
+	return nil
+}
+
+func (r *elasticRepository) DeleteExpiredIdempotencyKeys(ctx context.Context) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"query": map[string]interface{}{
+			"range": map[string]interface{}{
+				"expires_at": map[string]interface{}{"lt": time.Now().UTC()},
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal query: %w", err)
+	}
+
+	ignoreUnavailable := true
+	req := esapi.DeleteByQueryRequest{
+		Index:             []string{idempotencyIndex},
+		Body:              bytes.NewReader(body),
+		Conflicts:         "proceed",
+		IgnoreUnavailable: &ignoreUnavailable,
+	}
+
+	res, err := req.Do(ctx, r.client)
+	if err != nil {
+		return fmt.Errorf("failed to delete expired idempotency keys: %w", err)
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			slog.Warn("failed to close response body", "err", err)
+		}
+	}(res.Body)
+
+	if res.IsError() {
+		return fmt.Errorf("error deleting expired idempotency keys: %s", res.String())
+	}
+	return nil
+}
+
+// writeIdempotencyDocument runs a conditional write and reports whether it won.
+// A 409 means another request got there first and is not an error.
+func (r *elasticRepository) writeIdempotencyDocument(ctx context.Context, req esapi.IndexRequest) (bool, error) {
+	res, err := req.Do(ctx, r.client)
+	if err != nil {
+		return false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			slog.Warn("failed to close response body", "err", err)
+		}
+	}(res.Body)
+
+	if res.StatusCode == http.StatusConflict {
+		return false, nil
+	}
+	if res.IsError() {
+		return false, fmt.Errorf("error reserving idempotency key: %s", res.String())
+	}
+	return true, nil
+}
+
+// getIdempotencyDocument returns nil if the document does not exist.
+func (r *elasticRepository) getIdempotencyDocument(ctx context.Context, id string) (*idempotencyGetResponse, error) {
+	req := esapi.GetRequest{
+		Index:      idempotencyIndex,
+		DocumentID: id,
+	}
+
+	res, err := req.Do(ctx, r.client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			slog.Warn("failed to close response body", "err", err)
+		}
+	}(res.Body)
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if res.IsError() {
+		return nil, fmt.Errorf("error getting idempotency key: %s", res.String())
+	}
+
+	var doc idempotencyGetResponse
+	if err := json.NewDecoder(res.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode idempotency key: %w", err)
+	}
+	return &doc, nil
+}
@@ -0,0 +1,66 @@
+package catalog
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/fabian-emmanuel/go-ms/errs"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+	// pitKeepAlive is how long a point in time survives between two pages.
+	pitKeepAlive = "1m"
+)
+
+var (
+	ErrInvalidCursor = errs.InvalidArgument("invalid cursor")
+	ErrCursorExpired = errs.FailedPrecondition("cursor expired, start again from the first page")
+)
+
+// ListProductsQuery pages through every product, or only those matching Query
+// ranked by relevance.
+type ListProductsQuery struct {
+	Query string
+	First int
+	After string
+}
+
+// ProductPage is one page of products. Cursors holds the cursor of each
+// product, in the same order.
+type ProductPage struct {
+	Products    []*Product
+	Cursors     []string
+	HasNextPage bool
+	TotalCount  int
+}
+
+// productCursor pins a page to the point in time its listing started from, so
+// products indexed or removed meanwhile do not shift later pages. SearchAfter
+// holds the sort values of the product it points at.
+type productCursor struct {
+	PIT         string            `json:"p"`
+	Query       string            `json:"q,omitempty"`
+	SearchAfter []json.RawMessage `json:"s"`
+}
+
+func newProductCursor(pit, query string, searchAfter []json.RawMessage) string {
+	body, _ := json.Marshal(productCursor{PIT: pit, Query: query, SearchAfter: searchAfter})
+	return base64.RawURLEncoding.EncodeToString(body)
+}
+
+func parseProductCursor(s, query string) (*productCursor, error) {
+	body, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c productCursor
+	if err := json.Unmarshal(body, &c); err != nil || c.PIT == "" || len(c.SearchAfter) == 0 {
+		return nil, ErrInvalidCursor
+	}
+	if c.Query != query {
+		return nil, fmt.Errorf("%w: cursor was issued for query %q", ErrInvalidCursor, c.Query)
+	}
+	return &c, nil
+}
@@ -0,0 +1,11 @@
+package catalog
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var productsCreated = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "products_created_total",
+	Help: "Products added to the catalog.",
+})
@@ -0,0 +1,62 @@
+#!/bin/sh
+# Moves the catalog index from float prices to exact minor-unit money. The old
+# index maps price as a float, which rejects the {amount, currency} objects the
+# catalog writes now, so its documents are copied into catalog_v2 with an
+# explicit mapping and "catalog" becomes an alias of the new index. Prices are
+# taken to be US dollars, as for orders in order/migrations/002_exact_money.sql.
+#
+# Stop the catalog service first and run this once:
+#
+#	catalog/migrations/002_exact_money.sh http://catalog_db:9200
+set -eu
+
+url=${1:-${DATABASE_URL:-http://localhost:9200}}
+
+es() {
+	method=$1
+	path=$2
+	shift 2
+	curl -sS --fail-with-body -X "$method" -H 'Content-Type: application/json' "$url$path" "$@"
+	echo
+}
+
+if curl -sS --fail "$url/_alias/catalog" >/dev/null 2>&1; then
+	echo "catalog is already an alias, nothing to do"
+	exit 0
+fi
+
+es PUT /catalog_v2 -d '{
+  "mappings": {
+    "properties": {
+      "price": {
+        "properties": {
+          "amount": {"type": "long"},
+          "currency": {"type": "keyword"}
+        }
+      },
+      "prices": {
+        "properties": {
+          "amount": {"type": "long"},
+          "currency": {"type": "keyword"}
+        }
+      }
+    }
+  }
+}'
+
+es POST '/_reindex?wait_for_completion=true&refresh=true' -d '{
+  "source": {"index": "catalog"},
+  "dest": {"index": "catalog_v2"},
+  "script": {
+    "lang": "painless",
+    "source": "if (ctx._source.price instanceof Number) { ctx._source.price = [\"amount\": Math.round(((Number) ctx._source.price).doubleValue() * 100), \"currency\": \"USD\"]; }"
+  }
+}'
+
+# Swap the old index for the alias in one step
+es POST /_aliases -d '{
+  "actions": [
+    {"remove_index": {"index": "catalog"}},
+    {"add": {"index": "catalog_v2", "alias": "catalog"}}
+  ]
+}'
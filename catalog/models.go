@@ -1,22 +1,112 @@
 package catalog
 
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/fabian-emmanuel/go-ms/events"
+	"github.com/fabian-emmanuel/go-ms/money"
+	"math"
+)
+
+// legacyPriceCurrency is the currency of prices indexed as plain floats, before
+// prices became money.
+const legacyPriceCurrency = "USD"
+
 type Product struct {
-	ID          string  `json:"id"`
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
+	ID          string        `json:"id"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Price       money.Money   `json:"price"`
+	Prices      []money.Money `json:"prices"`
+	Stock       uint32        `json:"stock"`
 }
 
 type productDocument struct {
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
+	Name         string            `json:"name"`
+	Description  string            `json:"description"`
+	Price        money.Money       `json:"price"`
+	Prices       []money.Money     `json:"prices"`
+	Stock        uint32            `json:"stock"`
+	Reservations map[string]uint32 `json:"reservations,omitempty"`
+	// Outbox holds events not yet published. Elasticsearch has no transactions,
+	// so events ride along in the document write that produced them.
+	Outbox []events.Event `json:"outbox,omitempty"`
+}
+
+// UnmarshalJSON also reads documents not yet reindexed by
+// migrations/002_exact_money.sh, whose price is a float in major units of
+// legacyPriceCurrency.
+func (d *productDocument) UnmarshalJSON(b []byte) error {
+	type document productDocument
+	var doc struct {
+		document
+		Price json.RawMessage `json:"price"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+	*d = productDocument(doc.document)
+
+	price := bytes.TrimSpace(doc.Price)
+	switch {
+	case len(price) == 0 || bytes.Equal(price, []byte("null")):
+		d.Price = money.Money{}
+	case price[0] == '{':
+		return json.Unmarshal(price, &d.Price)
+	default:
+		var amount float64
+		if err := json.Unmarshal(price, &amount); err != nil {
+			return fmt.Errorf("invalid price %s: %w", price, err)
+		}
+		scale := math.Pow10(money.MinorUnits(legacyPriceCurrency))
+		d.Price = money.Money{Amount: int64(math.Round(amount * scale)), Currency: legacyPriceCurrency}
+	}
+	return nil
+}
+
+func (d *productDocument) toProduct(id string) *Product {
+	return &Product{
+		ID:          id,
+		Name:        d.Name,
+		Description: d.Description,
+		Price:       d.Price,
+		Prices:      d.Prices,
+		Stock:       d.available(),
+	}
+}
+
+// PriceIn returns the product's list price in the given currency, if one was set.
+func (p *Product) PriceIn(currency string) (money.Money, bool) {
+	for _, price := range p.Prices {
+		if price.Currency == currency {
+			return price, true
+		}
+	}
+	if p.Price.Currency == currency {
+		return p.Price, true
+	}
+	return money.Money{}, false
+}
+
+type getResponse struct {
+	ID          string          `json:"_id"`
+	Found       bool            `json:"found"`
+	SeqNo       int             `json:"_seq_no"`
+	PrimaryTerm int             `json:"_primary_term"`
+	Source      productDocument `json:"_source"`
 }
 
 type searchResponse struct {
-	Hits struct {
+	PitID string `json:"pit_id"`
+	Hits  struct {
+		Total struct {
+			Value int `json:"value"`
+		} `json:"total"`
 		Hits []struct {
-			Source Product `json:"_source"`
+			ID     string            `json:"_id"`
+			Source productDocument   `json:"_source"`
+			Sort   []json.RawMessage `json:"sort"`
 		} `json:"hits"`
 	} `json:"hits"`
 }
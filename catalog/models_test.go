@@ -0,0 +1,42 @@
+package catalog
+
+import (
+	"encoding/json"
+	"github.com/fabian-emmanuel/go-ms/money"
+	"testing"
+)
+
+func TestProductDocumentPrice(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want money.Money
+	}{
+		{"money", `{"name":"a","price":{"amount":1999,"currency":"EUR"}}`, money.Money{Amount: 1999, Currency: "EUR"}},
+		{"legacy float", `{"name":"a","price":19.99}`, money.Money{Amount: 1999, Currency: "USD"}},
+		{"legacy whole", `{"name":"a","price":5}`, money.Money{Amount: 500, Currency: "USD"}},
+		{"missing", `{"name":"a"}`, money.Money{}},
+		{"null", `{"name":"a","price":null}`, money.Money{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d productDocument
+			if err := json.Unmarshal([]byte(tt.json), &d); err != nil {
+				t.Fatal(err)
+			}
+			if d.Price != tt.want {
+				t.Errorf("price = %+v, want %+v", d.Price, tt.want)
+			}
+			if d.Name != "a" {
+				t.Errorf("name = %q, want %q", d.Name, "a")
+			}
+		})
+	}
+}
+
+func TestProductDocumentInvalidPrice(t *testing.T) {
+	var d productDocument
+	if err := json.Unmarshal([]byte(`{"price":"abc"}`), &d); err == nil {
+		t.Error("expected an error for a string price")
+	}
+}
@@ -4,28 +4,48 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	elastic "github.com/elastic/go-elasticsearch/v8"
 	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/fabian-emmanuel/go-ms/events"
+	"github.com/fabian-emmanuel/go-ms/idempotency"
+	"github.com/fabian-emmanuel/go-ms/metrics"
+	"go.opentelemetry.io/otel"
 	"io"
-	"log"
+	"log/slog"
+	"net/http"
 )
 
 type Repository interface {
 	Close()
+	Ping(ctx context.Context) error
 	CreateProduct(ctx context.Context, product Product) error
 	GetProductById(ctx context.Context, id string) (*Product, error)
 	GetProducts(ctx context.Context, skip, take uint64) ([]*Product, error)
 	GetProductsWithIds(ctx context.Context, ids []string, skip, take uint64) ([]*Product, error)
 	SearchProducts(ctx context.Context, query string, skip, take uint64) ([]*Product, error)
+	ListProducts(ctx context.Context, query ListProductsQuery) (*ProductPage, error)
+	ReserveStock(ctx context.Context, reservationId string, items []StockItem) error
+	ReleaseStock(ctx context.Context, reservationId string, productIds []string) error
+	CommitStock(ctx context.Context, reservationId string, productIds []string) error
+	events.Outbox
+	idempotency.Store
 }
 
+// maxConcurrencyRetries bounds how often a stock update is retried after losing an optimistic concurrency race.
+const maxConcurrencyRetries = 5
+
 type elasticRepository struct {
 	client *elastic.Client
 }
 
 func NewElasticRepository(url string) (Repository, error) {
-	client, err := elastic.NewClient(elastic.Config{Addresses: []string{url}})
+	client, err := elastic.NewClient(elastic.Config{
+		Addresses:       []string{url},
+		Transport:       metrics.InstrumentTransport("elasticsearch", nil),
+		Instrumentation: elastic.NewOpenTelemetryInstrumentation(otel.GetTracerProvider(), false),
+	})
 
 	if err != nil {
 		return nil, err
@@ -35,14 +55,40 @@ func NewElasticRepository(url string) (Repository, error) {
 }
 
 func (r *elasticRepository) Close() {
-	log.Println("Elasticsearch client does not require explicit closing")
+	slog.Debug("Elasticsearch client does not require explicit closing")
+}
+
+func (r *elasticRepository) Ping(ctx context.Context) error {
+	res, err := r.client.Ping(r.client.Ping.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			slog.Warn("failed to close response body", "err", err)
+		}
+	}(res.Body)
+
+	if res.IsError() {
+		return fmt.Errorf("elasticsearch ping failed: %s", res.Status())
+	}
+	return nil
 }
 
 func (r *elasticRepository) CreateProduct(ctx context.Context, product Product) error {
+	event, err := events.New(events.ProductCreated, product.ID, product)
+	if err != nil {
+		return err
+	}
+
 	body, err := json.Marshal(productDocument{
 		Name:        product.Name,
 		Description: product.Description,
 		Price:       product.Price,
+		Prices:      product.Prices,
+		Stock:       product.Stock,
+		Outbox:      []events.Event{event},
 	})
 
 	if err != nil {
@@ -64,7 +110,7 @@ func (r *elasticRepository) CreateProduct(ctx context.Context, product Product)
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
 		if err != nil {
-			log.Printf("failed to close response body")
+			slog.Warn("failed to close response body", "err", err)
 		}
 	}(res.Body)
 
@@ -76,31 +122,11 @@ func (r *elasticRepository) CreateProduct(ctx context.Context, product Product)
 }
 
 func (r *elasticRepository) GetProductById(ctx context.Context, id string) (*Product, error) {
-	req := esapi.GetRequest{
-		Index:      "catalog",
-		DocumentID: id,
-	}
-
-	res, err := req.Do(ctx, r.client)
+	doc, err := r.getProductDocument(ctx, id)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get product: %w", err)
-	}
-
-	defer func(Body io.ReadCloser) {
-		err := res.Body.Close()
-		if err != nil {
-			log.Printf("failed to close response body")
-		}
-	}(res.Body)
-
-	if res.IsError() {
-		return nil, fmt.Errorf("error getting product: %s", res.String())
-	}
-	var product Product
-	if err := json.NewDecoder(res.Body).Decode(&product); err != nil {
-		return nil, fmt.Errorf("failed to decode product: %w", err)
+		return nil, err
 	}
-	return &product, err
+	return doc.Source.toProduct(doc.ID), nil
 }
 
 func (r *elasticRepository) GetProducts(ctx context.Context, skip, take uint64) ([]*Product, error) {
@@ -133,7 +159,7 @@ func (r *elasticRepository) GetProducts(ctx context.Context, skip, take uint64)
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
 		if err != nil {
-			log.Printf("failed to close response body")
+			slog.Warn("failed to close response body", "err", err)
 		}
 	}(res.Body)
 
@@ -151,8 +177,7 @@ func (r *elasticRepository) GetProducts(ctx context.Context, skip, take uint64)
 	// Convert the results to []*Product
 	var products []*Product
 	for _, hit := range sr.Hits.Hits {
-		product := hit.Source
-		products = append(products, &product)
+		products = append(products, hit.Source.toProduct(hit.ID))
 	}
 
 	return products, err
@@ -190,7 +215,7 @@ func (r *elasticRepository) GetProductsWithIds(ctx context.Context, ids []string
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
 		if err != nil {
-			log.Printf("failed to close response body")
+			slog.Warn("failed to close response body", "err", err)
 		}
 	}(res.Body)
 
@@ -208,7 +233,7 @@ func (r *elasticRepository) GetProductsWithIds(ctx context.Context, ids []string
 	// Convert results to []*Product
 	var products []*Product
 	for _, hit := range sr.Hits.Hits {
-		products = append(products, &hit.Source)
+		products = append(products, hit.Source.toProduct(hit.ID))
 	}
 
 	return products, err
@@ -247,7 +272,7 @@ func (r *elasticRepository) SearchProducts(ctx context.Context, query string, sk
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
 		if err != nil {
-			log.Printf("failed to close response body")
+			slog.Warn("failed to close response body", "err", err)
 		}
 	}(res.Body)
 
@@ -265,8 +290,399 @@ func (r *elasticRepository) SearchProducts(ctx context.Context, query string, sk
 	// Convert results to []*Product
 	var products []*Product
 	for _, hit := range sr.Hits.Hits {
-		products = append(products, &hit.Source)
+		products = append(products, hit.Source.toProduct(hit.ID))
 	}
 
 	return products, err
 }
+
+// ListProducts pages through products with search_after on a point in time.
+// The first page opens the point in time and the last one closes it; cursors
+// of a listing left unfinished expire after pitKeepAlive.
+func (r *elasticRepository) ListProducts(ctx context.Context, query ListProductsQuery) (*ProductPage, error) {
+	var cursor *productCursor
+	var pit string
+	if query.After != "" {
+		var err error
+		if cursor, err = parseProductCursor(query.After, query.Query); err != nil {
+			return nil, err
+		}
+		pit = cursor.PIT
+	} else {
+		var err error
+		if pit, err = r.openPointInTime(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	// _shard_doc is the cheapest unique tiebreaker within a point in time
+	search := map[string]interface{}{
+		"size":             query.First + 1,
+		"track_total_hits": true,
+		"pit":              map[string]interface{}{"id": pit, "keep_alive": pitKeepAlive},
+		"query":            map[string]interface{}{"match_all": map[string]interface{}{}},
+		"sort":             []interface{}{map[string]interface{}{"_shard_doc": "asc"}},
+	}
+	if query.Query != "" {
+		search["query"] = map[string]interface{}{
+			"multi_match": map[string]interface{}{
+				"query":  query.Query,
+				"fields": []string{"name", "description"},
+			},
+		}
+		search["sort"] = []interface{}{
+			map[string]interface{}{"_score": "desc"},
+			map[string]interface{}{"_shard_doc": "asc"},
+		}
+	}
+	if cursor != nil {
+		search["search_after"] = cursor.SearchAfter
+	}
+
+	body, err := json.Marshal(search)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal search query: %w", err)
+	}
+
+	// Searches over a point in time must not name the index
+	req := esapi.SearchRequest{Body: bytes.NewReader(body)}
+	res, err := req.Do(ctx, r.client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list products: %w", err)
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			slog.Warn("failed to close response body", "err", err)
+		}
+	}(res.Body)
+
+	if res.StatusCode == http.StatusNotFound && cursor != nil {
+		return nil, ErrCursorExpired
+	}
+	if res.IsError() {
+		return nil, fmt.Errorf("error listing products: %s", res.String())
+	}
+
+	var sr searchResponse
+	if err := json.NewDecoder(res.Body).Decode(&sr); err != nil {
+		return nil, fmt.Errorf("failed to decode search response: %w", err)
+	}
+	// Elasticsearch may hand back a new id for the same point in time
+	if sr.PitID != "" {
+		pit = sr.PitID
+	}
+
+	page := &ProductPage{Products: []*Product{}, Cursors: []string{}, TotalCount: sr.Hits.Total.Value}
+	hits := sr.Hits.Hits
+	if len(hits) > query.First {
+		page.HasNextPage = true
+		hits = hits[:query.First]
+	}
+	for _, hit := range hits {
+		page.Products = append(page.Products, hit.Source.toProduct(hit.ID))
+		page.Cursors = append(page.Cursors, newProductCursor(pit, query.Query, hit.Sort))
+	}
+
+	if !page.HasNextPage {
+		r.closePointInTime(ctx, pit)
+	}
+
+	return page, nil
+}
+
+func (r *elasticRepository) openPointInTime(ctx context.Context) (string, error) {
+	req := esapi.OpenPointInTimeRequest{
+		Index:     []string{"catalog"},
+		KeepAlive: pitKeepAlive,
+	}
+	res, err := req.Do(ctx, r.client)
+	if err != nil {
+		return "", fmt.Errorf("failed to open point in time: %w", err)
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			slog.Warn("failed to close response body", "err", err)
+		}
+	}(res.Body)
+
+	if res.IsError() {
+		return "", fmt.Errorf("error opening point in time: %s", res.String())
+	}
+
+	var pit struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&pit); err != nil {
+		return "", fmt.Errorf("failed to decode point in time: %w", err)
+	}
+	return pit.ID, nil
+}
+
+// closePointInTime frees a point in time early. Failures only cost resources
+// until the keep-alive runs out, so they are logged rather than returned.
+func (r *elasticRepository) closePointInTime(ctx context.Context, pit string) {
+	body, err := json.Marshal(map[string]string{"id": pit})
+	if err != nil {
+		slog.WarnContext(ctx, "failed to marshal point in time", "err", err)
+		return
+	}
+
+	req := esapi.ClosePointInTimeRequest{Body: bytes.NewReader(body)}
+	res, err := req.Do(ctx, r.client)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to close point in time", "err", err)
+		return
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			slog.Warn("failed to close response body", "err", err)
+		}
+	}(res.Body)
+
+	if res.IsError() && res.StatusCode != http.StatusNotFound {
+		slog.WarnContext(ctx, "error closing point in time", "status", res.Status())
+	}
+}
+
+// ReserveStock holds quantities for a reservation on every product, or on none of them.
+// Products that cannot cover their quantity are reported together in an *OutOfStockError.
+func (r *elasticRepository) ReserveStock(ctx context.Context, reservationId string, items []StockItem) error {
+	var reserved []string
+	var outOfStock []string
+
+	for _, item := range items {
+		err := r.updateProductDocument(ctx, item.ProductID, func(doc *productDocument) error {
+			if _, ok := doc.Reservations[reservationId]; ok {
+				return nil
+			}
+			if doc.available() < item.Quantity {
+				return &OutOfStockError{ProductIDs: []string{item.ProductID}}
+			}
+			if doc.Reservations == nil {
+				doc.Reservations = map[string]uint32{}
+			}
+			doc.Reservations[reservationId] = item.Quantity
+			return nil
+		})
+
+		var outOfStockErr *OutOfStockError
+		switch {
+		case errors.As(err, &outOfStockErr):
+			outOfStock = append(outOfStock, item.ProductID)
+		case err != nil:
+			r.releaseQuietly(ctx, reservationId, reserved)
+			return err
+		default:
+			reserved = append(reserved, item.ProductID)
+		}
+	}
+
+	if len(outOfStock) > 0 {
+		r.releaseQuietly(ctx, reservationId, reserved)
+		return &OutOfStockError{ProductIDs: outOfStock}
+	}
+
+	return nil
+}
+
+func (r *elasticRepository) ReleaseStock(ctx context.Context, reservationId string, productIds []string) error {
+	for _, id := range productIds {
+		err := r.updateProductDocument(ctx, id, func(doc *productDocument) error {
+			delete(doc.Reservations, reservationId)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CommitStock turns a reservation into a permanent deduction from stock on hand.
+func (r *elasticRepository) CommitStock(ctx context.Context, reservationId string, productIds []string) error {
+	for _, id := range productIds {
+		err := r.updateProductDocument(ctx, id, func(doc *productDocument) error {
+			quantity, ok := doc.Reservations[reservationId]
+			if !ok {
+				return nil
+			}
+			doc.Stock -= min(quantity, doc.Stock)
+			delete(doc.Reservations, reservationId)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DispatchPendingEvents publishes the events held in product documents, oldest
+// first, and removes each one from its document once published.
+func (r *elasticRepository) DispatchPendingEvents(ctx context.Context, limit int, publish func(ctx context.Context, e events.Event) error) (int, error) {
+	query := map[string]interface{}{
+		"size": limit,
+		"query": map[string]interface{}{
+			"exists": map[string]interface{}{"field": "outbox.id"},
+		},
+		"sort": []interface{}{
+			map[string]interface{}{"outbox.occurred_at": map[string]interface{}{"order": "asc", "mode": "min", "unmapped_type": "date"}},
+		},
+	}
+
+	body, err := json.Marshal(query)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal query: %w", err)
+	}
+
+	// The index only exists once the first product is created
+	ignoreUnavailable := true
+	req := esapi.SearchRequest{
+		Index:             []string{"catalog"},
+		Body:              bytes.NewReader(body),
+		IgnoreUnavailable: &ignoreUnavailable,
+	}
+
+	res, err := req.Do(ctx, r.client)
+	if err != nil {
+		return 0, fmt.Errorf("failed to search pending events: %w", err)
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			slog.Warn("failed to close response body", "err", err)
+		}
+	}(res.Body)
+
+	if res.IsError() {
+		return 0, fmt.Errorf("error searching pending events: %s", res.String())
+	}
+
+	var sr searchResponse
+	if err := json.NewDecoder(res.Body).Decode(&sr); err != nil {
+		return 0, fmt.Errorf("failed to decode search response: %w", err)
+	}
+
+	dispatched := 0
+	for _, hit := range sr.Hits.Hits {
+		published := map[string]bool{}
+		var publishErr error
+		for _, e := range hit.Source.Outbox {
+			if publishErr = publish(ctx, e); publishErr != nil {
+				break
+			}
+			published[e.ID] = true
+		}
+
+		if len(published) > 0 {
+			err := r.updateProductDocument(ctx, hit.ID, func(doc *productDocument) error {
+				var pending []events.Event
+				for _, e := range doc.Outbox {
+					if !published[e.ID] {
+						pending = append(pending, e)
+					}
+				}
+				doc.Outbox = pending
+				return nil
+			})
+			if err != nil {
+				return dispatched, err
+			}
+			dispatched += len(published)
+		}
+
+		if publishErr != nil {
+			return dispatched, publishErr
+		}
+	}
+
+	return dispatched, nil
+}
+
+func (r *elasticRepository) releaseQuietly(ctx context.Context, reservationId string, productIds []string) {
+	if err := r.ReleaseStock(ctx, reservationId, productIds); err != nil {
+		slog.WarnContext(ctx, "failed to release stock reservation", "reservation_id", reservationId, "err", err)
+	}
+}
+
+func (r *elasticRepository) getProductDocument(ctx context.Context, id string) (*getResponse, error) {
+	req := esapi.GetRequest{
+		Index:      "catalog",
+		DocumentID: id,
+	}
+
+	res, err := req.Do(ctx, r.client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get product: %w", err)
+	}
+
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			slog.Warn("failed to close response body", "err", err)
+		}
+	}(res.Body)
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, ErrProductNotFound.WithResource("product", id)
+	}
+
+	if res.IsError() {
+		return nil, fmt.Errorf("error getting product: %s", res.String())
+	}
+
+	var doc getResponse
+	if err := json.NewDecoder(res.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode product: %w", err)
+	}
+	return &doc, nil
+}
+
+// updateProductDocument applies update to the latest version of a product and writes it back
+// only if nobody else wrote in between (if_seq_no/if_primary_term), retrying on conflicts.
+func (r *elasticRepository) updateProductDocument(ctx context.Context, id string, update func(doc *productDocument) error) error {
+	for attempt := 0; attempt < maxConcurrencyRetries; attempt++ {
+		doc, err := r.getProductDocument(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if err := update(&doc.Source); err != nil {
+			return err
+		}
+
+		body, err := json.Marshal(doc.Source)
+		if err != nil {
+			return fmt.Errorf("failed to marshal product: %w", err)
+		}
+
+		req := esapi.IndexRequest{
+			Index:         "catalog",
+			DocumentID:    id,
+			Body:          bytes.NewReader(body),
+			IfSeqNo:       &doc.SeqNo,
+			IfPrimaryTerm: &doc.PrimaryTerm,
+		}
+
+		res, err := req.Do(ctx, r.client)
+		if err != nil {
+			return fmt.Errorf("failed to update product: %w", err)
+		}
+		conflict := res.StatusCode == http.StatusConflict
+		if !conflict && res.IsError() {
+			err = fmt.Errorf("error updating product: %s", res.String())
+		}
+		if err := res.Body.Close(); err != nil {
+			slog.Warn("failed to close response body", "err", err)
+		}
+
+		if !conflict {
+			return err
+		}
+	}
+
+	return fmt.Errorf("%w: %s", ErrConcurrentUpdate, id)
+}
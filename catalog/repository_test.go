@@ -0,0 +1,285 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	elastic "github.com/elastic/go-elasticsearch/v8"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeElastic serves product documents for gets and seq_no checked index
+// requests, the only calls stock updates make.
+type fakeElastic struct {
+	mu     sync.Mutex
+	docs   map[string]productDocument
+	seqNos map[string]int
+	// interfere lands other writes on a product between a read and the next
+	// write to it, one per write, so that write loses on its seq_no
+	interfere map[string][]func(doc *productDocument)
+}
+
+func newFakeElastic(stock map[string]uint32) *fakeElastic {
+	f := &fakeElastic{
+		docs:      map[string]productDocument{},
+		seqNos:    map[string]int{},
+		interfere: map[string][]func(doc *productDocument){},
+	}
+	for id, quantity := range stock {
+		f.docs[id] = productDocument{Name: id, Stock: quantity}
+	}
+	return f
+}
+
+func (f *fakeElastic) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Elastic-Product", "Elasticsearch")
+	w.Header().Set("Content-Type", "application/json")
+
+	id, ok := strings.CutPrefix(r.URL.Path, "/catalog/_doc/")
+	if !ok {
+		http.Error(w, `{"error":"unsupported"}`, http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	doc, found := f.docs[id]
+
+	switch r.Method {
+	case http.MethodGet:
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(map[string]any{"_id": id, "found": false})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(getResponse{ID: id, Found: true, SeqNo: f.seqNos[id], PrimaryTerm: 1, Source: doc})
+
+	case http.MethodPut, http.MethodPost:
+		if pending := f.interfere[id]; len(pending) > 0 {
+			pending[0](&doc)
+			f.interfere[id] = pending[1:]
+			f.docs[id] = doc
+			f.seqNos[id]++
+		}
+		if seqNo := r.URL.Query().Get("if_seq_no"); seqNo != strconv.Itoa(f.seqNos[id]) {
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte(`{"error":{"type":"version_conflict_engine_exception"},"status":409}`))
+			return
+		}
+
+		var written productDocument
+		if err := json.NewDecoder(r.Body).Decode(&written); err != nil {
+			http.Error(w, `{"error":"bad document"}`, http.StatusBadRequest)
+			return
+		}
+		f.docs[id] = written
+		f.seqNos[id]++
+		_, _ = w.Write([]byte(`{"result":"updated"}`))
+
+	default:
+		http.Error(w, `{"error":"unsupported"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeElastic) doc(id string) productDocument {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.docs[id]
+}
+
+func newTestRepository(t *testing.T, f *fakeElastic) *elasticRepository {
+	t.Helper()
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+
+	client, err := elastic.NewClient(elastic.Config{Addresses: []string{server.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &elasticRepository{client}
+}
+
+// reservedBy is an interfering write that reserves quantity for another order.
+func reservedBy(reservationId string, quantity uint32) func(doc *productDocument) {
+	return func(doc *productDocument) {
+		if doc.Reservations == nil {
+			doc.Reservations = map[string]uint32{}
+		}
+		doc.Reservations[reservationId] = quantity
+	}
+}
+
+// touched is an interfering write that changes nothing but the seq_no.
+func touched(*productDocument) {}
+
+func TestReserveStock(t *testing.T) {
+	tests := []struct {
+		name      string
+		stock     map[string]uint32
+		existing  map[string]uint32
+		interfere map[string][]func(doc *productDocument)
+		items     []StockItem
+		// wantErr is matched with errors.Is, wantOutOfStock against the *OutOfStockError
+		wantErr        error
+		wantOutOfStock []string
+		// wantReserved is what "r1" holds on each product afterwards
+		wantReserved map[string]uint32
+	}{
+		{
+			name:         "all available",
+			stock:        map[string]uint32{"p1": 5, "p2": 5},
+			items:        []StockItem{{"p1", 2}, {"p2", 5}},
+			wantReserved: map[string]uint32{"p1": 2, "p2": 5},
+		},
+		{
+			name:           "partial shortage reserves nothing",
+			stock:          map[string]uint32{"p1": 5, "p2": 1, "p3": 0},
+			items:          []StockItem{{"p1", 2}, {"p2", 2}, {"p3", 1}},
+			wantErr:        ErrOutOfStock,
+			wantOutOfStock: []string{"p2", "p3"},
+			wantReserved:   map[string]uint32{},
+		},
+		{
+			name:           "shortage behind other reservations",
+			stock:          map[string]uint32{"p1": 5},
+			existing:       map[string]uint32{"p1": 4},
+			items:          []StockItem{{"p1", 2}},
+			wantErr:        ErrOutOfStock,
+			wantOutOfStock: []string{"p1"},
+			wantReserved:   map[string]uint32{},
+		},
+		{
+			name:         "seq_no conflict is retried",
+			stock:        map[string]uint32{"p1": 5, "p2": 5},
+			interfere:    map[string][]func(doc *productDocument){"p2": {touched, touched}},
+			items:        []StockItem{{"p1", 2}, {"p2", 3}},
+			wantReserved: map[string]uint32{"p1": 2, "p2": 3},
+		},
+		{
+			name:           "retry sees a reservation that won the race",
+			stock:          map[string]uint32{"p1": 5, "p2": 5},
+			interfere:      map[string][]func(doc *productDocument){"p2": {reservedBy("other", 4)}},
+			items:          []StockItem{{"p1", 2}, {"p2", 3}},
+			wantErr:        ErrOutOfStock,
+			wantOutOfStock: []string{"p2"},
+			wantReserved:   map[string]uint32{},
+		},
+		{
+			name:  "conflicts beyond the retry limit",
+			stock: map[string]uint32{"p1": 5, "p2": 5},
+			interfere: map[string][]func(doc *productDocument){
+				"p2": slices.Repeat([]func(doc *productDocument){touched}, maxConcurrencyRetries),
+			},
+			items:        []StockItem{{"p1", 2}, {"p2", 3}},
+			wantErr:      ErrConcurrentUpdate,
+			wantReserved: map[string]uint32{},
+		},
+		{
+			name:         "unknown product",
+			stock:        map[string]uint32{"p1": 5},
+			items:        []StockItem{{"p1", 2}, {"missing", 1}},
+			wantErr:      ErrProductNotFound,
+			wantReserved: map[string]uint32{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeElastic(tt.stock)
+			for id, quantity := range tt.existing {
+				doc := f.docs[id]
+				reservedBy("other", quantity)(&doc)
+				f.docs[id] = doc
+			}
+			for id, writes := range tt.interfere {
+				f.interfere[id] = writes
+			}
+			r := newTestRepository(t, f)
+
+			err := r.ReserveStock(context.Background(), "r1", tt.items)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("ReserveStock: %v", err)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ReserveStock error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantOutOfStock != nil {
+				var outOfStock *OutOfStockError
+				if !errors.As(err, &outOfStock) || !reflect.DeepEqual(outOfStock.ProductIDs, tt.wantOutOfStock) {
+					t.Errorf("ReserveStock error = %v, want out of stock %v", err, tt.wantOutOfStock)
+				}
+			}
+
+			for id := range tt.stock {
+				doc := f.doc(id)
+				quantity, ok := doc.Reservations["r1"]
+				if want, wantOk := tt.wantReserved[id]; ok != wantOk || quantity != want {
+					t.Errorf("%s reserved %d for r1, want %d", id, quantity, want)
+				}
+				if want, ok := tt.existing[id]; ok && doc.Reservations["other"] != want {
+					t.Errorf("%s reserved %d for other, want %d", id, doc.Reservations["other"], want)
+				}
+			}
+		})
+	}
+}
+
+func TestStockUpdatesAreIdempotent(t *testing.T) {
+	f := newFakeElastic(map[string]uint32{"p1": 5, "p2": 5})
+	r := newTestRepository(t, f)
+	ctx := context.Background()
+	items := []StockItem{{"p1", 2}, {"p2", 3}}
+
+	check := func(step string, wantStock, wantReserved map[string]uint32) {
+		t.Helper()
+		for id, want := range wantStock {
+			doc := f.doc(id)
+			if doc.Stock != want {
+				t.Errorf("after %s: %s stock = %d, want %d", step, id, doc.Stock, want)
+			}
+			if got := doc.Reservations["r1"]; got != wantReserved[id] {
+				t.Errorf("after %s: %s reserved %d for r1, want %d", step, id, got, wantReserved[id])
+			}
+		}
+	}
+
+	for _, step := range []string{"reserve", "re-reserve"} {
+		if err := r.ReserveStock(ctx, "r1", items); err != nil {
+			t.Fatalf("%s: %v", step, err)
+		}
+		check(step, map[string]uint32{"p1": 5, "p2": 5}, map[string]uint32{"p1": 2, "p2": 3})
+	}
+
+	// A re-reserve with other quantities keeps the original hold
+	if err := r.ReserveStock(ctx, "r1", []StockItem{{"p1", 5}}); err != nil {
+		t.Fatalf("re-reserve with other quantities: %v", err)
+	}
+	check("re-reserve with other quantities", map[string]uint32{"p1": 5}, map[string]uint32{"p1": 2})
+
+	for _, step := range []string{"release", "re-release"} {
+		if err := r.ReleaseStock(ctx, "r1", []string{"p1", "p2"}); err != nil {
+			t.Fatalf("%s: %v", step, err)
+		}
+		check(step, map[string]uint32{"p1": 5, "p2": 5}, map[string]uint32{})
+	}
+
+	if err := r.ReserveStock(ctx, "r2", items); err != nil {
+		t.Fatalf("reserve r2: %v", err)
+	}
+	for _, step := range []string{"commit", "re-commit"} {
+		if err := r.CommitStock(ctx, "r2", []string{"p1", "p2"}); err != nil {
+			t.Fatalf("%s: %v", step, err)
+		}
+		check(step, map[string]uint32{"p1": 3, "p2": 2}, map[string]uint32{})
+		if _, ok := f.doc("p1").Reservations["r2"]; ok {
+			t.Errorf("after %s: r2 still holds p1", step)
+		}
+	}
+}
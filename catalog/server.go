@@ -1,14 +1,28 @@
-//go:generate protoc --go_out=./pb --go-grpc_out=./pb catalog.proto
+//go:generate protoc -I . -I .. --go_out=./pb --go-grpc_out=./pb catalog.proto
 
 package catalog
 
 import (
 	"context"
 	"fmt"
+	"github.com/fabian-emmanuel/go-ms/auth"
 	"github.com/fabian-emmanuel/go-ms/catalog/pb"
+	"github.com/fabian-emmanuel/go-ms/errs"
+	"github.com/fabian-emmanuel/go-ms/healthcheck"
+	"github.com/fabian-emmanuel/go-ms/idempotency"
+	"github.com/fabian-emmanuel/go-ms/lifecycle"
+	"github.com/fabian-emmanuel/go-ms/logging"
+	"github.com/fabian-emmanuel/go-ms/metrics"
+	"github.com/fabian-emmanuel/go-ms/money"
+	"github.com/fabian-emmanuel/go-ms/mtls"
+	"github.com/fabian-emmanuel/go-ms/tracing"
+	"github.com/fabian-emmanuel/go-ms/validation"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
+	"log/slog"
+	"maps"
 	"net"
+	"time"
 )
 
 type grpcServer struct {
@@ -16,29 +30,84 @@ type grpcServer struct {
 	pb.UnimplementedCatalogServiceServer
 }
 
-func ListenGRPC(s Service, port int) error {
+// permissions are what callers need for the RPCs that are not open to everyone.
+var permissions = map[string]auth.Permission{
+	pb.CatalogService_CreateProduct_FullMethodName: auth.PermCreateProducts,
+}
+
+// stockMethods are only for the order service. Over mutual TLS its certificate
+// identifies it; otherwise it calls with a service account token.
+var stockMethods = []string{
+	pb.CatalogService_ReserveStock_FullMethodName,
+	pb.CatalogService_ReleaseStock_FullMethodName,
+	pb.CatalogService_CommitStock_FullMethodName,
+}
+
+// guardStock returns the permissions and peer checks that keep stockMethods to
+// the order service.
+func guardStock(mutualTLS bool) (map[string]auth.Permission, map[string][]string) {
+	required := maps.Clone(permissions)
+	peers := map[string][]string{}
+	for _, method := range stockMethods {
+		if mutualTLS {
+			peers[method] = []string{"order"}
+		} else {
+			required[method] = auth.PermManageStock
+		}
+	}
+	return required, peers
+}
+
+func ListenGRPC(ctx context.Context, s Service, r Repository, verifier auth.TokenVerifier, creds mtls.Config, port int, shutdownTimeout time.Duration) error {
+	transport, err := creds.ServerOption(ctx)
+	if err != nil {
+		return err
+	}
+
+	required, peers := guardStock(creds.MutualTLS())
+	interceptors := []grpc.UnaryServerInterceptor{
+		logging.UnaryServerInterceptor(),
+		metrics.UnaryServerInterceptor(),
+		errs.UnaryServerInterceptor(),
+	}
+	if creds.MutualTLS() {
+		interceptors = append(interceptors, mtls.RequireMethodPeers(peers))
+	} else {
+		slog.Warn("Mutual TLS is not configured, stock RPCs are only guarded by the stock permission")
+	}
+	interceptors = append(interceptors,
+		auth.UnaryServerInterceptor(verifier, required),
+		validation.UnaryServerInterceptor(validators),
+		idempotency.UnaryServerInterceptor(r, idempotency.DefaultTTL, nil, pb.CatalogService_CreateProduct_FullMethodName),
+	)
+
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
 	if err != nil {
 		return err
 	}
-	serv := grpc.NewServer()
+	serv := grpc.NewServer(
+		transport,
+		tracing.ServerOption(),
+		grpc.ChainUnaryInterceptor(interceptors...),
+	)
+	h := healthcheck.Register(ctx, serv, pb.CatalogService_ServiceDesc.ServiceName, r.Ping)
 	pb.RegisterCatalogServiceServer(serv, &grpcServer{s, pb.UnimplementedCatalogServiceServer{}})
 	reflection.Register(serv)
-	return serv.Serve(lis)
+	return lifecycle.ServeGRPC(ctx, serv, lis, h, shutdownTimeout)
 }
 
 func (s *grpcServer) CreateProduct(ctx context.Context, req *pb.CreateProductRequest) (*pb.CreateProductResponse, error) {
-	product, err := s.service.CreateProduct(ctx, req.Name, req.Description, req.Price)
+	var prices []money.Money
+	for _, p := range req.Prices {
+		prices = append(prices, money.FromProto(p))
+	}
+
+	product, err := s.service.CreateProduct(ctx, req.Name, req.Description, money.FromProto(req.Price), prices, req.Stock)
 	if err != nil {
 		return nil, err
 	}
 
-	return &pb.CreateProductResponse{Product: &pb.Product{
-		Id:          product.ID,
-		Name:        product.Name,
-		Description: product.Description,
-		Price:       product.Price,
-	}}, err
+	return &pb.CreateProductResponse{Product: productToProto(product)}, err
 }
 
 func (s *grpcServer) GetProduct(ctx context.Context, req *pb.GetProductRequest) (*pb.GetProductResponse, error) {
@@ -47,12 +116,7 @@ func (s *grpcServer) GetProduct(ctx context.Context, req *pb.GetProductRequest)
 		return nil, err
 	}
 
-	return &pb.GetProductResponse{Product: &pb.Product{
-		Id:          product.ID,
-		Name:        product.Name,
-		Description: product.Description,
-		Price:       product.Price,
-	}}, err
+	return &pb.GetProductResponse{Product: productToProto(product)}, err
 }
 
 func (s *grpcServer) GetProducts(ctx context.Context, req *pb.GetProductsRequest) (*pb.GetProductsResponse, error) {
@@ -63,12 +127,7 @@ func (s *grpcServer) GetProducts(ctx context.Context, req *pb.GetProductsRequest
 
 	var products []*pb.Product
 	for _, product := range response {
-		products = append(products, &pb.Product{
-			Id:          product.ID,
-			Name:        product.Name,
-			Description: product.Description,
-			Price:       product.Price,
-		})
+		products = append(products, productToProto(product))
 	}
 	return &pb.GetProductsResponse{Products: products}, err
 }
@@ -81,12 +140,7 @@ func (s *grpcServer) GetProductsWithIds(ctx context.Context, req *pb.GetProducts
 
 	var products []*pb.Product
 	for _, product := range response {
-		products = append(products, &pb.Product{
-			Id:          product.ID,
-			Name:        product.Name,
-			Description: product.Description,
-			Price:       product.Price,
-		})
+		products = append(products, productToProto(product))
 	}
 	return &pb.GetProductsResponse{Products: products}, err
 
@@ -100,12 +154,66 @@ func (s *grpcServer) SearchProducts(ctx context.Context, req *pb.SearchProductsR
 
 	var products []*pb.Product
 	for _, product := range response {
-		products = append(products, &pb.Product{
-			Id:          product.ID,
-			Name:        product.Name,
-			Description: product.Description,
-			Price:       product.Price,
-		})
+		products = append(products, productToProto(product))
 	}
 	return &pb.GetProductsResponse{Products: products}, err
 }
+
+func (s *grpcServer) ListProducts(ctx context.Context, req *pb.ListProductsRequest) (*pb.ListProductsResponse, error) {
+	page, err := s.service.ListProducts(ctx, ListProductsQuery{Query: req.Query, First: int(req.First), After: req.After})
+	if err != nil {
+		return nil, err
+	}
+
+	res := &pb.ListProductsResponse{
+		Cursors:     page.Cursors,
+		HasNextPage: page.HasNextPage,
+		TotalCount:  uint64(page.TotalCount),
+	}
+	for _, product := range page.Products {
+		res.Products = append(res.Products, productToProto(product))
+	}
+	return res, nil
+}
+
+func (s *grpcServer) ReserveStock(ctx context.Context, req *pb.ReserveStockRequest) (*pb.ReserveStockResponse, error) {
+	var items []StockItem
+	for _, item := range req.Items {
+		items = append(items, StockItem{ProductID: item.ProductId, Quantity: item.Quantity})
+	}
+
+	err := s.service.ReserveStock(ctx, req.ReservationId, items)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.ReserveStockResponse{}, nil
+}
+
+func (s *grpcServer) ReleaseStock(ctx context.Context, req *pb.ReleaseStockRequest) (*pb.ReleaseStockResponse, error) {
+	if err := s.service.ReleaseStock(ctx, req.ReservationId, req.ProductIds); err != nil {
+		return nil, err
+	}
+	return &pb.ReleaseStockResponse{}, nil
+}
+
+func (s *grpcServer) CommitStock(ctx context.Context, req *pb.CommitStockRequest) (*pb.CommitStockResponse, error) {
+	if err := s.service.CommitStock(ctx, req.ReservationId, req.ProductIds); err != nil {
+		return nil, err
+	}
+	return &pb.CommitStockResponse{}, nil
+}
+
+func productToProto(product *Product) *pb.Product {
+	p := &pb.Product{
+		Id:          product.ID,
+		Name:        product.Name,
+		Description: product.Description,
+		Price:       product.Price.Proto(),
+		Stock:       product.Stock,
+	}
+	for _, price := range product.Prices {
+		p.Prices = append(p.Prices, price.Proto())
+	}
+	return p
+}
@@ -0,0 +1,107 @@
+package catalog
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"github.com/fabian-emmanuel/go-ms/auth"
+	"github.com/fabian-emmanuel/go-ms/catalog/pb"
+	"github.com/fabian-emmanuel/go-ms/mtls"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+// roleVerifier accepts any token and treats it as the name of the caller's role.
+type roleVerifier struct{}
+
+func (roleVerifier) Verify(_ context.Context, token string) (*auth.Claims, error) {
+	role, err := auth.ParseRole(token)
+	if err != nil {
+		return nil, errors.New("unknown role")
+	}
+	claims := &auth.Claims{Roles: []auth.Role{role}}
+	claims.Subject = "account1"
+	return claims, nil
+}
+
+func okHandler(context.Context, any) (any, error) { return "ok", nil }
+
+func TestGuardStockWithoutMutualTLS(t *testing.T) {
+	required, _ := guardStock(false)
+	interceptor := auth.UnaryServerInterceptor(roleVerifier{}, required)
+
+	tests := []struct {
+		name string
+		role auth.Role
+		want codes.Code
+	}{
+		{name: "anonymous", want: codes.Unauthenticated},
+		{name: "customer", role: auth.RoleCustomer, want: codes.PermissionDenied},
+		{name: "merchant", role: auth.RoleMerchant, want: codes.PermissionDenied},
+		{name: "service", role: auth.RoleService, want: codes.OK},
+	}
+
+	for _, method := range stockMethods {
+		for _, tt := range tests {
+			t.Run(method+"/"+tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				if tt.role != "" {
+					ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(auth.MetadataKey, "Bearer "+string(tt.role)))
+				}
+
+				_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, okHandler)
+				if got := status.Code(err); got != tt.want {
+					t.Errorf("code = %s, want %s (err %v)", got, tt.want, err)
+				}
+			})
+		}
+	}
+}
+
+func peerContext(commonName string) context.Context {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: commonName}}
+	return peer.NewContext(context.Background(), &peer.Peer{
+		AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}},
+	})
+}
+
+func TestGuardStockWithMutualTLS(t *testing.T) {
+	required, peers := guardStock(true)
+	checkPeer := mtls.RequireMethodPeers(peers)
+	authorize := auth.UnaryServerInterceptor(roleVerifier{}, required)
+	call := func(ctx context.Context, method string) error {
+		info := &grpc.UnaryServerInfo{FullMethod: method}
+		_, err := checkPeer(ctx, nil, info, func(ctx context.Context, req any) (any, error) {
+			return authorize(ctx, req, info, okHandler)
+		})
+		return err
+	}
+
+	tests := []struct {
+		name   string
+		peer   string
+		method string
+		want   codes.Code
+	}{
+		{name: "order reserving stock", peer: "order", method: pb.CatalogService_ReserveStock_FullMethodName, want: codes.OK},
+		{name: "order committing stock", peer: "order", method: pb.CatalogService_CommitStock_FullMethodName, want: codes.OK},
+		{name: "graphql reserving stock", peer: "graphql", method: pb.CatalogService_ReserveStock_FullMethodName, want: codes.PermissionDenied},
+		{name: "graphql releasing stock", peer: "graphql", method: pb.CatalogService_ReleaseStock_FullMethodName, want: codes.PermissionDenied},
+		{name: "graphql getting products", peer: "graphql", method: pb.CatalogService_GetProducts_FullMethodName, want: codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := status.Code(call(peerContext(tt.peer), tt.method)); got != tt.want {
+				t.Errorf("code = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,15 +2,21 @@ package catalog
 
 import (
 	"context"
+	"fmt"
+	"github.com/fabian-emmanuel/go-ms/money"
 	"github.com/segmentio/ksuid"
 )
 
 type Service interface {
-	CreateProduct(ctx context.Context, name, description string, price float64) (*Product, error)
+	CreateProduct(ctx context.Context, name, description string, price money.Money, prices []money.Money, stock uint32) (*Product, error)
 	GetProductById(ctx context.Context, id string) (*Product, error)
 	GetProducts(ctx context.Context, skip, take uint64) ([]*Product, error)
 	GetProductsWithIds(ctx context.Context, ids []string, skip, take uint64) ([]*Product, error)
 	SearchProducts(ctx context.Context, query string, skip, take uint64) ([]*Product, error)
+	ListProducts(ctx context.Context, query ListProductsQuery) (*ProductPage, error)
+	ReserveStock(ctx context.Context, reservationId string, items []StockItem) error
+	ReleaseStock(ctx context.Context, reservationId string, productIds []string) error
+	CommitStock(ctx context.Context, reservationId string, productIds []string) error
 }
 
 type catalogService struct {
@@ -21,21 +27,58 @@ func NewCatalogService(repository Repository) Service {
 	return &catalogService{repository}
 }
 
-func (s *catalogService) CreateProduct(ctx context.Context, name, description string, price float64) (*Product, error) {
+func (s *catalogService) CreateProduct(ctx context.Context, name, description string, price money.Money, prices []money.Money, stock uint32) (*Product, error) {
+	price, err := validatePrice(price)
+	if err != nil {
+		return nil, err
+	}
+
+	// The base price is always listed; extra prices add other currencies
+	seen := map[string]money.Money{price.Currency: price}
+	productPrices := []money.Money{price}
+	for _, p := range prices {
+		p, err := validatePrice(p)
+		if err != nil {
+			return nil, err
+		}
+		if existing, ok := seen[p.Currency]; ok {
+			if existing != p {
+				return nil, fmt.Errorf("%w: conflicting prices given in %s", money.ErrInvalidAmount, p.Currency)
+			}
+			continue
+		}
+		seen[p.Currency] = p
+		productPrices = append(productPrices, p)
+	}
+
 	product := &Product{
 		Name:        name,
 		Description: description,
 		ID:          ksuid.New().String(),
 		Price:       price,
+		Prices:      productPrices,
+		Stock:       stock,
 	}
 
 	if err := s.repository.CreateProduct(ctx, *product); err != nil {
 		return nil, err
 	}
+	productsCreated.Inc()
 
 	return product, nil
 }
 
+func validatePrice(price money.Money) (money.Money, error) {
+	price, err := money.New(price.Amount, price.Currency)
+	if err != nil {
+		return money.Money{}, err
+	}
+	if price.IsNegative() {
+		return money.Money{}, fmt.Errorf("%w: price must not be negative", money.ErrInvalidAmount)
+	}
+	return price, nil
+}
+
 func (s *catalogService) GetProductById(ctx context.Context, id string) (*Product, error) {
 	return s.repository.GetProductById(ctx, id)
 }
@@ -60,3 +103,38 @@ func (s *catalogService) SearchProducts(ctx context.Context, query string, skip,
 	}
 	return s.repository.SearchProducts(ctx, query, skip, take)
 }
+
+func (s *catalogService) ListProducts(ctx context.Context, query ListProductsQuery) (*ProductPage, error) {
+	if query.First <= 0 {
+		query.First = defaultPageSize
+	}
+	if query.First > maxPageSize {
+		query.First = maxPageSize
+	}
+	return s.repository.ListProducts(ctx, query)
+}
+
+func (s *catalogService) ReserveStock(ctx context.Context, reservationId string, items []StockItem) error {
+	if reservationId == "" {
+		return fmt.Errorf("%w: reservation id is required", ErrInvalidStockItems)
+	}
+	items, err := mergeStockItems(items)
+	if err != nil {
+		return err
+	}
+	return s.repository.ReserveStock(ctx, reservationId, items)
+}
+
+func (s *catalogService) ReleaseStock(ctx context.Context, reservationId string, productIds []string) error {
+	if reservationId == "" {
+		return fmt.Errorf("%w: reservation id is required", ErrInvalidStockItems)
+	}
+	return s.repository.ReleaseStock(ctx, reservationId, productIds)
+}
+
+func (s *catalogService) CommitStock(ctx context.Context, reservationId string, productIds []string) error {
+	if reservationId == "" {
+		return fmt.Errorf("%w: reservation id is required", ErrInvalidStockItems)
+	}
+	return s.repository.CommitStock(ctx, reservationId, productIds)
+}
@@ -0,0 +1,66 @@
+package catalog
+
+import (
+	"fmt"
+	"github.com/fabian-emmanuel/go-ms/errs"
+	"strings"
+)
+
+var (
+	ErrProductNotFound   = errs.NotFound("product not found")
+	ErrConcurrentUpdate  = errs.Aborted("product was updated concurrently, try again")
+	ErrInvalidStockItems = errs.InvalidArgument("invalid stock items")
+	ErrOutOfStock        = errs.FailedPrecondition("out of stock")
+)
+
+type StockItem struct {
+	ProductID string `json:"product_id"`
+	Quantity  uint32 `json:"quantity"`
+}
+
+// OutOfStockError lists every product that could not cover the requested quantity.
+type OutOfStockError struct {
+	ProductIDs []string
+}
+
+func (e *OutOfStockError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrOutOfStock, strings.Join(e.ProductIDs, ", "))
+}
+
+func (e *OutOfStockError) Unwrap() error {
+	return ErrOutOfStock
+}
+
+// available is the stock on hand that is not held by an open reservation.
+func (d *productDocument) available() uint32 {
+	var reserved uint32
+	for _, quantity := range d.Reservations {
+		reserved += quantity
+	}
+	if reserved >= d.Stock {
+		return 0
+	}
+	return d.Stock - reserved
+}
+
+// mergeStockItems validates items and folds repeated products into one line.
+func mergeStockItems(items []StockItem) ([]StockItem, error) {
+	if len(items) == 0 {
+		return nil, fmt.Errorf("%w: no items given", ErrInvalidStockItems)
+	}
+
+	var merged []StockItem
+	index := map[string]int{}
+	for _, item := range items {
+		if item.ProductID == "" || item.Quantity == 0 {
+			return nil, fmt.Errorf("%w: every item needs a product id and a positive quantity", ErrInvalidStockItems)
+		}
+		if i, ok := index[item.ProductID]; ok {
+			merged[i].Quantity += item.Quantity
+			continue
+		}
+		index[item.ProductID] = len(merged)
+		merged = append(merged, item)
+	}
+	return merged, nil
+}
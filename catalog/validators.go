@@ -0,0 +1,57 @@
+package catalog
+
+import (
+	"fmt"
+	"github.com/fabian-emmanuel/go-ms/catalog/pb"
+	"github.com/fabian-emmanuel/go-ms/validation"
+)
+
+const (
+	maxProductNameLength        = 200
+	maxProductDescriptionLength = 5000
+	maxSearchQueryLength        = 256
+)
+
+// validators check every CatalogService request before it reaches the service.
+var validators = map[string]validation.Validator{
+	pb.CatalogService_CreateProduct_FullMethodName: validation.For(func(r *pb.CreateProductRequest, v *validation.Violations) {
+		v.Length("name", r.Name, 1, maxProductNameLength)
+		v.MaxLength("description", r.Description, maxProductDescriptionLength)
+		v.Price("price", r.Price)
+		for i, p := range r.Prices {
+			v.Price(fmt.Sprintf("prices[%d]", i), p)
+		}
+	}),
+	pb.CatalogService_GetProduct_FullMethodName: validation.For(func(r *pb.GetProductRequest, v *validation.Violations) {
+		v.ID("id", r.Id)
+	}),
+	pb.CatalogService_GetProductsWithIds_FullMethodName: validation.For(func(r *pb.GetProductsWithIdsRequest, v *validation.Violations) {
+		v.IDs("ids", r.Ids)
+	}),
+	pb.CatalogService_SearchProducts_FullMethodName: validation.For(func(r *pb.SearchProductsRequest, v *validation.Violations) {
+		v.MaxLength("query", r.Query, maxSearchQueryLength)
+	}),
+	pb.CatalogService_ListProducts_FullMethodName: validation.For(func(r *pb.ListProductsRequest, v *validation.Violations) {
+		v.MaxLength("query", r.Query, maxSearchQueryLength)
+	}),
+	pb.CatalogService_ReserveStock_FullMethodName: validation.For(func(r *pb.ReserveStockRequest, v *validation.Violations) {
+		v.ID("reservationId", r.ReservationId)
+		if len(r.Items) == 0 {
+			v.Add("items", "must not be empty")
+		}
+		for i, item := range r.Items {
+			v.ID(fmt.Sprintf("items[%d].productId", i), item.ProductId)
+			if item.Quantity == 0 {
+				v.Add(fmt.Sprintf("items[%d].quantity", i), "must be greater than zero")
+			}
+		}
+	}),
+	pb.CatalogService_ReleaseStock_FullMethodName: validation.For(func(r *pb.ReleaseStockRequest, v *validation.Violations) {
+		v.ID("reservationId", r.ReservationId)
+		v.IDs("productIds", r.ProductIds)
+	}),
+	pb.CatalogService_CommitStock_FullMethodName: validation.For(func(r *pb.CommitStockRequest, v *validation.Violations) {
+		v.ID("reservationId", r.ReservationId)
+		v.IDs("productIds", r.ProductIds)
+	}),
+}
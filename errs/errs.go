@@ -0,0 +1,108 @@
+package errs
+
+import (
+	"errors"
+	"google.golang.org/grpc/codes"
+	"strings"
+)
+
+// Error is a domain error that maps onto a gRPC status. Sentinels are made
+// with the constructors below and may be wrapped with fmt.Errorf("%w: ...");
+// the status then carries the full wrapped message.
+type Error struct {
+	Code       codes.Code
+	Message    string
+	Violations []FieldViolation
+	Resource   *Resource
+}
+
+// FieldViolation describes one invalid field of a request.
+type FieldViolation struct {
+	Field       string
+	Description string
+}
+
+// Resource identifies the entity an error is about, such as the account that
+// was not found.
+type Resource struct {
+	Type string
+	Name string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Is matches errors with the same code and message, so a sentinel still
+// matches after WithResource, or after a round trip through a Client.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code && t.Message == e.Message
+}
+
+// WithResource returns a copy of e about the resource typ named name.
+func (e *Error) WithResource(typ, name string) *Error {
+	c := *e
+	c.Resource = &Resource{Type: typ, Name: name}
+	return &c
+}
+
+func New(code codes.Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+func NotFound(message string) *Error {
+	return New(codes.NotFound, message)
+}
+
+func InvalidArgument(message string) *Error {
+	return New(codes.InvalidArgument, message)
+}
+
+func AlreadyExists(message string) *Error {
+	return New(codes.AlreadyExists, message)
+}
+
+func FailedPrecondition(message string) *Error {
+	return New(codes.FailedPrecondition, message)
+}
+
+func Unavailable(message string) *Error {
+	return New(codes.Unavailable, message)
+}
+
+func Unauthenticated(message string) *Error {
+	return New(codes.Unauthenticated, message)
+}
+
+func PermissionDenied(message string) *Error {
+	return New(codes.PermissionDenied, message)
+}
+
+func Aborted(message string) *Error {
+	return New(codes.Aborted, message)
+}
+
+func ResourceExhausted(message string) *Error {
+	return New(codes.ResourceExhausted, message)
+}
+
+// Invalid reports every violation of a request at once.
+func Invalid(violations ...FieldViolation) *Error {
+	var descriptions []string
+	for _, v := range violations {
+		descriptions = append(descriptions, v.Field+": "+v.Description)
+	}
+	return &Error{
+		Code:       codes.InvalidArgument,
+		Message:    "invalid request: " + strings.Join(descriptions, "; "),
+		Violations: violations,
+	}
+}
+
+// As returns the domain error in err's chain, if there is one.
+func As(err error) (*Error, bool) {
+	var e *Error
+	ok := errors.As(err, &e)
+	return e, ok
+}
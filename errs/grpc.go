@@ -0,0 +1,141 @@
+package errs
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"io"
+	"log/slog"
+)
+
+// internalMessage is all a caller learns about an error that is not a domain
+// error; the details only go to the server's log.
+const internalMessage = "internal error"
+
+// ToStatus converts err for sending over gRPC. Status errors pass through
+// unchanged, domain errors keep their code and gain errdetails for their
+// violations and resource, and anything else becomes Internal.
+func ToStatus(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+
+	e, ok := As(err)
+	if !ok {
+		slog.ErrorContext(ctx, "Unexpected error", "err", err)
+		return status.Error(codes.Internal, internalMessage)
+	}
+
+	st := status.New(e.Code, err.Error())
+	var details []protoadapt.MessageV1
+	if len(e.Violations) > 0 {
+		br := &errdetails.BadRequest{}
+		for _, v := range e.Violations {
+			br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       v.Field,
+				Description: v.Description,
+			})
+		}
+		details = append(details, br)
+	}
+	if e.Resource != nil {
+		details = append(details, &errdetails.ResourceInfo{
+			ResourceType: e.Resource.Type,
+			ResourceName: e.Resource.Name,
+			Description:  e.Message,
+		})
+	}
+	if len(details) > 0 {
+		if withDetails, err := st.WithDetails(details...); err == nil {
+			st = withDetails
+		}
+	}
+	return st.Err()
+}
+
+// FromStatus turns a status error received from a service back into a domain
+// error. Codes without a domain meaning, such as Internal, are left as they
+// are.
+func FromStatus(err error) error {
+	st, ok := status.FromError(err)
+	if !ok || err == nil {
+		return err
+	}
+	switch st.Code() {
+	case codes.NotFound, codes.InvalidArgument, codes.AlreadyExists, codes.FailedPrecondition,
+		codes.Unavailable, codes.Unauthenticated, codes.PermissionDenied, codes.Aborted, codes.ResourceExhausted:
+	default:
+		return err
+	}
+
+	e := &Error{Code: st.Code(), Message: st.Message()}
+	for _, d := range st.Details() {
+		switch d := d.(type) {
+		case *errdetails.BadRequest:
+			for _, v := range d.FieldViolations {
+				e.Violations = append(e.Violations, FieldViolation{Field: v.Field, Description: v.Description})
+			}
+		case *errdetails.ResourceInfo:
+			e.Resource = &Resource{Type: d.ResourceType, Name: d.ResourceName}
+		}
+	}
+	return e
+}
+
+// UnaryServerInterceptor applies ToStatus to every error a handler returns.
+// It belongs after the logging and metrics interceptors so they see the
+// final code.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		resp, err := handler(ctx, req)
+		return resp, ToStatus(ctx, err)
+	}
+}
+
+// StreamServerInterceptor is UnaryServerInterceptor for streaming RPCs.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return ToStatus(ss.Context(), handler(srv, ss))
+	}
+}
+
+// UnaryClientInterceptor applies FromStatus to every error a call returns.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return FromStatus(invoker(ctx, method, req, reply, cc, opts...))
+	}
+}
+
+// StreamClientInterceptor applies FromStatus to the errors of opening a stream
+// and of receiving from it.
+func StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			return nil, FromStatus(err)
+		}
+		return &clientStream{cs}, nil
+	}
+}
+
+type clientStream struct {
+	grpc.ClientStream
+}
+
+func (s *clientStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err == nil || errors.Is(err, io.EOF) {
+		return err
+	}
+	return FromStatus(err)
+}
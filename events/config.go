@@ -0,0 +1,39 @@
+package events
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Config selects the publisher a service relays its outbox to. It is meant to
+// be embedded in a service's envconfig Config.
+type Config struct {
+	EventPublisher string `envconfig:"EVENT_PUBLISHER"`
+	EventsTopic    string `envconfig:"EVENTS_TOPIC"`
+	NatsUrl        string `envconfig:"NATS_URL"`
+	KafkaProxyUrl  string `envconfig:"KAFKA_PROXY_URL"`
+}
+
+// NewPublisher builds the publisher named by EVENT_PUBLISHER: "inprocess"
+// (the default), "nats" or "kafka". EVENTS_TOPIC defaults to "events" and is
+// the NATS subject prefix or the Kafka topic.
+func NewPublisher(c Config) (Publisher, error) {
+	topic := c.EventsTopic
+	if topic == "" {
+		topic = "events"
+	}
+
+	switch strings.ToLower(c.EventPublisher) {
+	case "", "inprocess":
+		return NewInProcessPublisher(), nil
+	case "nats":
+		return NewNatsPublisher(c.NatsUrl, topic)
+	case "kafka":
+		if c.KafkaProxyUrl == "" {
+			return nil, fmt.Errorf("KAFKA_PROXY_URL is required for the kafka publisher")
+		}
+		return NewKafkaPublisher(c.KafkaProxyUrl, topic), nil
+	default:
+		return nil, fmt.Errorf("unknown event publisher %q", c.EventPublisher)
+	}
+}
@@ -0,0 +1,53 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/segmentio/ksuid"
+	"time"
+)
+
+type Type string
+
+const (
+	OrderCreated       Type = "OrderCreated"
+	AccountCreated     Type = "AccountCreated"
+	AccountUpdated     Type = "AccountUpdated"
+	AccountDeactivated Type = "AccountDeactivated"
+	AccountDeleted     Type = "AccountDeleted"
+	AccountRoleChanged Type = "AccountRoleChanged"
+	ProductCreated     Type = "ProductCreated"
+)
+
+// Event is a domain event on its way out of a service. ID is fixed when the
+// event is recorded and survives redelivery, so consumers dedupe on it.
+type Event struct {
+	ID          string          `json:"id"`
+	Type        Type            `json:"type"`
+	AggregateID string          `json:"aggregate_id"`
+	Payload     json.RawMessage `json:"payload"`
+	OccurredAt  time.Time       `json:"occurred_at"`
+}
+
+func New(eventType Type, aggregateId string, payload any) (Event, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return Event{}, fmt.Errorf("failed to marshal %s payload: %w", eventType, err)
+	}
+
+	return Event{
+		ID:          ksuid.New().String(),
+		Type:        eventType,
+		AggregateID: aggregateId,
+		Payload:     body,
+		OccurredAt:  time.Now().UTC(),
+	}, nil
+}
+
+// Publisher delivers events to a broker. Publish returns only once the broker
+// has accepted the event; the relay retries anything that returned an error.
+type Publisher interface {
+	Publish(ctx context.Context, e Event) error
+	Close() error
+}
@@ -0,0 +1,81 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// dedupeWindow is how many recent event IDs the in-process bus remembers.
+const dedupeWindow = 10000
+
+type Handler func(ctx context.Context, e Event) error
+
+// InProcessPublisher hands events straight to handlers subscribed in the same
+// process. Redelivered events whose ID was seen recently are dropped.
+type InProcessPublisher struct {
+	mu       sync.RWMutex
+	handlers map[Type][]Handler
+	seen     map[string]struct{}
+	order    []string
+}
+
+func NewInProcessPublisher() *InProcessPublisher {
+	return &InProcessPublisher{
+		handlers: map[Type][]Handler{},
+		seen:     map[string]struct{}{},
+	}
+}
+
+func (p *InProcessPublisher) Subscribe(eventType Type, handler Handler) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.handlers[eventType] = append(p.handlers[eventType], handler)
+}
+
+func (p *InProcessPublisher) Publish(ctx context.Context, e Event) error {
+	if !p.markSeen(e.ID) {
+		return nil
+	}
+
+	p.mu.RLock()
+	handlers := p.handlers[e.Type]
+	p.mu.RUnlock()
+
+	for _, handler := range handlers {
+		if err := handler(ctx, e); err != nil {
+			p.forget(e.ID)
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *InProcessPublisher) Close() error {
+	return nil
+}
+
+// markSeen records id and reports whether it was new.
+func (p *InProcessPublisher) markSeen(id string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.seen[id]; ok {
+		slog.Debug("Dropping duplicate event", "event_id", id)
+		return false
+	}
+	p.seen[id] = struct{}{}
+	p.order = append(p.order, id)
+	if len(p.order) > dedupeWindow {
+		delete(p.seen, p.order[0])
+		p.order = p.order[1:]
+	}
+	return true
+}
+
+// forget lets a failed event be delivered again on the next attempt.
+func (p *InProcessPublisher) forget(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.seen, id)
+}
@@ -0,0 +1,91 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// kafkaPublisher produces to a Kafka topic through the REST proxy API (v2),
+// which Confluent REST Proxy and Redpanda's pandaproxy both serve. Records are
+// keyed by aggregate ID so events for one order, account or product stay in
+// order on a partition; consumers dedupe on the event ID inside the value.
+type kafkaPublisher struct {
+	client *http.Client
+	url    string
+}
+
+type kafkaRecords struct {
+	Records []kafkaRecord `json:"records"`
+}
+
+type kafkaRecord struct {
+	Key   string `json:"key"`
+	Value Event  `json:"value"`
+}
+
+type kafkaProduceResponse struct {
+	Offsets []struct {
+		ErrorCode *int   `json:"error_code"`
+		Error     string `json:"error"`
+	} `json:"offsets"`
+}
+
+func NewKafkaPublisher(proxyUrl, topic string) Publisher {
+	return &kafkaPublisher{
+		client: &http.Client{Timeout: 10 * time.Second},
+		url:    strings.TrimRight(proxyUrl, "/") + "/topics/" + topic,
+	}
+}
+
+func (p *kafkaPublisher) Publish(ctx context.Context, e Event) error {
+	body, err := json.Marshal(kafkaRecords{Records: []kafkaRecord{{Key: e.AggregateID, Value: e}}})
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build produce request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to publish event %s: %w", e.ID, err)
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			slog.Warn("failed to close response body", "err", err)
+		}
+	}(res.Body)
+
+	if res.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("error publishing event %s: %s: %s", e.ID, res.Status, msg)
+	}
+
+	var pr kafkaProduceResponse
+	if err := json.NewDecoder(res.Body).Decode(&pr); err != nil {
+		return fmt.Errorf("failed to decode produce response: %w", err)
+	}
+	for _, offset := range pr.Offsets {
+		if offset.ErrorCode != nil {
+			return fmt.Errorf("error publishing event %s: %s", e.ID, offset.Error)
+		}
+	}
+	return nil
+}
+
+func (p *kafkaPublisher) Close() error {
+	p.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,53 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// natsPublisher publishes to JetStream, which acknowledges every message once
+// it is stored and drops messages whose Nats-Msg-Id it has already seen within
+// the stream's duplicate window.
+type natsPublisher struct {
+	conn   *nats.Conn
+	js     jetstream.JetStream
+	prefix string
+}
+
+// NewNatsPublisher publishes each event on the subject "<prefix>.<type>". A
+// JetStream stream must already capture those subjects.
+func NewNatsPublisher(url, prefix string) (Publisher, error) {
+	conn, err := nats.Connect(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to nats::{%s}::%w", url, err)
+	}
+
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open jetstream: %w", err)
+	}
+
+	return &natsPublisher{conn: conn, js: js, prefix: prefix}, nil
+}
+
+func (p *natsPublisher) Publish(ctx context.Context, e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	msg := nats.NewMsg(p.prefix + "." + string(e.Type))
+	msg.Data = body
+	if _, err := p.js.PublishMsg(ctx, msg, jetstream.WithMsgID(e.ID)); err != nil {
+		return fmt.Errorf("failed to publish event %s: %w", e.ID, err)
+	}
+	return nil
+}
+
+func (p *natsPublisher) Close() error {
+	return p.conn.Drain()
+}
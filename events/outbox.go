@@ -0,0 +1,93 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/lib/pq"
+	"time"
+)
+
+// Outbox is a store of recorded events that have not been published yet.
+// DispatchPendingEvents passes up to limit of them, oldest first, to publish
+// and marks each one published once publish returns nil. It stops at the first
+// failure so later events are not published ahead of earlier ones.
+type Outbox interface {
+	DispatchPendingEvents(ctx context.Context, limit int, publish func(ctx context.Context, e Event) error) (int, error)
+}
+
+// WriteOutbox records e in the outbox table inside tx, so the event exists if
+// and only if the change that produced it commits.
+func WriteOutbox(ctx context.Context, tx *sql.Tx, e Event) error {
+	_, err := tx.ExecContext(ctx,
+		"INSERT INTO outbox(id, event_type, aggregate_id, payload, occurred_at) VALUES($1, $2, $3, $4, $5)",
+		e.ID, e.Type, e.AggregateID, []byte(e.Payload), e.OccurredAt)
+	if err != nil {
+		return fmt.Errorf("failed to write %s to outbox: %w", e.Type, err)
+	}
+	return nil
+}
+
+// DispatchPostgresOutbox implements Outbox.DispatchPendingEvents over the
+// outbox table. Rows are locked with SKIP LOCKED so several relays can share
+// one table without publishing the same row concurrently.
+func DispatchPostgresOutbox(ctx context.Context, db *sql.DB, limit int, publish func(ctx context.Context, e Event) error) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id, event_type, aggregate_id, payload, occurred_at FROM outbox
+		WHERE published_at IS NULL
+		ORDER BY occurred_at, id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED`, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	var pending []Event
+	for rows.Next() {
+		var e Event
+		var payload []byte
+		if err := rows.Scan(&e.ID, &e.Type, &e.AggregateID, &payload, &e.OccurredAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		e.Payload = payload
+		pending = append(pending, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var published []string
+	var publishErr error
+	for _, e := range pending {
+		if publishErr = publish(ctx, e); publishErr != nil {
+			_, err := tx.ExecContext(ctx,
+				"UPDATE outbox SET attempts = attempts + 1, last_error = $2 WHERE id = $1", e.ID, publishErr.Error())
+			if err != nil {
+				return 0, err
+			}
+			break
+		}
+		published = append(published, e.ID)
+	}
+
+	if len(published) > 0 {
+		_, err := tx.ExecContext(ctx,
+			"UPDATE outbox SET published_at = $2, last_error = '' WHERE id = ANY($1)", pq.Array(published), time.Now().UTC())
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(published), publishErr
+}
@@ -0,0 +1,45 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+const (
+	relayInterval  = time.Second
+	relayBatchSize = 100
+)
+
+// Relay moves events from an outbox to a publisher. Delivery is at least once:
+// an event published just before a crash is published again with the same ID.
+type Relay struct {
+	outbox    Outbox
+	publisher Publisher
+}
+
+func NewRelay(outbox Outbox, publisher Publisher) *Relay {
+	return &Relay{outbox: outbox, publisher: publisher}
+}
+
+// Run polls the outbox until ctx is cancelled, draining full batches back to back.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(relayInterval)
+	defer ticker.Stop()
+
+	for {
+		n, err := r.outbox.DispatchPendingEvents(ctx, relayBatchSize, r.publisher.Publish)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error relaying events", "err", err)
+		}
+		if err == nil && n == relayBatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/fabian-emmanuel/go-ms/auth"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// authMiddleware validates the bearer token, if any, and puts its principal on
+// the request context. Requests without a token continue anonymously; fields
+// that need a caller are guarded by the @auth directive.
+func authMiddleware(verifier *auth.Verifier, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_request"`)
+			http.Error(w, "malformed Authorization header", http.StatusUnauthorized)
+			return
+		}
+
+		token = strings.TrimSpace(token)
+		claims, err := verifier.Verify(r.Context(), token)
+		if err != nil {
+			slog.WarnContext(r.Context(), "Error verifying access token", "err", err)
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			http.Error(w, "invalid access token", http.StatusUnauthorized)
+			return
+		}
+
+		// The token travels on to the services so they can enforce their own permissions
+		ctx := auth.WithToken(auth.NewContext(r.Context(), auth.PrincipalFromClaims(claims)), token)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func authDirective(ctx context.Context, _ any, next graphql.Resolver, requires *Role) (any, error) {
+	p := auth.FromContext(ctx)
+	if p == nil {
+		return nil, errUnauthenticated(ctx)
+	}
+
+	role := RoleCustomer
+	if requires != nil {
+		role = *requires
+	}
+	if !p.HasRole(fromRole(role)) {
+		return nil, errForbidden(ctx)
+	}
+
+	return next(ctx)
+}
+
+// requireAccountAccess fails unless the caller owns the account or is an admin.
+func requireAccountAccess(ctx context.Context, accountId string) error {
+	p := auth.FromContext(ctx)
+	if p == nil {
+		return errUnauthenticated(ctx)
+	}
+	if !p.CanAccessAccount(accountId) {
+		return errForbidden(ctx)
+	}
+	return nil
+}
+
+func errUnauthenticated(ctx context.Context) error {
+	return authError(ctx, "authentication required", "UNAUTHENTICATED")
+}
+
+func errForbidden(ctx context.Context) error {
+	return authError(ctx, "not allowed", "FORBIDDEN")
+}
+
+func authError(ctx context.Context, message, code string) error {
+	return &gqlerror.Error{
+		Message:    message,
+		Path:       graphql.GetPath(ctx),
+		Extensions: map[string]any{"code": code},
+	}
+}
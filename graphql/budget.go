@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"github.com/fabian-emmanuel/go-ms/auth"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const apiKeyHeader = "X-API-Key"
+
+// budgetClient is who the cost of a request is charged to.
+type budgetClient struct {
+	id string
+	// budget overrides the default budget when positive.
+	budget int
+}
+
+type budgetClientKey struct{}
+
+func clientFromContext(ctx context.Context) budgetClient {
+	c, _ := ctx.Value(budgetClientKey{}).(budgetClient)
+	return c
+}
+
+// apiKeyMiddleware works out who a request's query cost is charged to: its API
+// key when it sends one, otherwise the signed-in account, otherwise the remote
+// IP. keys maps every known API key to its budget, or to 0 for the default.
+// It must run after authMiddleware has put the principal on the context.
+func apiKeyMiddleware(keys map[string]int, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var client budgetClient
+		if key := r.Header.Get(apiKeyHeader); key != "" {
+			budget, ok := keys[key]
+			if !ok {
+				http.Error(w, "invalid API key", http.StatusUnauthorized)
+				return
+			}
+			client = budgetClient{id: "key:" + key, budget: budget}
+		} else if p := auth.FromContext(r.Context()); p != nil {
+			client = budgetClient{id: "account:" + p.AccountId}
+		} else {
+			host, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				host = r.RemoteAddr
+			}
+			client = budgetClient{id: "ip:" + host}
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), budgetClientKey{}, client)))
+	})
+}
+
+type budgetStatus struct {
+	Limit         int `json:"limit"`
+	Remaining     int `json:"remaining"`
+	WindowSeconds int `json:"windowSeconds"`
+}
+
+// costBudgets caps the query cost each client may spend within a sliding
+// window. The window is approximated from the current and the previous fixed
+// window, counting the previous one by how much of it still overlaps. Budgets
+// live in memory, so every gateway replica enforces its own.
+type costBudgets struct {
+	limit  int
+	window time.Duration
+	now    func() time.Time
+
+	mu        sync.Mutex
+	clients   map[string]*costWindow
+	lastSweep time.Time
+}
+
+type costWindow struct {
+	start    time.Time
+	current  int
+	previous int
+}
+
+// newCostBudgets returns budgets of limit per window; a limit of 0 turns them
+// off.
+func newCostBudgets(limit int, window time.Duration) *costBudgets {
+	return &costBudgets{
+		limit:   limit,
+		window:  window,
+		now:     time.Now,
+		clients: map[string]*costWindow{},
+	}
+}
+
+// charge spends cost from the client's budget unless that would overdraw it.
+func (b *costBudgets) charge(client budgetClient, cost int) (*budgetStatus, bool) {
+	limit := b.limit
+	if client.budget > 0 {
+		limit = client.budget
+	}
+	if limit <= 0 || b.window <= 0 {
+		return nil, true
+	}
+
+	now := b.now()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.sweep(now)
+
+	w, ok := b.clients[client.id]
+	if !ok {
+		w = &costWindow{start: now.Truncate(b.window)}
+		b.clients[client.id] = w
+	}
+	switch elapsed := now.Sub(w.start); {
+	case elapsed >= 2*b.window:
+		*w = costWindow{start: now.Truncate(b.window)}
+	case elapsed >= b.window:
+		*w = costWindow{start: w.start.Add(b.window), previous: w.current}
+	}
+
+	overlap := 1 - float64(now.Sub(w.start))/float64(b.window)
+	used := int(float64(w.previous)*overlap) + w.current
+
+	status := &budgetStatus{Limit: limit, Remaining: max(limit-used, 0), WindowSeconds: int(b.window.Seconds())}
+	if used+cost > limit {
+		return status, false
+	}
+	w.current += cost
+	status.Remaining = limit - used - cost
+	return status, true
+}
+
+// sweep drops clients that have spent nothing for two windows, at most once
+// per window.
+func (b *costBudgets) sweep(now time.Time) {
+	if now.Sub(b.lastSweep) < b.window {
+		return
+	}
+	b.lastSweep = now
+	for id, w := range b.clients {
+		if now.Sub(w.start) >= 2*b.window {
+			delete(b.clients, id)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock the test moves by hand.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func newTestBudgets(limit int, window time.Duration) (*costBudgets, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+	b := newCostBudgets(limit, window)
+	b.now = clock.Now
+	return b, clock
+}
+
+func TestCostBudgetsCharge(t *testing.T) {
+	alice := budgetClient{id: "account:alice"}
+	bob := budgetClient{id: "account:bob"}
+	partner := budgetClient{id: "key:partner", budget: 500}
+
+	type charge struct {
+		// at is the time since the start of the first window
+		at            time.Duration
+		client        budgetClient
+		cost          int
+		wantOk        bool
+		wantRemaining int
+	}
+	tests := []struct {
+		name    string
+		charges []charge
+	}{
+		{
+			name: "spends within the window",
+			charges: []charge{
+				{at: 0, client: alice, cost: 80, wantOk: true, wantRemaining: 20},
+				{at: 10 * time.Second, client: alice, cost: 30, wantOk: false, wantRemaining: 20},
+				{at: 20 * time.Second, client: alice, cost: 20, wantOk: true, wantRemaining: 0},
+			},
+		},
+		{
+			name: "clients have separate budgets",
+			charges: []charge{
+				{at: 0, client: alice, cost: 100, wantOk: true, wantRemaining: 0},
+				{at: time.Second, client: bob, cost: 60, wantOk: true, wantRemaining: 40},
+				{at: 2 * time.Second, client: partner, cost: 450, wantOk: true, wantRemaining: 50},
+			},
+		},
+		{
+			name: "previous window counts by its overlap",
+			charges: []charge{
+				{at: 0, client: alice, cost: 80, wantOk: true, wantRemaining: 20},
+				// Half of the previous window still overlaps: 40 used
+				{at: 90 * time.Second, client: alice, cost: 70, wantOk: false, wantRemaining: 60},
+				{at: 90 * time.Second, client: alice, cost: 60, wantOk: true, wantRemaining: 0},
+				// A quarter of the first window's 80 overlaps, plus the 60 just spent
+				{at: 105 * time.Second, client: alice, cost: 21, wantOk: false, wantRemaining: 20},
+				// The 60 spent becomes the previous window and half of it overlaps
+				{at: 150 * time.Second, client: alice, cost: 70, wantOk: true, wantRemaining: 0},
+			},
+		},
+		{
+			name: "resets after two windows",
+			charges: []charge{
+				{at: 0, client: alice, cost: 100, wantOk: true, wantRemaining: 0},
+				{at: 59 * time.Second, client: alice, cost: 1, wantOk: false, wantRemaining: 0},
+				{at: 120 * time.Second, client: alice, cost: 100, wantOk: true, wantRemaining: 0},
+				{at: 5 * time.Minute, client: alice, cost: 10, wantOk: true, wantRemaining: 90},
+			},
+		},
+		{
+			name: "cost above the whole budget",
+			charges: []charge{
+				{at: 0, client: bob, cost: 101, wantOk: false, wantRemaining: 100},
+				{at: time.Second, client: bob, cost: 100, wantOk: true, wantRemaining: 0},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, clock := newTestBudgets(100, time.Minute)
+			start := clock.now
+
+			for i, c := range tt.charges {
+				clock.now = start.Add(c.at)
+				status, ok := b.charge(c.client, c.cost)
+				if ok != c.wantOk {
+					t.Fatalf("charge %d: ok = %v, want %v", i, ok, c.wantOk)
+				}
+				if status.Remaining != c.wantRemaining {
+					t.Errorf("charge %d: remaining = %d, want %d", i, status.Remaining, c.wantRemaining)
+				}
+				if limit := max(c.client.budget, 100); status.Limit != limit || status.WindowSeconds != 60 {
+					t.Errorf("charge %d: status = %+v, want a limit of %d per 60s", i, status, limit)
+				}
+			}
+		})
+	}
+}
+
+func TestCostBudgetsDisabled(t *testing.T) {
+	b, _ := newTestBudgets(0, time.Minute)
+	if status, ok := b.charge(budgetClient{id: "ip:127.0.0.1"}, 1_000_000); !ok || status != nil {
+		t.Errorf("charge without a limit = %+v, %v, want nil, true", status, ok)
+	}
+
+	// A client with its own budget is still held to it
+	status, ok := b.charge(budgetClient{id: "key:partner", budget: 10}, 11)
+	if ok || status == nil || status.Limit != 10 {
+		t.Errorf("charge over an API key budget = %+v, %v, want it refused", status, ok)
+	}
+}
+
+func TestCostBudgetsSweep(t *testing.T) {
+	b, clock := newTestBudgets(100, time.Minute)
+	start := clock.now
+	at := func(d time.Duration, id string) {
+		clock.now = start.Add(d)
+		b.charge(budgetClient{id: id}, 10)
+	}
+	tracked := func(id string) bool {
+		_, ok := b.clients[id]
+		return ok
+	}
+
+	at(0, "account:alice")
+	at(70*time.Second, "account:bob")
+
+	at(150*time.Second, "account:carol")
+	if tracked("account:alice") {
+		t.Error("alice is still tracked after two idle windows")
+	}
+	if !tracked("account:bob") {
+		t.Error("bob was dropped before being idle for two windows")
+	}
+
+	// bob is now due, but sweeps run at most once per window
+	at(190*time.Second, "account:dave")
+	if !tracked("account:bob") {
+		t.Error("bob was swept within one window of the last sweep")
+	}
+
+	at(210*time.Second, "account:carol")
+	if tracked("account:bob") {
+		t.Error("bob is still tracked after two idle windows")
+	}
+	if len(b.clients) != 2 {
+		t.Errorf("tracking %d clients, want carol and dave", len(b.clients))
+	}
+}
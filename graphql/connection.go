@@ -0,0 +1,29 @@
+package main
+
+import (
+	"github.com/fabian-emmanuel/go-ms/errs"
+)
+
+// pageSize reads the first argument of a connection. Zero leaves the page size
+// to the service, which also caps it.
+func pageSize(first *int) (int, error) {
+	if first == nil {
+		return 0, nil
+	}
+	if *first < 0 {
+		return 0, errs.Invalid(errs.FieldViolation{Field: "first", Description: "must not be negative"})
+	}
+	return *first, nil
+}
+
+func newPageInfo(cursors []string, hasNextPage bool, after *string) *PageInfo {
+	info := &PageInfo{
+		HasNextPage:     hasNextPage,
+		HasPreviousPage: optionalString(after) != "",
+	}
+	if len(cursors) > 0 {
+		info.StartCursor = &cursors[0]
+		info.EndCursor = &cursors[len(cursors)-1]
+	}
+	return info
+}
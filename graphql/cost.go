@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"math"
+	"strings"
+)
+
+// maxListSize matches the largest page the services return, so asking for
+// more items does not raise the estimate any further.
+const maxListSize = 100
+
+type queryCost struct {
+	Requested int           `json:"requested"`
+	Limit     int           `json:"limit"`
+	Depth     int           `json:"depth"`
+	Budget    *budgetStatus `json:"budget,omitempty"`
+}
+
+// costExtension rejects operations nested deeper than maxDepth or estimated
+// to cost more than maxCost, charges the rest to the caller's budget and
+// reports the cost in the response extensions. Costs come from the @cost
+// annotations in the schema. Introspection fields are free.
+type costExtension struct {
+	maxDepth int
+	maxCost  int
+	budgets  *costBudgets
+	schema   *ast.Schema
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationContextMutator
+	graphql.ResponseInterceptor
+} = &costExtension{}
+
+func (e *costExtension) ExtensionName() string {
+	return "Cost"
+}
+
+func (e *costExtension) Validate(schema graphql.ExecutableSchema) error {
+	e.schema = schema.Schema()
+	return nil
+}
+
+func (e *costExtension) MutateOperationContext(ctx context.Context, oc *graphql.OperationContext) *gqlerror.Error {
+	depth, cost := e.selectionSet(oc, oc.Operation.SelectionSet, 1)
+	qc := &queryCost{Requested: cost, Limit: e.maxCost, Depth: depth}
+
+	if depth > e.maxDepth {
+		return costError(fmt.Sprintf("query depth %d exceeds the limit of %d", depth, e.maxDepth), "QUERY_TOO_DEEP", qc)
+	}
+	if cost > e.maxCost {
+		return costError(fmt.Sprintf("query cost %d exceeds the limit of %d", cost, e.maxCost), "QUERY_TOO_COMPLEX", qc)
+	}
+
+	status, ok := e.budgets.charge(clientFromContext(ctx), cost)
+	qc.Budget = status
+	if !ok {
+		return costError("cost budget exceeded, retry later", "COST_BUDGET_EXCEEDED", qc)
+	}
+
+	oc.Stats.SetExtension(e.ExtensionName(), qc)
+	return nil
+}
+
+func (e *costExtension) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	res := next(ctx)
+	if res == nil || !graphql.HasOperationContext(ctx) {
+		return res
+	}
+	if qc, ok := graphql.GetOperationContext(ctx).Stats.GetExtension(e.ExtensionName()).(*queryCost); ok {
+		if res.Extensions == nil {
+			res.Extensions = map[string]any{}
+		}
+		res.Extensions["cost"] = qc
+	}
+	return res
+}
+
+// selectionSet returns the deepest level reached below set, which sits at
+// level, and the summed cost of its selections.
+func (e *costExtension) selectionSet(oc *graphql.OperationContext, set ast.SelectionSet, level int) (int, int) {
+	depth, cost := 0, 0
+	for _, sel := range set {
+		var d, c int
+		switch sel := sel.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(sel.Name, "__") {
+				continue
+			}
+			d, c = e.field(oc, sel, level)
+		case *ast.InlineFragment:
+			d, c = e.selectionSet(oc, sel.SelectionSet, level)
+		case *ast.FragmentSpread:
+			if sel.Definition != nil {
+				d, c = e.selectionSet(oc, sel.Definition.SelectionSet, level)
+			}
+		}
+		depth = max(depth, d)
+		cost = min(cost+c, math.MaxInt32)
+	}
+	return depth, cost
+}
+
+func (e *costExtension) field(oc *graphql.OperationContext, f *ast.Field, level int) (int, int) {
+	depth, children := e.selectionSet(oc, f.SelectionSet, level+1)
+	depth = max(depth, level)
+
+	weight, size := 0, 1
+	if t := e.schema.Types[f.Definition.Type.Name()]; t != nil && !t.IsLeafType() {
+		weight = 1
+	}
+	if d := f.Definition.Directives.ForName("cost"); d != nil {
+		args := d.ArgumentMap(nil)
+		if w, ok := toInt(args["weight"]); ok {
+			weight = w
+		}
+		if n, ok := toInt(args["listSize"]); ok {
+			size = n
+		}
+		if path, ok := args["sizedBy"].(string); ok {
+			if n, ok := toInt(argumentAt(f.ArgumentMap(oc.Variables), path)); ok && n > 0 {
+				size = n
+			}
+		}
+	}
+	size = min(max(size, 0), maxListSize)
+
+	return depth, min(weight+size*children, math.MaxInt32)
+}
+
+// argumentAt looks up a dotted path such as "pagination.take" in the
+// arguments of a field.
+func argumentAt(args map[string]any, path string) any {
+	var value any = args
+	for _, name := range strings.Split(path, ".") {
+		m, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = m[name]
+	}
+	return value
+}
+
+func toInt(v any) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int64:
+		return int(min(max(n, math.MinInt32), math.MaxInt32)), true
+	case float64:
+		return int(min(max(n, math.MinInt32), math.MaxInt32)), true
+	case json.Number:
+		i, err := n.Int64()
+		if err != nil {
+			return 0, false
+		}
+		return toInt(i)
+	default:
+		return 0, false
+	}
+}
+
+func costError(message, code string, qc *queryCost) *gqlerror.Error {
+	return &gqlerror.Error{
+		Message:    message,
+		Extensions: map[string]any{"code": code, "cost": qc},
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+	"testing"
+)
+
+const costTestSchema = `
+directive @cost(weight: Int = 1, sizedBy: String, listSize: Int) on FIELD_DEFINITION
+
+input Pagination {
+    skip: Int
+    take: Int
+}
+
+type Query {
+    product(id: ID!): Product
+    products(first: Int): [Product!]! @cost(sizedBy: "first", listSize: 10)
+    pagedProducts(pagination: Pagination): [Product!]! @cost(sizedBy: "pagination.take", listSize: 10)
+}
+
+type Product {
+    id: ID!
+    name: String!
+    reviews(first: Int): [Review!]! @cost(sizedBy: "first", listSize: 5)
+    recommendation: String @cost(weight: 10)
+}
+
+type Review {
+    id: ID!
+    author: Author
+}
+
+type Author {
+    id: ID!
+}
+`
+
+func TestCostExtensionSelectionSet(t *testing.T) {
+	schema := gqlparser.MustLoadSchema(&ast.Source{Name: "schema.graphql", Input: costTestSchema})
+	e := &costExtension{schema: schema}
+
+	tests := []struct {
+		name      string
+		query     string
+		variables map[string]any
+		wantDepth int
+		wantCost  int
+	}{
+		{
+			name:      "object with scalars",
+			query:     `{ product(id: "1") { id name } }`,
+			wantDepth: 2,
+			wantCost:  1,
+		},
+		{
+			name:      "weighted scalar",
+			query:     `{ product(id: "1") { recommendation } }`,
+			wantDepth: 2,
+			wantCost:  11,
+		},
+		{
+			name:      "introspection is free",
+			query:     `{ __typename product(id: "1") { __typename id } }`,
+			wantDepth: 2,
+			wantCost:  1,
+		},
+		{
+			// products: 1 + 20 * reviews (1 + 3 * 0)
+			name:      "sized by an argument",
+			query:     `{ products(first: 20) { reviews(first: 3) { id } } }`,
+			wantDepth: 3,
+			wantCost:  21,
+		},
+		{
+			// products: 1 + 10 * reviews (1 + 5 * author 1)
+			name:      "list size without the argument",
+			query:     `{ products { reviews { author { id } } } }`,
+			wantDepth: 4,
+			wantCost:  61,
+		},
+		{
+			name:      "argument of 0 falls back to the list size",
+			query:     `{ products(first: 0) { reviews(first: 1) { id } } }`,
+			wantDepth: 3,
+			wantCost:  11,
+		},
+		{
+			name:      "sized by a variable",
+			query:     `query ($n: Int) { products(first: $n) { reviews(first: 2) { id } } }`,
+			variables: map[string]any{"n": json.Number("7")},
+			wantDepth: 3,
+			wantCost:  8,
+		},
+		{
+			// pagedProducts: 1 + 4 * reviews (1 + 2 * author 1)
+			name:      "sized by a nested argument",
+			query:     `{ pagedProducts(pagination: {skip: 10, take: 4}) { reviews(first: 2) { author { id } } } }`,
+			wantDepth: 4,
+			wantCost:  13,
+		},
+		{
+			name:      "capped at the largest page",
+			query:     `{ products(first: 1000) { reviews(first: 3) { id } } }`,
+			wantDepth: 3,
+			wantCost:  1 + maxListSize,
+		},
+		{
+			// products: 1 + 100 * reviews (1 + 100 * author 1)
+			name:      "capped at every nested list",
+			query:     `{ products(first: 500) { reviews(first: 500) { author { id } } } }`,
+			wantDepth: 4,
+			wantCost:  1 + maxListSize*(1+maxListSize),
+		},
+		{
+			// products: 1 + 2 * reviews (1 + 3 * author 1)
+			name: "fragments",
+			query: `{ products(first: 2) { ...productReviews } }
+				fragment productReviews on Product { reviews(first: 3) { ... on Review { author { id } } } }`,
+			wantDepth: 4,
+			wantCost:  9,
+		},
+		{
+			name: "sibling lists add up",
+			query: `{
+				a: products(first: 2) { reviews(first: 3) { id } }
+				b: products(first: 4) { name }
+			}`,
+			wantDepth: 3,
+			wantCost:  (1 + 2*1) + 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, errs := gqlparser.LoadQuery(schema, tt.query)
+			if len(errs) > 0 {
+				t.Fatalf("LoadQuery: %v", errs)
+			}
+			oc := &graphql.OperationContext{Variables: tt.variables}
+
+			depth, cost := e.selectionSet(oc, doc.Operations[0].SelectionSet, 1)
+			if depth != tt.wantDepth || cost != tt.wantCost {
+				t.Errorf("depth, cost = %d, %d, want %d, %d", depth, cost, tt.wantDepth, tt.wantCost)
+			}
+		})
+	}
+}
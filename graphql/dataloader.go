@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/fabian-emmanuel/go-ms/catalog"
+	"github.com/fabian-emmanuel/go-ms/order"
+	"sync"
+	"time"
+)
+
+const (
+	loaderWait    = 2 * time.Millisecond
+	loaderTimeout = 10 * time.Second
+	// Both the order and catalog services cap a batch at 100 ids.
+	loaderMaxBatch = 100
+)
+
+// loader collects the keys requested by concurrently running resolvers for a
+// short window and resolves them with a single fetch. Results are cached for
+// the lifetime of the loader, which is one GraphQL response.
+type loader[K comparable, V any] struct {
+	fetch func(ctx context.Context, keys []K) (map[K]V, error)
+
+	mu    sync.Mutex
+	cache map[K]*loaderResult[V]
+	batch *loaderBatch[K, V]
+}
+
+type loaderResult[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+type loaderBatch[K comparable, V any] struct {
+	ctx     context.Context
+	keys    []K
+	results []*loaderResult[V]
+	timer   *time.Timer
+}
+
+func newLoader[K comparable, V any](fetch func(ctx context.Context, keys []K) (map[K]V, error)) *loader[K, V] {
+	return &loader[K, V]{
+		fetch: fetch,
+		cache: map[K]*loaderResult[V]{},
+	}
+}
+
+// Load returns the value for key, waiting for the batch it lands in. Keys the
+// fetch does not return resolve to the zero value.
+func (l *loader[K, V]) Load(ctx context.Context, key K) (V, error) {
+	res := l.enqueue(ctx, key)
+	return l.wait(ctx, res)
+}
+
+// LoadAll loads several keys in the same batch and returns their values in
+// order.
+func (l *loader[K, V]) LoadAll(ctx context.Context, keys []K) ([]V, error) {
+	results := make([]*loaderResult[V], len(keys))
+	for i, key := range keys {
+		results[i] = l.enqueue(ctx, key)
+	}
+
+	values := make([]V, len(keys))
+	for i, res := range results {
+		value, err := l.wait(ctx, res)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+func (l *loader[K, V]) enqueue(ctx context.Context, key K) *loaderResult[V] {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if res, ok := l.cache[key]; ok {
+		return res
+	}
+
+	res := &loaderResult[V]{done: make(chan struct{})}
+	l.cache[key] = res
+
+	if l.batch == nil {
+		// The first caller's context carries the principal, request ID and
+		// trace, but must not cancel the fetch for everyone else in the batch.
+		b := &loaderBatch[K, V]{ctx: context.WithoutCancel(ctx)}
+		b.timer = time.AfterFunc(loaderWait, func() { l.dispatch(b) })
+		l.batch = b
+	}
+	l.batch.keys = append(l.batch.keys, key)
+	l.batch.results = append(l.batch.results, res)
+
+	if len(l.batch.keys) >= loaderMaxBatch {
+		b := l.batch
+		l.batch = nil
+		if b.timer.Stop() {
+			go l.run(b)
+		}
+	}
+	return res
+}
+
+func (l *loader[K, V]) dispatch(b *loaderBatch[K, V]) {
+	l.mu.Lock()
+	if l.batch == b {
+		l.batch = nil
+	}
+	l.mu.Unlock()
+	l.run(b)
+}
+
+func (l *loader[K, V]) run(b *loaderBatch[K, V]) {
+	ctx, cancel := context.WithTimeout(b.ctx, loaderTimeout)
+	defer cancel()
+
+	values, err := l.fetch(ctx, b.keys)
+	if err != nil {
+		// Don't cache failures, a later load may succeed
+		l.mu.Lock()
+		for _, key := range b.keys {
+			delete(l.cache, key)
+		}
+		l.mu.Unlock()
+	}
+
+	for i, res := range b.results {
+		if err != nil {
+			res.err = err
+		} else {
+			res.value = values[b.keys[i]]
+		}
+		close(res.done)
+	}
+}
+
+func (l *loader[K, V]) wait(ctx context.Context, res *loaderResult[V]) (V, error) {
+	select {
+	case <-res.done:
+		return res.value, res.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+type loaders struct {
+	ordersByAccount *loader[string, []*order.Order]
+	products        *loader[string, *catalog.Product]
+}
+
+type loadersKey struct{}
+
+func (s *Server) newLoaders() *loaders {
+	return &loaders{
+		ordersByAccount: newLoader(func(ctx context.Context, accountIds []string) (map[string][]*order.Order, error) {
+			orders, err := s.orderClient.GetOrdersForAccounts(ctx, accountIds)
+			if err != nil {
+				return nil, err
+			}
+			byAccount := map[string][]*order.Order{}
+			for _, o := range orders {
+				byAccount[o.AccountId] = append(byAccount[o.AccountId], o)
+			}
+			return byAccount, nil
+		}),
+		products: newLoader(func(ctx context.Context, ids []string) (map[string]*catalog.Product, error) {
+			products, err := s.catalogClient.GetProductsByIds(ctx, ids, 0, 0)
+			if err != nil {
+				return nil, err
+			}
+			byId := map[string]*catalog.Product{}
+			for _, p := range products {
+				byId[p.ID] = p
+			}
+			return byId, nil
+		}),
+	}
+}
+
+func loadersFromContext(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
+
+// loaderExtension gives every GraphQL response its own set of loaders, so
+// batches never mix callers and cached values never outlive the response.
+type loaderExtension struct {
+	server *Server
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.ResponseInterceptor
+} = loaderExtension{}
+
+func (loaderExtension) ExtensionName() string {
+	return "DataLoaders"
+}
+
+func (loaderExtension) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (e loaderExtension) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	return next(context.WithValue(ctx, loadersKey{}, e.server.newLoaders()))
+}
+
+// addProductDetails fills in the product names and descriptions the order
+// service leaves out of batched results, falling back to the current catalog
+// price for orders placed before prices were snapshotted.
+func addProductDetails(ctx context.Context, orders []*Order) error {
+	var ids []string
+	for _, o := range orders {
+		for _, p := range o.Products {
+			ids = append(ids, p.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	products, err := loadersFromContext(ctx).products.LoadAll(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	i := 0
+	for _, o := range orders {
+		for _, op := range o.Products {
+			if p := products[i]; p != nil {
+				op.Name = p.Name
+				op.Description = p.Description
+				if op.Price.Currency == "" {
+					op.Price = toMoney(p.Price)
+				}
+			}
+			i++
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// recordingFetch returns every key's square and records the batches it is
+// called with.
+type recordingFetch struct {
+	mu      sync.Mutex
+	batches [][]int
+	err     error
+}
+
+func (f *recordingFetch) fetch(_ context.Context, keys []int) (map[int]int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.batches = append(f.batches, slices.Clone(keys))
+	if f.err != nil {
+		return nil, f.err
+	}
+	values := map[int]int{}
+	for _, key := range keys {
+		if key >= 0 {
+			values[key] = key * key
+		}
+	}
+	return values, nil
+}
+
+func (f *recordingFetch) batchSizes() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var sizes []int
+	for _, b := range f.batches {
+		sizes = append(sizes, len(b))
+	}
+	slices.Sort(sizes)
+	return sizes
+}
+
+func TestLoaderBatching(t *testing.T) {
+	keys := func(n int) []int {
+		var keys []int
+		for i := range n {
+			keys = append(keys, i)
+		}
+		return keys
+	}
+
+	tests := []struct {
+		name string
+		// loads are the keys requested by each concurrent resolver
+		loads [][]int
+		want  []int
+	}{
+		{"one key", [][]int{{1}}, []int{1}},
+		{"concurrent keys", [][]int{{1}, {2}, {3}}, []int{3}},
+		{"repeated keys", [][]int{{1}, {1}, {2, 1}}, []int{2}},
+		{"full batch", [][]int{keys(loaderMaxBatch)}, []int{loaderMaxBatch}},
+		{"over a full batch", [][]int{keys(loaderMaxBatch + 1)}, []int{1, loaderMaxBatch}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &recordingFetch{}
+			l := newLoader(f.fetch)
+
+			// Queue every resolver's keys before waiting on any, as resolvers
+			// running concurrently would
+			var results [][]*loaderResult[int]
+			for _, load := range tt.loads {
+				var res []*loaderResult[int]
+				for _, key := range load {
+					res = append(res, l.enqueue(context.Background(), key))
+				}
+				results = append(results, res)
+			}
+
+			for i, load := range tt.loads {
+				for j, key := range load {
+					v, err := l.wait(context.Background(), results[i][j])
+					if err != nil || v != key*key {
+						t.Errorf("value of %d = %d, %v; want %d", key, v, err, key*key)
+					}
+				}
+			}
+
+			if got := f.batchSizes(); !slices.Equal(got, tt.want) {
+				t.Errorf("batch sizes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoaderCache(t *testing.T) {
+	f := &recordingFetch{}
+	l := newLoader(f.fetch)
+	ctx := context.Background()
+
+	if v, err := l.Load(ctx, 3); err != nil || v != 9 {
+		t.Fatalf("Load(3) = %d, %v", v, err)
+	}
+	if v, err := l.Load(ctx, 3); err != nil || v != 9 {
+		t.Fatalf("cached Load(3) = %d, %v", v, err)
+	}
+	if v, err := l.Load(ctx, -1); err != nil || v != 0 {
+		t.Errorf("Load of a missing key = %d, %v; want the zero value", v, err)
+	}
+	if got := f.batchSizes(); !slices.Equal(got, []int{1, 1}) {
+		t.Errorf("batch sizes = %v, want a fetch per uncached key", got)
+	}
+}
+
+func TestLoaderDoesNotCacheErrors(t *testing.T) {
+	errDown := errors.New("service down")
+	f := &recordingFetch{err: errDown}
+	l := newLoader(f.fetch)
+	ctx := context.Background()
+
+	if _, err := l.Load(ctx, 2); !errors.Is(err, errDown) {
+		t.Fatalf("err = %v, want %v", err, errDown)
+	}
+
+	f.mu.Lock()
+	f.err = nil
+	f.mu.Unlock()
+	if v, err := l.Load(ctx, 2); err != nil || v != 4 {
+		t.Errorf("Load after the fetch recovered = %d, %v", v, err)
+	}
+}
+
+func TestLoaderWaitHonorsContext(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	l := newLoader(func(ctx context.Context, keys []int) (map[int]int, error) {
+		<-block
+		return nil, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := l.Load(ctx, 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/fabian-emmanuel/go-ms/errs"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errorCodes names gRPC codes for extensions.code. PermissionDenied reads
+// FORBIDDEN to match the gateway's own authorization errors.
+var errorCodes = map[codes.Code]string{
+	codes.NotFound:           "NOT_FOUND",
+	codes.InvalidArgument:    "INVALID_ARGUMENT",
+	codes.AlreadyExists:      "ALREADY_EXISTS",
+	codes.FailedPrecondition: "FAILED_PRECONDITION",
+	codes.Unavailable:        "UNAVAILABLE",
+	codes.Unauthenticated:    "UNAUTHENTICATED",
+	codes.PermissionDenied:   "FORBIDDEN",
+	codes.Aborted:            "ABORTED",
+	codes.ResourceExhausted:  "RESOURCE_EXHAUSTED",
+	codes.DeadlineExceeded:   "DEADLINE_EXCEEDED",
+	codes.Canceled:           "CANCELLED",
+	codes.Internal:           "INTERNAL",
+	codes.Unknown:            "INTERNAL",
+}
+
+// presentError sets extensions.code from the domain or gRPC status error
+// behind err, along with any field violations and the resource involved.
+// Errors that already carry a code, such as authorization and validation
+// errors, are left alone.
+func presentError(ctx context.Context, err error) *gqlerror.Error {
+	gqlErr := graphql.DefaultErrorPresenter(ctx, err)
+	if _, ok := gqlErr.Extensions["code"]; ok {
+		return gqlErr
+	}
+
+	if e, ok := errs.As(err); ok {
+		setExtension(gqlErr, "code", errorCodes[e.Code])
+		if len(e.Violations) > 0 {
+			var violations []map[string]string
+			for _, v := range e.Violations {
+				violations = append(violations, map[string]string{"field": v.Field, "description": v.Description})
+			}
+			setExtension(gqlErr, "fieldViolations", violations)
+		}
+		if e.Resource != nil {
+			setExtension(gqlErr, "resource", map[string]string{"type": e.Resource.Type, "name": e.Resource.Name})
+		}
+		return gqlErr
+	}
+
+	if st, ok := status.FromError(gqlErr.Err); ok && gqlErr.Err != nil {
+		if code, ok := errorCodes[st.Code()]; ok {
+			gqlErr.Message = st.Message()
+			setExtension(gqlErr, "code", code)
+		}
+	}
+	return gqlErr
+}
+
+func setExtension(err *gqlerror.Error, key string, value any) {
+	if err.Extensions == nil {
+		err.Extensions = map[string]any{}
+	}
+	err.Extensions[key] = value
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/99designs/gqlgen/graphql"
 	"github.com/fabian-emmanuel/go-ms/account"
 	"github.com/fabian-emmanuel/go-ms/catalog"
+	"github.com/fabian-emmanuel/go-ms/mtls"
 	"github.com/fabian-emmanuel/go-ms/order"
 )
 
@@ -13,18 +14,18 @@ type Server struct {
 	orderClient   *order.Client
 }
 
-func NewGraphQLServer(accountUrl, catalogUrl, orderUrl string) (*Server, error) {
-	accountClient, err := account.NewClient(accountUrl)
+func NewGraphQLServer(accountUrl, catalogUrl, orderUrl string, creds mtls.Config) (*Server, error) {
+	accountClient, err := account.NewClient(accountUrl, creds)
 	if err != nil {
 		return nil, err
 	}
-	catalogClient, err := catalog.NewClient(catalogUrl)
+	catalogClient, err := catalog.NewClient(catalogUrl, creds)
 	if err != nil {
 		accountClient.Close()
 		return nil, err
 	}
 
-	orderClient, err := order.NewClient(orderUrl)
+	orderClient, err := order.NewClient(orderUrl, creds)
 	if err != nil {
 		accountClient.Close()
 		catalogClient.Close()
@@ -38,6 +39,13 @@ func NewGraphQLServer(accountUrl, catalogUrl, orderUrl string) (*Server, error)
 	}, nil
 }
 
+// Close closes the connections to the downstream services.
+func (s *Server) Close() {
+	s.orderClient.Close()
+	s.catalogClient.Close()
+	s.accountClient.Close()
+}
+
 func (s *Server) Mutation() MutationResolver {
 	return &mutationResolver{
 		server: s,
@@ -50,6 +58,12 @@ func (s *Server) Query() QueryResolver {
 	}
 }
 
+func (s *Server) Subscription() SubscriptionResolver {
+	return &subscriptionResolver{
+		server: s,
+	}
+}
+
 func (s *Server) Account() AccountResolver {
 	return &accountResolver{
 		server: s,
@@ -59,5 +73,8 @@ func (s *Server) Account() AccountResolver {
 func (s *Server) ToExecutableSchema() graphql.ExecutableSchema {
 	return NewExecutableSchema(Config{
 		Resolvers: s,
+		Directives: DirectiveRoot{
+			Auth: authDirective,
+		},
 	})
 }
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// readinessTimeout bounds how long /readyz waits on the downstream services.
+const readinessTimeout = 2 * time.Second
+
+type readiness struct {
+	Status   string            `json:"status"`
+	Services map[string]string `json:"services"`
+}
+
+// healthzHandler reports that the gateway process is up, regardless of the
+// services behind it.
+func healthzHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte("ok"))
+	})
+}
+
+// readyzHandler reports whether every downstream service is SERVING, and
+// answers 503 with the failing ones otherwise.
+func (s *Server) readyzHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+		defer cancel()
+
+		checks := map[string]func(ctx context.Context) error{
+			"account": s.accountClient.Health,
+			"catalog": s.catalogClient.Health,
+			"order":   s.orderClient.Health,
+		}
+
+		res := readiness{Status: "ok", Services: map[string]string{}}
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		for name, check := range checks {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := check(ctx)
+
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					res.Status = "unavailable"
+					res.Services[name] = err.Error()
+					return
+				}
+				res.Services[name] = "serving"
+			}()
+		}
+		wg.Wait()
+
+		w.Header().Set("Content-Type", "application/json")
+		if res.Status != "ok" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			slog.ErrorContext(ctx, "Error writing readiness response", "err", err)
+		}
+	})
+}
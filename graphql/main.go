@@ -1,39 +1,149 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
 	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
+	"github.com/fabian-emmanuel/go-ms/auth"
+	"github.com/fabian-emmanuel/go-ms/lifecycle"
+	"github.com/fabian-emmanuel/go-ms/logging"
+	"github.com/fabian-emmanuel/go-ms/metrics"
+	"github.com/fabian-emmanuel/go-ms/mtls"
+	"github.com/fabian-emmanuel/go-ms/persisted"
+	"github.com/fabian-emmanuel/go-ms/tracing"
 	"github.com/kelseyhightower/envconfig"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"time"
 )
 
 type AppConfig struct {
-	AccountUrl         string `envconfig:"ACCOUNT_SERVER_URL"`
-	CatalogUrl         string `envconfig:"CATALOG_SERVER_URL"`
-	OrderUrl           string `envconfig:"ORDER_SERVER_URL"`
-	GraphQLServicePort int    `envconfig:"GRAPHQL_SERVICE_PORT"`
+	AccountUrl         string        `envconfig:"ACCOUNT_SERVER_URL"`
+	CatalogUrl         string        `envconfig:"CATALOG_SERVER_URL"`
+	OrderUrl           string        `envconfig:"ORDER_SERVER_URL"`
+	GraphQLServicePort int           `envconfig:"GRAPHQL_SERVICE_PORT"`
+	JwksUrl            string        `envconfig:"JWKS_URL"`
+	ShutdownTimeout    time.Duration `envconfig:"SHUTDOWN_TIMEOUT"`
+	MaxQueryDepth      int           `envconfig:"MAX_QUERY_DEPTH" default:"10"`
+	MaxQueryCost       int           `envconfig:"MAX_QUERY_COST" default:"5000"`
+	// CostBudget is what each client may spend per CostBudgetWindow; 0 turns
+	// budgets off. APIKeys lists the accepted keys as key:budget pairs.
+	CostBudget       int            `envconfig:"COST_BUDGET" default:"50000"`
+	CostBudgetWindow time.Duration  `envconfig:"COST_BUDGET_WINDOW" default:"1m"`
+	APIKeys          map[string]int `envconfig:"API_KEYS"`
+	// PersistedQueries is the postgres:// URL or file path of the registered
+	// operations. StrictOperations rejects every operation not registered there.
+	PersistedQueries string `envconfig:"PERSISTED_QUERIES"`
+	StrictOperations bool   `envconfig:"STRICT_OPERATIONS"`
+	// WebsocketKeepAlive is how often websockets get a keepalive message.
+	// graphql-transport-ws clients that leave two pings unanswered are
+	// disconnected.
+	WebsocketKeepAlive time.Duration `envconfig:"WEBSOCKET_KEEPALIVE" default:"15s"`
+	TLS                mtls.Config
+	Tracing            tracing.Config
+	Log                logging.Config
 }
 
 func main() {
+	if err := run(); err != nil {
+		slog.Error("Exiting", "err", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
 	var config AppConfig
 	err := envconfig.Process("", &config)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	s, err := NewGraphQLServer(config.AccountUrl, config.CatalogUrl, config.OrderUrl)
+	logging.Setup("graphql", config.Log)
+
+	// Deferred first so spans from the rest of shutdown are still flushed
+	shutdownTracing, err := tracing.Setup(context.Background(), "graphql", config.Tracing)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+	defer shutdownTracing()
+
+	ctx, stop := lifecycle.SignalContext()
+	defer stop()
+
+	s, err := NewGraphQLServer(config.AccountUrl, config.CatalogUrl, config.OrderUrl, config.TLS)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	var queryStore persisted.Store
+	if config.PersistedQueries != "" {
+		queryStore, err = persisted.Open(config.PersistedQueries)
+		if err != nil {
+			return err
+		}
+		defer queryStore.Close()
+	} else if config.StrictOperations {
+		return errors.New("STRICT_OPERATIONS requires PERSISTED_QUERIES")
+	}
+	persistedQueries := newPersistedQueryCache(queryStore, config.StrictOperations)
+
+	websockets := newWebsocketDrainer()
+	verifier := auth.NewVerifier(config.JwksUrl)
 
 	srv := handler.New(s.ToExecutableSchema())
-	srv.AddTransport(&transport.Websocket{})
-	http.Handle("/graphql", srv)
-	http.Handle("/playground", playground.Handler("GraphQL playground", "/graphql"))
-	log.Printf("Listening on port :%v...\n", config.GraphQLServicePort)
+	srv.AddTransport(&transport.Websocket{
+		InitFunc:              websocketInit(verifier),
+		InitTimeout:           10 * time.Second,
+		KeepAlivePingInterval: config.WebsocketKeepAlive,
+		PingPongInterval:      config.WebsocketKeepAlive,
+	})
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.Use(extension.AutomaticPersistedQuery{Cache: persistedQueries})
+	if config.StrictOperations {
+		srv.Use(allowlistExtension{queries: persistedQueries})
+	}
+	srv.SetErrorPresenter(presentError)
+	srv.Use(tracingExtension{})
+	srv.Use(metricsExtension{})
+	srv.Use(loaderExtension{server: s})
+	srv.Use(&costExtension{
+		maxDepth: config.MaxQueryDepth,
+		maxCost:  config.MaxQueryCost,
+		budgets:  newCostBudgets(config.CostBudget, config.CostBudgetWindow),
+	})
+
+	var api http.Handler = srv
+	api = apiKeyMiddleware(config.APIKeys, api)
+	api = authMiddleware(verifier, api)
+	api = websockets.Middleware(api)
+	api = logging.HTTPMiddleware(api)
+	api = tracing.HTTPMiddleware(api)
+
+	mux := http.NewServeMux()
+	mux.Handle("/graphql", api)
+	mux.Handle("/playground", playground.Handler("GraphQL playground", "/graphql"))
+	mux.Handle("/healthz", healthzHandler())
+	mux.Handle("/readyz", s.readyzHandler())
+	mux.Handle("/metrics", metrics.Handler())
+
+	httpServer := &http.Server{
+		Addr:    fmt.Sprintf(":%v", config.GraphQLServicePort),
+		Handler: mux,
+	}
+	httpServer.RegisterOnShutdown(websockets.Shutdown)
+
+	slog.Info("Listening", "port", config.GraphQLServicePort)
+	err = lifecycle.ServeHTTP(ctx, httpServer, config.ShutdownTimeout)
 
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%v", config.GraphQLServicePort), nil))
+	waitCtx, cancel := context.WithTimeout(context.Background(), lifecycle.Timeout(config.ShutdownTimeout))
+	defer cancel()
+	websockets.Wait(waitCtx)
+	return err
 }
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"time"
+)
+
+var (
+	operationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "graphql_operation_duration_seconds",
+		Help:    "Time to execute a GraphQL operation, by type, name and outcome.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"operation_type", "operation_name", "status"})
+
+	resolverDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "graphql_resolver_duration_seconds",
+		Help:    "Time spent in a field resolver, by field and outcome.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"object", "field", "status"})
+)
+
+// metricsExtension times operations and resolver calls. As with tracing,
+// fields read straight off their parent are left out.
+type metricsExtension struct{}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.ResponseInterceptor
+	graphql.FieldInterceptor
+} = metricsExtension{}
+
+func (metricsExtension) ExtensionName() string {
+	return "Metrics"
+}
+
+func (metricsExtension) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (metricsExtension) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	if !graphql.HasOperationContext(ctx) {
+		return next(ctx)
+	}
+	oc := graphql.GetOperationContext(ctx)
+
+	start := time.Now()
+	res := next(ctx)
+
+	operationType := "unknown"
+	if oc.Operation != nil {
+		operationType = string(oc.Operation.Operation)
+	}
+	operationName := oc.OperationName
+	if operationName == "" {
+		operationName = "anonymous"
+	}
+	status := "ok"
+	if res != nil && len(res.Errors) > 0 {
+		status = "error"
+	}
+	operationDuration.WithLabelValues(operationType, operationName, status).Observe(time.Since(start).Seconds())
+	return res
+}
+
+func (metricsExtension) InterceptField(ctx context.Context, next graphql.Resolver) (any, error) {
+	fc := graphql.GetFieldContext(ctx)
+	if fc == nil || !fc.IsResolver {
+		return next(ctx)
+	}
+
+	start := time.Now()
+	res, err := next(ctx)
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	resolverDuration.WithLabelValues(fc.Object, fc.Field.Name, status).Observe(time.Since(start).Seconds())
+	return res, err
+}
@@ -1,6 +1,148 @@
 package main
 
+import (
+	"github.com/fabian-emmanuel/go-ms/account"
+	"github.com/fabian-emmanuel/go-ms/auth"
+	"github.com/fabian-emmanuel/go-ms/catalog"
+	"github.com/fabian-emmanuel/go-ms/money"
+	"github.com/fabian-emmanuel/go-ms/order"
+	"strings"
+)
+
 type Account struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID     string        `json:"id"`
+	Name   string        `json:"name"`
+	Email  string        `json:"email"`
+	Status AccountStatus `json:"status"`
+	Roles  []Role        `json:"roles"`
+}
+
+func toAccount(a *account.Account) *Account {
+	status := AccountStatusActive
+	if !a.IsActive() {
+		status = AccountStatusDeactivated
+	}
+	roles := []Role{}
+	for _, role := range a.Roles {
+		roles = append(roles, toRole(role))
+	}
+	return &Account{
+		ID:     a.ID,
+		Name:   a.Name,
+		Email:  a.Email,
+		Status: status,
+		Roles:  roles,
+	}
+}
+
+func toRole(r auth.Role) Role {
+	return Role(strings.ToUpper(string(r)))
+}
+
+func fromRole(r Role) auth.Role {
+	return auth.Role(strings.ToLower(string(r)))
+}
+
+func toRoleChange(c *account.RoleChange) *RoleChange {
+	change := &RoleChange{
+		ID:        c.ID,
+		AccountID: c.AccountId,
+		Role:      toRole(c.Role),
+		Action:    RoleAction(strings.ToUpper(string(c.Action))),
+		Reason:    c.Reason,
+		ChangedAt: c.ChangedAt,
+	}
+	if c.ActorId != "" {
+		change.ActorID = &c.ActorId
+	}
+	return change
+}
+
+func toAuthPayload(t *account.TokenPair) *AuthPayload {
+	return &AuthPayload{
+		AccessToken:  t.AccessToken,
+		RefreshToken: t.RefreshToken,
+		ExpiresIn:    int(t.ExpiresIn.Seconds()),
+	}
+}
+
+func toOrder(o *order.Order) *Order {
+	products := []*OrderedProduct{}
+	for _, product := range o.Products {
+		products = append(products, &OrderedProduct{
+			ID:          product.ID,
+			Name:        product.Name,
+			Price:       toMoney(product.Price),
+			Description: product.Description,
+			Quantity:    int(product.Quantity),
+		})
+	}
+
+	history := []*OrderStatusChange{}
+	for _, c := range o.StatusHistory {
+		change := &OrderStatusChange{
+			To:        toOrderStatus(c.To),
+			Reason:    c.Reason,
+			ChangedAt: c.ChangedAt,
+		}
+		if c.From != "" {
+			from := toOrderStatus(c.From)
+			change.From = &from
+		}
+		history = append(history, change)
+	}
+
+	rates := []*ExchangeRate{}
+	for _, rate := range o.ExchangeRates {
+		rates = append(rates, &ExchangeRate{
+			From: rate.From,
+			To:   rate.To,
+			Rate: rate.Value,
+		})
+	}
+
+	return &Order{
+		ID:            o.ID,
+		CreatedAt:     o.CreatedAt,
+		TotalPrice:    toMoney(o.TotalAmount),
+		Status:        toOrderStatus(o.Status),
+		StatusHistory: history,
+		ExchangeRates: rates,
+		Products:      products,
+	}
+}
+
+func toOrderStatus(s order.OrderStatus) OrderStatus {
+	return OrderStatus(strings.ToUpper(string(s)))
+}
+
+func fromOrderStatus(s OrderStatus) order.OrderStatus {
+	return order.OrderStatus(strings.ToLower(string(s)))
+}
+
+func toProduct(p *catalog.Product) *Product {
+	prices := []*Money{}
+	for _, price := range p.Prices {
+		prices = append(prices, toMoney(price))
+	}
+
+	return &Product{
+		ID:          p.ID,
+		Name:        p.Name,
+		Description: p.Description,
+		Price:       toMoney(p.Price),
+		Prices:      prices,
+		Stock:       int(p.Stock),
+	}
+}
+
+func toMoney(m money.Money) *Money {
+	return &Money{
+		Amount:   m.Decimal(),
+		Currency: m.Currency,
+	}
+}
+
+func (m *MoneyInput) parse() (money.Money, error) {
+	return money.Parse(m.Amount, m.Currency)
 }
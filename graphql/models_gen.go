@@ -3,35 +3,116 @@
 package main
 
 import (
+	"fmt"
+	"io"
+	"strconv"
 	"time"
 )
 
+type AccountConnection struct {
+	Edges      []*AccountEdge `json:"edges"`
+	PageInfo   *PageInfo      `json:"pageInfo"`
+	TotalCount int            `json:"totalCount"`
+}
+
+type AccountEdge struct {
+	Node   *Account `json:"node"`
+	Cursor string   `json:"cursor"`
+}
+
 type AccountInput struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type AccountUpdateInput struct {
 	Name string `json:"name"`
 }
 
+type AuthPayload struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	// Seconds until the access token expires.
+	ExpiresIn int `json:"expiresIn"`
+}
+
+type ExchangeRate struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Rate string `json:"rate"`
+}
+
+// An exact amount of money. `amount` is a decimal string in major units, e.g. "1999.50".
+type Money struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+type MoneyInput struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
 type Mutation struct {
 }
 
 type Order struct {
-	ID         string            `json:"id"`
-	Name       string            `json:"name"`
-	CreatedAt  time.Time         `json:"createdAt"`
-	TotalPrice float64           `json:"totalPrice"`
-	Products   []*OrderedProduct `json:"products"`
+	ID            string               `json:"id"`
+	Name          string               `json:"name"`
+	CreatedAt     time.Time            `json:"createdAt"`
+	TotalPrice    *Money               `json:"totalPrice"`
+	Status        OrderStatus          `json:"status"`
+	StatusHistory []*OrderStatusChange `json:"statusHistory"`
+	ExchangeRates []*ExchangeRate      `json:"exchangeRates"`
+	Products      []*OrderedProduct    `json:"products"`
+}
+
+type OrderConnection struct {
+	Edges      []*OrderEdge `json:"edges"`
+	PageInfo   *PageInfo    `json:"pageInfo"`
+	TotalCount int          `json:"totalCount"`
+}
+
+type OrderEdge struct {
+	Node   *Order `json:"node"`
+	Cursor string `json:"cursor"`
+}
+
+type OrderFilter struct {
+	AccountID     *string       `json:"accountId,omitempty"`
+	Statuses      []OrderStatus `json:"statuses,omitempty"`
+	CreatedAfter  *time.Time    `json:"createdAfter,omitempty"`
+	CreatedBefore *time.Time    `json:"createdBefore,omitempty"`
+	// Only orders totalled in this currency with at least this amount.
+	MinTotal *MoneyInput `json:"minTotal,omitempty"`
 }
 
 type OrderInput struct {
 	AccountID string                 `json:"accountId"`
+	Currency  *string                `json:"currency,omitempty"`
 	Products  []*OrderedProductInput `json:"products,omitempty"`
 }
 
+type OrderPage struct {
+	Orders      []*Order `json:"orders"`
+	EndCursor   *string  `json:"endCursor,omitempty"`
+	HasNextPage bool     `json:"hasNextPage"`
+}
+
+type OrderStatusChange struct {
+	From      *OrderStatus `json:"from,omitempty"`
+	To        OrderStatus  `json:"to"`
+	Reason    string       `json:"reason"`
+	ChangedAt time.Time    `json:"changedAt"`
+}
+
 type OrderedProduct struct {
-	ID          string  `json:"id"`
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
-	Quantity    int     `json:"quantity"`
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Price       *Money `json:"price"`
+	Quantity    int    `json:"quantity"`
 }
 
 type OrderedProductInput struct {
@@ -39,23 +120,288 @@ type OrderedProductInput struct {
 	Quantity int    `json:"quantity"`
 }
 
+// Where a page of a connection sits in the whole list. Pagination is forward only.
+type PageInfo struct {
+	HasNextPage bool `json:"hasNextPage"`
+	// True when the page was fetched with `after`; earlier pages are not looked up.
+	HasPreviousPage bool    `json:"hasPreviousPage"`
+	StartCursor     *string `json:"startCursor,omitempty"`
+	EndCursor       *string `json:"endCursor,omitempty"`
+}
+
 type PaginationInput struct {
 	Skip *int `json:"skip,omitempty"`
 	Take *int `json:"take,omitempty"`
 }
 
 type Product struct {
-	ID          string  `json:"id"`
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Price       *Money   `json:"price"`
+	Prices      []*Money `json:"prices"`
+	Stock       int      `json:"stock"`
+}
+
+type ProductConnection struct {
+	Edges      []*ProductEdge `json:"edges"`
+	PageInfo   *PageInfo      `json:"pageInfo"`
+	TotalCount int            `json:"totalCount"`
+}
+
+type ProductEdge struct {
+	Node   *Product `json:"node"`
+	Cursor string   `json:"cursor"`
 }
 
 type ProductInput struct {
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Price       *MoneyInput   `json:"price"`
+	Prices      []*MoneyInput `json:"prices,omitempty"`
+	Stock       *int          `json:"stock,omitempty"`
 }
 
 type Query struct {
 }
+
+type RoleChange struct {
+	ID        string     `json:"id"`
+	AccountID string     `json:"accountId"`
+	Role      Role       `json:"role"`
+	Action    RoleAction `json:"action"`
+	// The admin who made the change; null when the service made it itself.
+	ActorID   *string   `json:"actorId,omitempty"`
+	Reason    string    `json:"reason"`
+	ChangedAt time.Time `json:"changedAt"`
+}
+
+// Subscriptions are only served over websockets. Send the access token as the
+// Authorization field of the connection_init payload. A subscription whose
+// client falls too far behind ends with a RESOURCE_EXHAUSTED error; subscribe
+// again to catch up.
+type Subscription struct {
+}
+
+type AccountStatus string
+
+const (
+	AccountStatusActive      AccountStatus = "ACTIVE"
+	AccountStatusDeactivated AccountStatus = "DEACTIVATED"
+)
+
+var AllAccountStatus = []AccountStatus{
+	AccountStatusActive,
+	AccountStatusDeactivated,
+}
+
+func (e AccountStatus) IsValid() bool {
+	switch e {
+	case AccountStatusActive, AccountStatusDeactivated:
+		return true
+	}
+	return false
+}
+
+func (e AccountStatus) String() string {
+	return string(e)
+}
+
+func (e *AccountStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = AccountStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid AccountStatus", str)
+	}
+	return nil
+}
+
+func (e AccountStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type OrderSort string
+
+const (
+	OrderSortCreatedAtDesc OrderSort = "CREATED_AT_DESC"
+	OrderSortCreatedAtAsc  OrderSort = "CREATED_AT_ASC"
+	OrderSortTotalDesc     OrderSort = "TOTAL_DESC"
+	OrderSortTotalAsc      OrderSort = "TOTAL_ASC"
+)
+
+var AllOrderSort = []OrderSort{
+	OrderSortCreatedAtDesc,
+	OrderSortCreatedAtAsc,
+	OrderSortTotalDesc,
+	OrderSortTotalAsc,
+}
+
+func (e OrderSort) IsValid() bool {
+	switch e {
+	case OrderSortCreatedAtDesc, OrderSortCreatedAtAsc, OrderSortTotalDesc, OrderSortTotalAsc:
+		return true
+	}
+	return false
+}
+
+func (e OrderSort) String() string {
+	return string(e)
+}
+
+func (e *OrderSort) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = OrderSort(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid OrderSort", str)
+	}
+	return nil
+}
+
+func (e OrderSort) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type OrderStatus string
+
+const (
+	OrderStatusPending   OrderStatus = "PENDING"
+	OrderStatusPaid      OrderStatus = "PAID"
+	OrderStatusFulfilled OrderStatus = "FULFILLED"
+	OrderStatusShipped   OrderStatus = "SHIPPED"
+	OrderStatusDelivered OrderStatus = "DELIVERED"
+	OrderStatusCancelled OrderStatus = "CANCELLED"
+	OrderStatusRefunded  OrderStatus = "REFUNDED"
+)
+
+var AllOrderStatus = []OrderStatus{
+	OrderStatusPending,
+	OrderStatusPaid,
+	OrderStatusFulfilled,
+	OrderStatusShipped,
+	OrderStatusDelivered,
+	OrderStatusCancelled,
+	OrderStatusRefunded,
+}
+
+func (e OrderStatus) IsValid() bool {
+	switch e {
+	case OrderStatusPending, OrderStatusPaid, OrderStatusFulfilled, OrderStatusShipped, OrderStatusDelivered, OrderStatusCancelled, OrderStatusRefunded:
+		return true
+	}
+	return false
+}
+
+func (e OrderStatus) String() string {
+	return string(e)
+}
+
+func (e *OrderStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = OrderStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid OrderStatus", str)
+	}
+	return nil
+}
+
+func (e OrderStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type Role string
+
+const (
+	RoleAdmin    Role = "ADMIN"
+	RoleMerchant Role = "MERCHANT"
+	RoleCustomer Role = "CUSTOMER"
+	RoleService  Role = "SERVICE"
+)
+
+var AllRole = []Role{
+	RoleAdmin,
+	RoleMerchant,
+	RoleCustomer,
+	RoleService,
+}
+
+func (e Role) IsValid() bool {
+	switch e {
+	case RoleAdmin, RoleMerchant, RoleCustomer, RoleService:
+		return true
+	}
+	return false
+}
+
+func (e Role) String() string {
+	return string(e)
+}
+
+func (e *Role) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = Role(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid Role", str)
+	}
+	return nil
+}
+
+func (e Role) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type RoleAction string
+
+const (
+	RoleActionGranted RoleAction = "GRANTED"
+	RoleActionRevoked RoleAction = "REVOKED"
+)
+
+var AllRoleAction = []RoleAction{
+	RoleActionGranted,
+	RoleActionRevoked,
+}
+
+func (e RoleAction) IsValid() bool {
+	switch e {
+	case RoleActionGranted, RoleActionRevoked:
+		return true
+	}
+	return false
+}
+
+func (e RoleAction) String() string {
+	return string(e)
+}
+
+func (e *RoleAction) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = RoleAction(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid RoleAction", str)
+	}
+	return nil
+}
+
+func (e RoleAction) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
@@ -3,6 +3,9 @@ package main
 import (
 	"context"
 	"fmt"
+	"github.com/fabian-emmanuel/go-ms/errs"
+	"github.com/fabian-emmanuel/go-ms/idempotency"
+	"github.com/fabian-emmanuel/go-ms/money"
 	"github.com/fabian-emmanuel/go-ms/order"
 	"time"
 )
@@ -11,47 +14,163 @@ type mutationResolver struct {
 	server *Server
 }
 
-func (r *mutationResolver) CreateAccount(ctx context.Context, in AccountInput) (*Account, error) {
+func (r *mutationResolver) CreateAccount(ctx context.Context, in AccountInput, idempotencyKey *string) (*Account, error) {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
+	ctx = idempotency.WithKey(ctx, optionalString(idempotencyKey))
 
-	a, err := r.server.accountClient.PostAccount(ctx, in.Name)
+	a, err := r.server.accountClient.PostAccount(ctx, in.Name, in.Email, in.Password)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Account{
-		ID:   a.ID,
-		Name: a.Name,
-	}, nil
+	return toAccount(a), nil
 }
 
-func (r *mutationResolver) CreateProduct(ctx context.Context, in ProductInput) (*Product, error) {
+func (r *mutationResolver) UpdateAccount(ctx context.Context, id string, in AccountUpdateInput) (*Account, error) {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	p, err := r.server.catalogClient.CreateProduct(ctx, in.Name, in.Description, in.Price)
+	if err := requireAccountAccess(ctx, id); err != nil {
+		return nil, err
+	}
+
+	a, err := r.server.accountClient.UpdateAccount(ctx, id, in.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return toAccount(a), nil
+}
+
+func (r *mutationResolver) DeactivateAccount(ctx context.Context, id string) (*Account, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := requireAccountAccess(ctx, id); err != nil {
+		return nil, err
+	}
+
+	a, err := r.server.accountClient.DeactivateAccount(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Product{
-		ID:          p.ID,
-		Name:        p.Name,
-		Description: p.Description,
-		Price:       p.Price,
-	}, nil
+	return toAccount(a), nil
+}
+
+func (r *mutationResolver) DeleteAccount(ctx context.Context, id string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := requireAccountAccess(ctx, id); err != nil {
+		return false, err
+	}
+
+	if err := r.server.accountClient.DeleteAccount(ctx, id); err != nil {
+		return false, err
+	}
+
+	return true, nil
 }
 
-func (r *mutationResolver) CreateOrder(ctx context.Context, in OrderInput) (*Order, error) {
+func (r *mutationResolver) Login(ctx context.Context, email string, password string) (*AuthPayload, error) {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
+	tokens, err := r.server.accountClient.Login(ctx, email, password)
+	if err != nil {
+		return nil, err
+	}
+
+	return toAuthPayload(tokens), nil
+}
+
+func (r *mutationResolver) RefreshToken(ctx context.Context, refreshToken string) (*AuthPayload, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tokens, err := r.server.accountClient.RefreshToken(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return toAuthPayload(tokens), nil
+}
+
+func (r *mutationResolver) GrantRole(ctx context.Context, accountId string, role Role, reason *string) (*Account, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	a, err := r.server.accountClient.GrantRole(ctx, accountId, fromRole(role), optionalString(reason))
+	if err != nil {
+		return nil, err
+	}
+
+	return toAccount(a), nil
+}
+
+func (r *mutationResolver) RevokeRole(ctx context.Context, accountId string, role Role, reason *string) (*Account, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	a, err := r.server.accountClient.RevokeRole(ctx, accountId, fromRole(role), optionalString(reason))
+	if err != nil {
+		return nil, err
+	}
+
+	return toAccount(a), nil
+}
+
+func (r *mutationResolver) CreateProduct(ctx context.Context, in ProductInput, idempotencyKey *string) (*Product, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	ctx = idempotency.WithKey(ctx, optionalString(idempotencyKey))
+
+	price, err := in.Price.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	var prices []money.Money
+	for _, pi := range in.Prices {
+		p, err := pi.parse()
+		if err != nil {
+			return nil, err
+		}
+		prices = append(prices, p)
+	}
+
+	var stock uint32
+	if in.Stock != nil {
+		if *in.Stock < 0 {
+			return nil, errs.Invalid(errs.FieldViolation{Field: "product.stock", Description: "must not be negative"})
+		}
+		stock = uint32(*in.Stock)
+	}
+
+	p, err := r.server.catalogClient.CreateProduct(ctx, in.Name, in.Description, price, prices, stock)
+	if err != nil {
+		return nil, err
+	}
+
+	return toProduct(p), nil
+}
+
+func (r *mutationResolver) CreateOrder(ctx context.Context, in OrderInput, idempotencyKey *string) (*Order, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	ctx = idempotency.WithKey(ctx, optionalString(idempotencyKey))
+
+	if err := requireAccountAccess(ctx, in.AccountID); err != nil {
+		return nil, err
+	}
+
 	var products []order.OrderedProduct
 
-	for _, p := range in.Products {
+	for i, p := range in.Products {
 		if p.Quantity < 1 {
-			return nil, fmt.Errorf("quantity must be be greater than zero")
+			return nil, errs.Invalid(errs.FieldViolation{Field: fmt.Sprintf("order.products[%d].quantity", i), Description: "must be greater than zero"})
 		}
 		products = append(products, order.OrderedProduct{
 			ID:       p.ID,
@@ -59,14 +178,49 @@ func (r *mutationResolver) CreateOrder(ctx context.Context, in OrderInput) (*Ord
 		})
 	}
 
-	o, err := r.server.orderClient.CreateOrder(ctx, in.AccountID, products)
+	o, err := r.server.orderClient.CreateOrder(ctx, in.AccountID, optionalString(in.Currency), products)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Order{
-		ID:         o.ID,
-		CreatedAt:  o.CreatedAt,
-		TotalPrice: o.TotalAmount,
-	}, nil
+	return toOrder(o), nil
+}
+
+func (r *mutationResolver) UpdateOrderStatus(ctx context.Context, id string, status OrderStatus, reason *string) (*Order, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	o, err := r.server.orderClient.UpdateOrderStatus(ctx, id, fromOrderStatus(status), optionalString(reason))
+	if err != nil {
+		return nil, err
+	}
+
+	return toOrder(o), nil
+}
+
+func (r *mutationResolver) CancelOrder(ctx context.Context, id string, reason *string) (*Order, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	existing, err := r.server.orderClient.GetOrder(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireAccountAccess(ctx, existing.AccountId); err != nil {
+		return nil, err
+	}
+
+	o, err := r.server.orderClient.CancelOrder(ctx, id, optionalString(reason))
+	if err != nil {
+		return nil, err
+	}
+
+	return toOrder(o), nil
+}
+
+func optionalString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
 }
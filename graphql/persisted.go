@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/fabian-emmanuel/go-ms/persisted"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"log/slog"
+)
+
+const persistedQueryCacheSize = 1000
+
+// persistedQueryCache serves automatic persisted queries from an in-memory LRU,
+// falling back to the registered operations in store. Queries clients register
+// on the fly only go into the LRU of this replica, and not at all in strict
+// mode, where only operations registered ahead of time may run.
+type persistedQueryCache struct {
+	lru    *lru.LRU[string]
+	store  persisted.Store
+	strict bool
+}
+
+var _ graphql.Cache[string] = &persistedQueryCache{}
+
+func newPersistedQueryCache(store persisted.Store, strict bool) *persistedQueryCache {
+	return &persistedQueryCache{
+		lru:    lru.New[string](persistedQueryCacheSize),
+		store:  store,
+		strict: strict,
+	}
+}
+
+func (c *persistedQueryCache) Get(ctx context.Context, hash string) (string, bool) {
+	if query, ok := c.lru.Get(ctx, hash); ok {
+		return query, true
+	}
+	if c.store == nil {
+		return "", false
+	}
+
+	query, ok, err := c.store.Get(ctx, hash)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error getting persisted query", "err", err)
+		return "", false
+	}
+	if ok {
+		c.lru.Add(ctx, hash, query)
+	}
+	return query, ok
+}
+
+func (c *persistedQueryCache) Add(ctx context.Context, hash, query string) {
+	if c.strict {
+		return
+	}
+	c.lru.Add(ctx, hash, query)
+}
+
+// allowlistExtension only lets registered operations run, whether the client
+// sent the full query or just its hash. It must be added after the persisted
+// query extension, which swaps hashes for queries.
+type allowlistExtension struct {
+	queries *persistedQueryCache
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationParameterMutator
+} = allowlistExtension{}
+
+func (allowlistExtension) ExtensionName() string {
+	return "OperationAllowlist"
+}
+
+func (allowlistExtension) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (e allowlistExtension) MutateOperationParameters(ctx context.Context, params *graphql.RawParams) *gqlerror.Error {
+	if _, ok := e.queries.Get(ctx, persisted.Hash(params.Query)); ok {
+		return nil
+	}
+	return &gqlerror.Error{
+		Message:    "operation not allowed",
+		Extensions: map[string]any{"code": "OPERATION_NOT_ALLOWED"},
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/fabian-emmanuel/go-ms/persisted"
+	"testing"
+)
+
+// memoryQueryStore is a persisted.Store whose lookups fail with err when set.
+type memoryQueryStore struct {
+	queries map[string]string
+	err     error
+}
+
+func newMemoryQueryStore(queries ...string) *memoryQueryStore {
+	s := &memoryQueryStore{queries: map[string]string{}}
+	for _, query := range queries {
+		s.queries[persisted.Hash(query)] = query
+	}
+	return s
+}
+
+func (s *memoryQueryStore) Get(_ context.Context, hash string) (string, bool, error) {
+	if s.err != nil {
+		return "", false, s.err
+	}
+	query, ok := s.queries[hash]
+	return query, ok, nil
+}
+
+func (s *memoryQueryStore) Put(_ context.Context, query string) (string, error) {
+	hash := persisted.Hash(query)
+	s.queries[hash] = query
+	return hash, nil
+}
+
+func (s *memoryQueryStore) Close() error {
+	return nil
+}
+
+func TestPersistedQueryCacheAdd(t *testing.T) {
+	const query = "{ products { id } }"
+	hash := persisted.Hash(query)
+	ctx := context.Background()
+
+	for _, strict := range []bool{false, true} {
+		c := newPersistedQueryCache(newMemoryQueryStore(), strict)
+		c.Add(ctx, hash, query)
+		if _, ok := c.Get(ctx, hash); ok == strict {
+			t.Errorf("strict %v: Get after Add = %v, want %v", strict, ok, !strict)
+		}
+	}
+}
+
+func TestAllowlistExtension(t *testing.T) {
+	const registered = "query Products { products { id name } }"
+	const unregistered = "query Accounts { accounts { id email } }"
+
+	tests := []struct {
+		name     string
+		storeErr error
+		// apq sends the query through the automatic persisted query extension
+		// first, hashOnly sends it as just its hash
+		apq       bool
+		hashOnly  bool
+		query     string
+		wantAllow bool
+	}{
+		{name: "registered query", query: registered, wantAllow: true},
+		{name: "unregistered query", query: unregistered},
+		{name: "registered hash", apq: true, hashOnly: true, query: registered, wantAllow: true},
+		{name: "registered query with its hash", apq: true, query: registered, wantAllow: true},
+		{name: "unregistered query registered on the fly", apq: true, query: unregistered},
+		{name: "store error fails closed", storeErr: errors.New("connection refused"), query: registered},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemoryQueryStore(registered)
+			store.err = tt.storeErr
+			cache := newPersistedQueryCache(store, true)
+			// The persisted query extension records its outcome on the operation context
+			ctx := graphql.WithOperationContext(context.Background(), &graphql.OperationContext{})
+
+			params := &graphql.RawParams{Query: tt.query}
+			if tt.hashOnly {
+				params.Query = ""
+			}
+			if tt.apq {
+				params.Extensions = map[string]any{
+					"persistedQuery": map[string]any{"version": 1, "sha256Hash": persisted.Hash(tt.query)},
+				}
+				if err := (extension.AutomaticPersistedQuery{Cache: cache}).MutateOperationParameters(ctx, params); err != nil {
+					t.Fatalf("AutomaticPersistedQuery: %v", err)
+				}
+			}
+
+			err := allowlistExtension{queries: cache}.MutateOperationParameters(ctx, params)
+			if tt.wantAllow {
+				if err != nil {
+					t.Errorf("MutateOperationParameters: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Extensions["code"] != "OPERATION_NOT_ALLOWED" {
+				t.Errorf("MutateOperationParameters = %v, want OPERATION_NOT_ALLOWED", err)
+			}
+
+			// Nothing the rejected request did lets it through afterwards
+			if _, ok := cache.Get(ctx, persisted.Hash(tt.query)); ok && tt.storeErr == nil {
+				t.Errorf("%q became allowed", tt.query)
+			}
+		})
+	}
+}
@@ -2,6 +2,11 @@ package main
 
 import (
 	"context"
+	"github.com/fabian-emmanuel/go-ms/account"
+	"github.com/fabian-emmanuel/go-ms/auth"
+	"github.com/fabian-emmanuel/go-ms/catalog"
+	"github.com/fabian-emmanuel/go-ms/order"
+	"strings"
 	"time"
 )
 
@@ -15,15 +20,20 @@ func (r *queryResolver) Accounts(ctx context.Context, pagination *PaginationInpu
 	defer cancel()
 
 	if id != nil {
+		if err := requireAccountAccess(ctx, *id); err != nil {
+			return nil, err
+		}
+
 		res, err := r.server.accountClient.GetAccount(ctx, *id)
 		if err != nil {
 			return nil, err
 		}
 
-		return []*Account{{
-			ID:   res.ID,
-			Name: res.Name,
-		}}, nil
+		return []*Account{toAccount(res)}, nil
+	}
+
+	if !auth.FromContext(ctx).Can(auth.PermListAccounts) {
+		return nil, errForbidden(ctx)
 	}
 
 	skip, take := uint64(0), uint64(10) // Default values
@@ -39,15 +49,65 @@ func (r *queryResolver) Accounts(ctx context.Context, pagination *PaginationInpu
 
 	var accounts []*Account
 	for _, a := range res {
-		accounts = append(accounts, &Account{
-			ID:   a.ID,
-			Name: a.Name,
-		})
+		accounts = append(accounts, toAccount(a))
 	}
 
 	return accounts, nil
 }
 
+func (r *queryResolver) AccountsConnection(ctx context.Context, first *int, after *string) (*AccountConnection, error) {
+	if !auth.FromContext(ctx).Can(auth.PermListAccounts) {
+		return nil, errForbidden(ctx)
+	}
+	size, err := pageSize(first)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	page, err := r.server.accountClient.ListAccounts(ctx, account.ListAccountsQuery{
+		First: size,
+		After: optionalString(after),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := &AccountConnection{
+		Edges:      []*AccountEdge{},
+		PageInfo:   newPageInfo(page.Cursors, page.HasNextPage, after),
+		TotalCount: page.TotalCount,
+	}
+	for i, a := range page.Accounts {
+		res.Edges = append(res.Edges, &AccountEdge{Node: toAccount(a), Cursor: page.Cursors[i]})
+	}
+	return res, nil
+}
+
+func (r *queryResolver) RoleChanges(ctx context.Context, accountId *string, pagination *PaginationInput) ([]*RoleChange, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	skip, take := uint64(0), uint64(10) // Default values
+
+	if pagination != nil {
+		skip, take = pagination.bounds()
+	}
+
+	res, err := r.server.accountClient.GetRoleChanges(ctx, optionalString(accountId), skip, take)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []*RoleChange{}
+	for _, c := range res {
+		changes = append(changes, toRoleChange(c))
+	}
+	return changes, nil
+}
+
 func (r *queryResolver) Products(ctx context.Context, pagination *PaginationInput, query, id *string) ([]*Product, error) {
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 
@@ -59,12 +119,7 @@ func (r *queryResolver) Products(ctx context.Context, pagination *PaginationInpu
 			return nil, err
 		}
 
-		return []*Product{{
-			ID:          res.ID,
-			Name:        res.Name,
-			Description: res.Description,
-			Price:       res.Price,
-		}}, nil
+		return []*Product{toProduct(res)}, nil
 	}
 
 	skip, take := uint64(0), uint64(10) // Default values
@@ -80,12 +135,7 @@ func (r *queryResolver) Products(ctx context.Context, pagination *PaginationInpu
 		}
 		var products []*Product
 		for _, p := range res {
-			products = append(products, &Product{
-				ID:          p.ID,
-				Name:        p.Name,
-				Description: p.Description,
-				Price:       p.Price,
-			})
+			products = append(products, toProduct(p))
 		}
 		return products, nil
 	}
@@ -97,17 +147,137 @@ func (r *queryResolver) Products(ctx context.Context, pagination *PaginationInpu
 
 	var products []*Product
 	for _, p := range res {
-		products = append(products, &Product{
-			ID:          p.ID,
-			Name:        p.Name,
-			Description: p.Description,
-			Price:       p.Price,
-		})
+		products = append(products, toProduct(p))
 	}
 
 	return products, nil
 }
 
+func (r *queryResolver) ProductsConnection(ctx context.Context, query *string, first *int, after *string) (*ProductConnection, error) {
+	size, err := pageSize(first)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	page, err := r.server.catalogClient.ListProducts(ctx, catalog.ListProductsQuery{
+		Query: optionalString(query),
+		First: size,
+		After: optionalString(after),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := &ProductConnection{
+		Edges:      []*ProductEdge{},
+		PageInfo:   newPageInfo(page.Cursors, page.HasNextPage, after),
+		TotalCount: page.TotalCount,
+	}
+	for i, p := range page.Products {
+		res.Edges = append(res.Edges, &ProductEdge{Node: toProduct(p), Cursor: page.Cursors[i]})
+	}
+	return res, nil
+}
+
+func (r *queryResolver) Order(ctx context.Context, id string) (*Order, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	o, err := r.server.orderClient.GetOrder(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// Orders of other accounts are reported missing so their IDs can't be probed
+	if err := requireAccountAccess(ctx, o.AccountId); err != nil {
+		return nil, order.ErrOrderNotFound.WithResource("order", id)
+	}
+
+	return toOrder(o), nil
+}
+
+func (r *queryResolver) Orders(ctx context.Context, filter *OrderFilter, sort *OrderSort, first *int, after *string) (*OrderPage, error) {
+	page, err := r.listOrders(ctx, filter, sort, first, after)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &OrderPage{
+		Orders:      []*Order{},
+		HasNextPage: page.HasNextPage,
+	}
+	if page.EndCursor != "" {
+		res.EndCursor = &page.EndCursor
+	}
+	for _, o := range page.Orders {
+		res.Orders = append(res.Orders, toOrder(o))
+	}
+	return res, nil
+}
+
+func (r *queryResolver) OrdersConnection(ctx context.Context, filter *OrderFilter, sort *OrderSort, first *int, after *string) (*OrderConnection, error) {
+	page, err := r.listOrders(ctx, filter, sort, first, after)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &OrderConnection{
+		Edges:      []*OrderEdge{},
+		PageInfo:   newPageInfo(page.Cursors, page.HasNextPage, after),
+		TotalCount: page.TotalCount,
+	}
+	for i, o := range page.Orders {
+		res.Edges = append(res.Edges, &OrderEdge{Node: toOrder(o), Cursor: page.Cursors[i]})
+	}
+	return res, nil
+}
+
+func (r *queryResolver) listOrders(ctx context.Context, filter *OrderFilter, sort *OrderSort, first *int, after *string) (*order.OrderPage, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	size, err := pageSize(first)
+	if err != nil {
+		return nil, err
+	}
+	query := order.ListOrdersQuery{First: size, After: optionalString(after)}
+	if sort != nil {
+		query.Sort = order.OrderSort(strings.ToLower(string(*sort)))
+	}
+
+	if filter != nil {
+		query.Filter.AccountId = optionalString(filter.AccountID)
+		for _, status := range filter.Statuses {
+			query.Filter.Statuses = append(query.Filter.Statuses, fromOrderStatus(status))
+		}
+		if filter.CreatedAfter != nil {
+			query.Filter.CreatedAfter = *filter.CreatedAfter
+		}
+		if filter.CreatedBefore != nil {
+			query.Filter.CreatedBefore = *filter.CreatedBefore
+		}
+		if filter.MinTotal != nil {
+			minTotal, err := filter.MinTotal.parse()
+			if err != nil {
+				return nil, err
+			}
+			query.Filter.MinTotal = &minTotal
+		}
+	}
+
+	// Everyone but admins is limited to their own orders
+	if p := auth.FromContext(ctx); !p.IsAdmin() {
+		if query.Filter.AccountId != "" && query.Filter.AccountId != p.AccountId {
+			return nil, errForbidden(ctx)
+		}
+		query.Filter.AccountId = p.AccountId
+	}
+
+	return r.server.orderClient.ListOrders(ctx, query)
+}
+
 func (p *PaginationInput) bounds() (uint64, uint64) {
 	skipValue := uint64(0)
 	takeValue := uint64(0)
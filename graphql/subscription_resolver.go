@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/fabian-emmanuel/go-ms/errs"
+	"io"
+	"sync"
+	"time"
+)
+
+// maxPendingOrders is how many changed orders a subscriber may fall behind by
+// before its subscription is ended.
+const maxPendingOrders = 100
+
+var errSubscriberTooSlow = errs.ResourceExhausted("subscriber fell too far behind, subscribe again")
+
+type subscriptionResolver struct {
+	server *Server
+}
+
+func (r *subscriptionResolver) OrderUpdated(ctx context.Context, orderID string) (<-chan *Order, error) {
+	if err := requireWebsocket(ctx); err != nil {
+		return nil, err
+	}
+
+	lookupCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	o, err := r.server.orderClient.GetOrder(lookupCtx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireAccountAccess(ctx, o.AccountId); err != nil {
+		return nil, err
+	}
+
+	return r.watchOrders(ctx, orderID, "")
+}
+
+func (r *subscriptionResolver) OrdersForAccount(ctx context.Context, accountID string) (<-chan *Order, error) {
+	if err := requireWebsocket(ctx); err != nil {
+		return nil, err
+	}
+	if err := requireAccountAccess(ctx, accountID); err != nil {
+		return nil, err
+	}
+
+	return r.watchOrders(ctx, "", accountID)
+}
+
+// watchOrders relays the orders the order service streams until ctx is done.
+// The stream is always read promptly so the order service never waits on a
+// slow subscriber; orders queue up here instead, and the subscription ends
+// with errSubscriberTooSlow once too many are waiting.
+func (r *subscriptionResolver) watchOrders(ctx context.Context, orderId, accountId string) (<-chan *Order, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	stream, err := r.server.orderClient.WatchOrders(ctx, orderId, accountId)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	queue := newOrderQueue(maxPendingOrders)
+	go func() {
+		for {
+			o, err := stream.Recv()
+			if err != nil {
+				queue.close(err)
+				return
+			}
+			if !queue.push(toOrder(o)) {
+				return
+			}
+		}
+	}()
+
+	orders := make(chan *Order)
+	go func() {
+		defer close(orders)
+		defer cancel()
+		for {
+			o, err := queue.pop(ctx)
+			if err != nil {
+				// Errors must be added before the channel closes to reach the client
+				if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+					transport.AddSubscriptionError(ctx, presentError(ctx, err))
+				}
+				return
+			}
+			select {
+			case orders <- o:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return orders, nil
+}
+
+// orderQueue holds the orders waiting for a subscriber in the order they
+// first changed. An order that changes again while waiting keeps its place
+// and is only delivered in its latest state.
+type orderQueue struct {
+	limit  int
+	signal chan struct{}
+
+	mu     sync.Mutex
+	ids    []string
+	orders map[string]*Order
+	err    error
+}
+
+func newOrderQueue(limit int) *orderQueue {
+	return &orderQueue{
+		limit:  limit,
+		signal: make(chan struct{}, 1),
+		orders: map[string]*Order{},
+	}
+}
+
+// push queues o and reports whether the queue is still open. Overflowing the
+// queue drops everything in it and closes it with errSubscriberTooSlow.
+func (q *orderQueue) push(o *Order) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	defer q.wake()
+
+	if q.err != nil {
+		return false
+	}
+	if _, ok := q.orders[o.ID]; !ok {
+		if len(q.ids) >= q.limit {
+			q.ids, q.orders, q.err = nil, nil, errSubscriberTooSlow
+			return false
+		}
+		q.ids = append(q.ids, o.ID)
+	}
+	q.orders[o.ID] = o
+	return true
+}
+
+// close ends the queue with err once the orders already in it are taken.
+func (q *orderQueue) close(err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	defer q.wake()
+
+	if q.err == nil {
+		q.err = err
+	}
+}
+
+func (q *orderQueue) wake() {
+	select {
+	case q.signal <- struct{}{}:
+	default:
+	}
+}
+
+// pop waits for the next order, failing with the error the queue was closed
+// with once it is empty.
+func (q *orderQueue) pop(ctx context.Context) (*Order, error) {
+	for {
+		q.mu.Lock()
+		if len(q.ids) > 0 {
+			id := q.ids[0]
+			q.ids = q.ids[1:]
+			o := q.orders[id]
+			delete(q.orders, id)
+			q.mu.Unlock()
+			return o, nil
+		}
+		err := q.err
+		q.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-q.signal:
+		}
+	}
+}
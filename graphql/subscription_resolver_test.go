@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+)
+
+func TestOrderQueue(t *testing.T) {
+	pending := func(id string) *Order { return &Order{ID: id, Status: OrderStatusPending} }
+	paid := func(id string) *Order { return &Order{ID: id, Status: OrderStatusPaid} }
+
+	tests := []struct {
+		name  string
+		limit int
+		push  []*Order
+		// open is how many of the pushes should report the queue still open
+		open int
+		want []*Order
+		err  error
+	}{
+		{"arrival order", 3, []*Order{pending("a"), pending("b"), pending("c")}, 3, []*Order{pending("a"), pending("b"), pending("c")}, io.EOF},
+		{"latest state in first place", 3, []*Order{pending("a"), pending("b"), paid("a")}, 3, []*Order{paid("a"), pending("b")}, io.EOF},
+		{"repeated orders within the limit", 2, []*Order{pending("a"), pending("b"), paid("a"), paid("b")}, 4, []*Order{paid("a"), paid("b")}, io.EOF},
+		{"overflow drops everything", 2, []*Order{pending("a"), pending("b"), pending("c")}, 2, nil, errSubscriberTooSlow},
+		{"pushes after overflow", 1, []*Order{pending("a"), pending("b"), pending("c")}, 1, nil, errSubscriberTooSlow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newOrderQueue(tt.limit)
+			open := 0
+			for _, o := range tt.push {
+				if q.push(o) {
+					open++
+				}
+			}
+			if open != tt.open {
+				t.Errorf("push reported open %d times, want %d", open, tt.open)
+			}
+			// Ending the stream must not drop what is already queued
+			q.close(io.EOF)
+
+			var got []*Order
+			for {
+				o, err := q.pop(context.Background())
+				if err != nil {
+					if !errors.Is(err, tt.err) {
+						t.Errorf("err = %v, want %v", err, tt.err)
+					}
+					break
+				}
+				got = append(got, o)
+			}
+			if !slices.EqualFunc(got, tt.want, func(a, b *Order) bool { return a.ID == b.ID && a.Status == b.Status }) {
+				t.Errorf("popped %v, want %v", orderStates(got), orderStates(tt.want))
+			}
+		})
+	}
+}
+
+func TestOrderQueuePopWaits(t *testing.T) {
+	q := newOrderQueue(1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := q.pop(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("pop from an empty queue = %v, want %v", err, context.Canceled)
+	}
+
+	popped := make(chan *Order)
+	go func() {
+		o, _ := q.pop(context.Background())
+		popped <- o
+	}()
+	q.push(&Order{ID: "a"})
+	if o := <-popped; o.ID != "a" {
+		t.Errorf("popped %s, want a", o.ID)
+	}
+
+	q.close(io.EOF)
+	if q.push(&Order{ID: "b"}) {
+		t.Error("push reported a closed queue open")
+	}
+}
+
+func orderStates(orders []*Order) []string {
+	var states []string
+	for _, o := range orders {
+		states = append(states, o.ID+":"+string(o.Status))
+	}
+	return states
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/fabian-emmanuel/go-ms/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = tracing.Tracer("github.com/fabian-emmanuel/go-ms/graphql")
+
+// tracingExtension opens a span per GraphQL operation and a child span per
+// resolver call, so the gRPC spans of each resolver nest under the field that
+// made them. Fields served straight from a parent object are not traced.
+type tracingExtension struct{}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.ResponseInterceptor
+	graphql.FieldInterceptor
+} = tracingExtension{}
+
+func (tracingExtension) ExtensionName() string {
+	return "Tracing"
+}
+
+func (tracingExtension) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (tracingExtension) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	if !graphql.HasOperationContext(ctx) {
+		return next(ctx)
+	}
+	oc := graphql.GetOperationContext(ctx)
+
+	name := "graphql"
+	attrs := []attribute.KeyValue{attribute.String("graphql.operation.name", oc.OperationName)}
+	if oc.Operation != nil {
+		operationType := string(oc.Operation.Operation)
+		name = operationType
+		if oc.OperationName != "" {
+			name += " " + oc.OperationName
+		}
+		attrs = append(attrs, attribute.String("graphql.operation.type", operationType))
+	}
+
+	ctx, span := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...))
+	defer span.End()
+
+	res := next(ctx)
+	if res != nil && len(res.Errors) > 0 {
+		span.SetStatus(codes.Error, res.Errors.Error())
+	}
+	return res
+}
+
+func (tracingExtension) InterceptField(ctx context.Context, next graphql.Resolver) (any, error) {
+	fc := graphql.GetFieldContext(ctx)
+	if fc == nil || !fc.IsResolver {
+		return next(ctx)
+	}
+
+	ctx, span := tracer.Start(ctx, fc.Object+"."+fc.Field.Name, trace.WithAttributes(
+		attribute.String("graphql.field.path", fc.Path().String()),
+	))
+	defer span.End()
+
+	res, err := next(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return res, err
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/fabian-emmanuel/go-ms/auth"
+	"github.com/gorilla/websocket"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// websocketDrainer ends subscriptions when the gateway shuts down.
+// http.Server.Shutdown neither closes nor waits for hijacked connections, so
+// every websocket request gets a context that is cancelled once shutdown
+// starts, which makes gqlgen send a normal closure and return.
+type websocketDrainer struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newWebsocketDrainer() *websocketDrainer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &websocketDrainer{ctx: ctx, cancel: cancel}
+}
+
+func (d *websocketDrainer) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !websocket.IsWebSocketUpgrade(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		d.wg.Add(1)
+		defer d.wg.Done()
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		stop := context.AfterFunc(d.ctx, cancel)
+		defer stop()
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Shutdown starts closing every open websocket.
+func (d *websocketDrainer) Shutdown() {
+	d.cancel()
+}
+
+// Wait blocks until every websocket has closed, or ctx is done.
+func (d *websocketDrainer) Wait(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
+
+type websocketKey struct{}
+
+// websocketInit authenticates a websocket with the Authorization field of its
+// connection_init payload, as browsers cannot set headers on websockets. A
+// connection without one keeps the principal of its upgrade request, if any,
+// and one with an invalid token is closed.
+func websocketInit(verifier *auth.Verifier) transport.WebsocketInitFunc {
+	return func(ctx context.Context, payload transport.InitPayload) (context.Context, *transport.InitPayload, error) {
+		ctx = context.WithValue(ctx, websocketKey{}, true)
+
+		header := payload.Authorization()
+		if header == "" {
+			return ctx, nil, nil
+		}
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok {
+			return ctx, nil, errors.New("malformed Authorization")
+		}
+
+		token = strings.TrimSpace(token)
+		claims, err := verifier.Verify(ctx, token)
+		if err != nil {
+			slog.WarnContext(ctx, "Error verifying access token", "err", err)
+			return ctx, nil, errors.New("invalid access token")
+		}
+
+		p := auth.PrincipalFromClaims(claims)
+		ctx = auth.WithToken(auth.NewContext(ctx, p), token)
+
+		// Charge the account rather than the IP the upgrade came from
+		if client := clientFromContext(ctx); strings.HasPrefix(client.id, "ip:") {
+			ctx = context.WithValue(ctx, budgetClientKey{}, budgetClient{id: "account:" + p.AccountId})
+		}
+		return ctx, nil, nil
+	}
+}
+
+// requireWebsocket fails unless the operation arrived over a websocket, the
+// only transport subscriptions are served on.
+func requireWebsocket(ctx context.Context) error {
+	if ctx.Value(websocketKey{}) != nil {
+		return nil
+	}
+	return &gqlerror.Error{
+		Message:    "subscriptions are only served over websockets",
+		Path:       graphql.GetPath(ctx),
+		Extensions: map[string]any{"code": "WEBSOCKET_REQUIRED"},
+	}
+}
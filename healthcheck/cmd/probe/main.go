@@ -0,0 +1,47 @@
+// Command probe checks a gRPC server's grpc.health.v1 status and exits
+// non-zero unless it is SERVING, for use as a container health check. It reads
+// the same TLS_* variables as the services.
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/fabian-emmanuel/go-ms/healthcheck"
+	"github.com/fabian-emmanuel/go-ms/mtls"
+	"github.com/kelseyhightower/envconfig"
+	"google.golang.org/grpc"
+	"log"
+	"time"
+)
+
+type Config struct {
+	TLS mtls.Config
+}
+
+func main() {
+	addr := flag.String("addr", "localhost:8080", "address of the gRPC server")
+	service := flag.String("service", "", "service to check; the whole server when empty")
+	timeout := flag.Duration("timeout", 3*time.Second, "how long to wait for an answer")
+	flag.Parse()
+
+	var config Config
+	if err := envconfig.Process("", &config); err != nil {
+		log.Fatal(err)
+	}
+
+	transport, err := config.TLS.DialOption(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+	conn, err := grpc.NewClient(*addr, transport)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	if err := healthcheck.Check(ctx, conn, *service); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,74 @@
+package healthcheck
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"log/slog"
+	"time"
+)
+
+const (
+	checkInterval = 5 * time.Second
+	checkTimeout  = 2 * time.Second
+)
+
+// Register adds grpc.health.v1 to serv and keeps the status of service, and of
+// the server as a whole, in step with ping until ctx is done: SERVING while
+// ping succeeds, NOT_SERVING while it fails.
+func Register(ctx context.Context, serv *grpc.Server, service string, ping func(ctx context.Context) error) *health.Server {
+	h := health.NewServer()
+	healthpb.RegisterHealthServer(serv, h)
+	h.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	h.SetServingStatus(service, healthpb.HealthCheckResponse_NOT_SERVING)
+
+	go func() {
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+
+		current := healthpb.HealthCheckResponse_UNKNOWN
+		for {
+			pingCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+			err := ping(pingCtx)
+			cancel()
+
+			status := healthpb.HealthCheckResponse_SERVING
+			if err != nil {
+				status = healthpb.HealthCheckResponse_NOT_SERVING
+			}
+			if status != current {
+				if err != nil {
+					slog.Warn("Health check failed", "grpc_service", service, "err", err)
+				} else {
+					slog.Info("Serving", "grpc_service", service)
+				}
+				current = status
+				h.SetServingStatus("", status)
+				h.SetServingStatus(service, status)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return h
+}
+
+// Check asks the server behind conn for the health of service and fails
+// unless it is SERVING.
+func Check(ctx context.Context, conn grpc.ClientConnInterface, service string) error {
+	res, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		return err
+	}
+	if res.Status != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("%s is %s", service, res.Status)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc/metadata"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// MetadataKey is the gRPC metadata entry carrying a caller's idempotency key.
+const MetadataKey = "idempotency-key"
+
+const (
+	// DefaultTTL is how long a key and its response are kept for replay.
+	DefaultTTL = 24 * time.Hour
+	// lockTimeout is how long a key stays claimed by a request that never finished
+	// (e.g. the process died) before a retry with the same payload may take it over.
+	lockTimeout  = time.Minute
+	maxKeyLength = 255
+)
+
+var (
+	ErrKeyReused     = errors.New("idempotency key was already used with a different request")
+	ErrKeyInProgress = errors.New("a request with this idempotency key is still in progress")
+)
+
+// Record is a stored idempotency key. Response is nil while the first request
+// holding the key is still running.
+type Record struct {
+	Method      string
+	Key         string
+	RequestHash []byte
+	Response    []byte
+	LockedUntil time.Time
+	ExpiresAt   time.Time
+}
+
+// Store keeps idempotency records in a service's own datastore.
+type Store interface {
+	// ReserveIdempotencyKey claims r.Key for r.Method. If the key is held by a
+	// live record, that record is returned with reserved set to false.
+	ReserveIdempotencyKey(ctx context.Context, r Record) (existing *Record, reserved bool, err error)
+	CompleteIdempotencyKey(ctx context.Context, method, key string, response []byte) error
+	// ReleaseIdempotencyKey drops a claim whose request failed so it can be retried.
+	ReleaseIdempotencyKey(ctx context.Context, method, key string) error
+	DeleteExpiredIdempotencyKeys(ctx context.Context) error
+}
+
+// WithKey attaches key to the outgoing gRPC metadata of ctx.
+func WithKey(ctx context.Context, key string) context.Context {
+	if key == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, MetadataKey, key)
+}
+
+// KeyFromContext returns the idempotency key sent with an incoming gRPC call.
+func KeyFromContext(ctx context.Context) string {
+	values := metadata.ValueFromIncomingContext(ctx, MetadataKey)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// LoadHashKey reads the key requests are hashed with from path. Without a
+// path a random key is generated, so keys sent before a restart can no longer
+// be replayed.
+func LoadHashKey(path string) ([]byte, error) {
+	if path == "" {
+		slog.Warn("No request hash key configured, generating a temporary one")
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+
+	key, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request hash key: %w", err)
+	}
+	if len(key) < 32 {
+		return nil, errors.New("request hash key must be at least 32 bytes")
+	}
+	return key, nil
+}
+
+// RunJanitor deletes expired keys from store every interval until ctx is cancelled.
+func RunJanitor(ctx context.Context, store Store, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := store.DeleteExpiredIdempotencyKeys(ctx); err != nil {
+				slog.ErrorContext(ctx, "Error deleting expired idempotency keys", "err", err)
+			}
+		}
+	}
+}
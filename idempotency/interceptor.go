@@ -0,0 +1,157 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/fabian-emmanuel/go-ms/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// UnaryServerInterceptor makes the given methods idempotent for callers that
+// send an idempotency key. The first successful response is stored for ttl and
+// replayed for retries with the same key and request; a different request under
+// the same key is rejected with InvalidArgument. Requests are compared by
+// their HMAC under hashKey, which must be kept secret and shared by every
+// instance of a service whose requests carry secrets. Keys are scoped to the account
+// of the caller, so it must run after auth.UnaryServerInterceptor.
+func UnaryServerInterceptor(store Store, ttl time.Duration, hashKey []byte, methods ...string) grpc.UnaryServerInterceptor {
+	guarded := map[string]bool{}
+	for _, m := range methods {
+		guarded[m] = true
+	}
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		key := KeyFromContext(ctx)
+		if key == "" || !guarded[info.FullMethod] {
+			return handler(ctx, req)
+		}
+		if len(key) > maxKeyLength {
+			return nil, status.Errorf(codes.InvalidArgument, "idempotency key must be at most %d characters", maxKeyLength)
+		}
+
+		hash, err := requestHash(req, hashKey)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		// Replays skip the handler and its access checks, so one caller must
+		// never be able to reach another's stored response
+		key = scopedKey(ctx, key)
+
+		now := time.Now().UTC()
+		existing, reserved, err := store.ReserveIdempotencyKey(ctx, Record{
+			Method:      info.FullMethod,
+			Key:         key,
+			RequestHash: hash,
+			LockedUntil: now.Add(lockTimeout),
+			ExpiresAt:   now.Add(ttl),
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Error reserving idempotency key", "err", err)
+			return nil, status.Error(codes.Unavailable, "could not check idempotency key")
+		}
+
+		if !reserved {
+			if !bytes.Equal(existing.RequestHash, hash) {
+				return nil, status.Error(codes.InvalidArgument, ErrKeyReused.Error())
+			}
+			if existing.Response == nil {
+				return nil, status.Error(codes.Aborted, ErrKeyInProgress.Error())
+			}
+			return replay(info.FullMethod, existing.Response)
+		}
+
+		// Record the outcome even if the caller hangs up mid-request
+		storeCtx := context.WithoutCancel(ctx)
+
+		res, err := handler(ctx, req)
+		if err != nil {
+			if err := store.ReleaseIdempotencyKey(storeCtx, info.FullMethod, key); err != nil {
+				slog.ErrorContext(ctx, "Error releasing idempotency key", "err", err)
+			}
+			return nil, err
+		}
+
+		body, err := proto.Marshal(res.(proto.Message))
+		if err == nil {
+			err = store.CompleteIdempotencyKey(storeCtx, info.FullMethod, key, body)
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Error storing idempotent response", "err", err)
+		}
+
+		return res, nil
+	}
+}
+
+// scopedKey derives the stored key from the caller's key and account. Keys of
+// anonymous callers share one scope; their requests are only replayed to
+// callers who send exactly the same request.
+func scopedKey(ctx context.Context, key string) string {
+	owner := ""
+	if p := auth.FromContext(ctx); p != nil {
+		owner = p.AccountId
+	}
+	sum := sha256.Sum256([]byte(owner + "\x00" + key))
+	return hex.EncodeToString(sum[:])
+}
+
+// requestHash keys the digest of req with hashKey, so that stored hashes of
+// requests carrying secrets such as passwords cannot be used to guess them.
+func requestHash(req any, hashKey []byte) ([]byte, error) {
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("request %T is not a protobuf message", req)
+	}
+	body, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	mac := hmac.New(sha256.New, hashKey)
+	mac.Write(body)
+	return mac.Sum(nil), nil
+}
+
+// replay decodes a stored response into the output type of fullMethod.
+func replay(fullMethod string, body []byte) (any, error) {
+	service, method, ok := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if !ok {
+		return nil, status.Errorf(codes.Internal, "malformed method name %q", fullMethod)
+	}
+
+	desc, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "unknown service %q", service)
+	}
+	sd, ok := desc.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, status.Errorf(codes.Internal, "%q is not a service", service)
+	}
+	md := sd.Methods().ByName(protoreflect.Name(method))
+	if md == nil {
+		return nil, status.Errorf(codes.Internal, "unknown method %q", fullMethod)
+	}
+
+	mt, err := protoregistry.GlobalTypes.FindMessageByName(md.Output().FullName())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "unknown response type for %q", fullMethod)
+	}
+
+	res := mt.New().Interface()
+	if err := proto.Unmarshal(body, res); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to decode stored response: %v", err)
+	}
+	return res, nil
+}
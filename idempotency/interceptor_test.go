@@ -0,0 +1,143 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"github.com/fabian-emmanuel/go-ms/account/pb"
+	"github.com/fabian-emmanuel/go-ms/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memoryStore struct {
+	mu      sync.Mutex
+	records map[string]*Record
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{records: map[string]*Record{}}
+}
+
+func (s *memoryStore) ReserveIdempotencyKey(_ context.Context, r Record) (*Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.records[r.Method+r.Key]; ok {
+		return existing, false, nil
+	}
+	s.records[r.Method+r.Key] = &r
+	return nil, true, nil
+}
+
+func (s *memoryStore) CompleteIdempotencyKey(_ context.Context, method, key string, response []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[method+key].Response = response
+	return nil
+}
+
+func (s *memoryStore) ReleaseIdempotencyKey(_ context.Context, method, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, method+key)
+	return nil
+}
+
+func (s *memoryStore) DeleteExpiredIdempotencyKeys(context.Context) error {
+	return nil
+}
+
+func TestRequestHash(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	req := &pb.PostAccountRequest{Name: "Ada", Email: "ada@example.com", Password: "correct horse"}
+	hash, err := requestHash(req, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		req  *pb.PostAccountRequest
+		key  []byte
+		same bool
+	}{
+		{"same request", &pb.PostAccountRequest{Name: "Ada", Email: "ada@example.com", Password: "correct horse"}, key, true},
+		{"other password", &pb.PostAccountRequest{Name: "Ada", Email: "ada@example.com", Password: "battery staple"}, key, false},
+		{"no password", &pb.PostAccountRequest{Name: "Ada", Email: "ada@example.com"}, key, false},
+		{"other email", &pb.PostAccountRequest{Name: "Ada", Email: "ada@example.org", Password: "correct horse"}, key, false},
+		{"other hash key", &pb.PostAccountRequest{Name: "Ada", Email: "ada@example.com", Password: "correct horse"}, []byte("another key"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := requestHash(tt.req, tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Equal(got, hash) != tt.same {
+				t.Errorf("hash equal = %v, want %v", !tt.same, tt.same)
+			}
+		})
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	method := pb.AccountService_PostAccount_FullMethodName
+	first := &pb.PostAccountRequest{Name: "Ada", Email: "ada@example.com", Password: "correct horse"}
+	alice := &auth.Principal{AccountId: "alice"}
+	bob := &auth.Principal{AccountId: "bob"}
+
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		req       *pb.PostAccountRequest
+		// replayed is whether the first response comes back without the
+		// handler running again
+		replayed bool
+		code     codes.Code
+	}{
+		{"retry", alice, first, true, codes.OK},
+		{"other password", alice, &pb.PostAccountRequest{Name: "Ada", Email: "ada@example.com", Password: "battery staple"}, false, codes.InvalidArgument},
+		{"other caller", bob, first, false, codes.OK},
+		{"anonymous caller", nil, first, false, codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interceptor := UnaryServerInterceptor(newMemoryStore(), DefaultTTL, []byte("0123456789abcdef0123456789abcdef"), method)
+			calls := 0
+			handler := func(context.Context, any) (any, error) {
+				calls++
+				return &pb.PostAccountResponse{Account: &pb.Account{Id: time.Now().String()}}, nil
+			}
+			call := func(p *auth.Principal, req *pb.PostAccountRequest) (any, error) {
+				ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataKey, "key1"))
+				if p != nil {
+					ctx = auth.NewContext(ctx, p)
+				}
+				return interceptor(ctx, req, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+			}
+
+			want, err := call(alice, first)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := call(tt.principal, tt.req)
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("code = %s, want %s", code, tt.code)
+			}
+			if err != nil {
+				return
+			}
+			if replayed := calls == 1; replayed != tt.replayed {
+				t.Errorf("replayed = %v, want %v", replayed, tt.replayed)
+			}
+			if tt.replayed && !proto.Equal(res.(proto.Message), want.(proto.Message)) {
+				t.Errorf("replayed %v, want %v", res, want)
+			}
+		})
+	}
+}
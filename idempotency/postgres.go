@@ -0,0 +1,75 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type postgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore keeps idempotency records in the idempotency_keys table.
+func NewPostgresStore(db *sql.DB) Store {
+	return &postgresStore{db: db}
+}
+
+func (s *postgresStore) ReserveIdempotencyKey(ctx context.Context, r Record) (*Record, bool, error) {
+	// Take over rows that expired, or that were abandoned mid-request by a retry of the same request
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO idempotency_keys(method, idempotency_key, request_hash, locked_until, expires_at)
+		VALUES($1, $2, $3, $4, $5)
+		ON CONFLICT (method, idempotency_key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash, response = NULL,
+		    locked_until = EXCLUDED.locked_until, expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at < $6
+		   OR (idempotency_keys.response IS NULL AND idempotency_keys.locked_until < $6
+		       AND idempotency_keys.request_hash = EXCLUDED.request_hash)`,
+		r.Method, r.Key, r.RequestHash, r.LockedUntil, r.ExpiresAt, time.Now().UTC())
+	if err != nil {
+		return nil, false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return nil, false, err
+	}
+	if affected > 0 {
+		return nil, true, nil
+	}
+
+	existing := &Record{Method: r.Method, Key: r.Key}
+	err = s.db.QueryRowContext(ctx,
+		"SELECT request_hash, response, locked_until, expires_at FROM idempotency_keys WHERE method = $1 AND idempotency_key = $2",
+		r.Method, r.Key,
+	).Scan(&existing.RequestHash, &existing.Response, &existing.LockedUntil, &existing.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Deleted between the two statements; report it as busy and let the caller retry
+		return &Record{RequestHash: r.RequestHash}, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return existing, false, nil
+}
+
+func (s *postgresStore) CompleteIdempotencyKey(ctx context.Context, method, key string, response []byte) error {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE idempotency_keys SET response = $3 WHERE method = $1 AND idempotency_key = $2",
+		method, key, response)
+	return err
+}
+
+func (s *postgresStore) ReleaseIdempotencyKey(ctx context.Context, method, key string) error {
+	_, err := s.db.ExecContext(ctx,
+		"DELETE FROM idempotency_keys WHERE method = $1 AND idempotency_key = $2 AND response IS NULL",
+		method, key)
+	return err
+}
+
+func (s *postgresStore) DeleteExpiredIdempotencyKeys(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE expires_at < $1", time.Now().UTC())
+	return err
+}
@@ -0,0 +1,96 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// DefaultShutdownTimeout is how long in-flight requests get to finish when no
+// SHUTDOWN_TIMEOUT is configured.
+const DefaultShutdownTimeout = 30 * time.Second
+
+// SignalContext returns a context that is cancelled on SIGINT or SIGTERM.
+func SignalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// ServeGRPC serves on lis until ctx is done and then drains the server: health
+// checks switch to NOT_SERVING so no new traffic is routed here, in-flight RPCs
+// get up to timeout to finish, and whatever is still running after that is
+// cut off.
+func ServeGRPC(ctx context.Context, serv *grpc.Server, lis net.Listener, h *health.Server, timeout time.Duration) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- serv.Serve(lis)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down, draining in-flight RPCs")
+	h.Shutdown()
+
+	stopped := make(chan struct{})
+	go func() {
+		serv.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(Timeout(timeout)):
+		slog.Warn("Shutdown deadline exceeded, closing remaining RPCs", "timeout", Timeout(timeout))
+		serv.Stop()
+		<-stopped
+	}
+	return <-errs
+}
+
+// ServeHTTP is ServeGRPC for an http.Server. Hijacked connections such as
+// websockets are not drained here; see http.Server.RegisterOnShutdown.
+func ServeHTTP(ctx context.Context, srv *http.Server, timeout time.Duration) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down, draining in-flight requests")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), Timeout(timeout))
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("Shutdown deadline exceeded, closing remaining connections", "timeout", Timeout(timeout))
+		_ = srv.Close()
+	}
+
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Timeout returns timeout, or DefaultShutdownTimeout when it is not set.
+func Timeout(timeout time.Duration) time.Duration {
+	if timeout <= 0 {
+		return DefaultShutdownTimeout
+	}
+	return timeout
+}
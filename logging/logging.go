@@ -0,0 +1,54 @@
+package logging
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"os"
+)
+
+// Config is meant to be a field named Log in a service's envconfig Config,
+// which reads the level from LOG_LEVEL (debug, info, warn or error).
+type Config struct {
+	Level slog.Level `envconfig:"LEVEL" default:"info"`
+}
+
+// Setup makes a JSON logger writing to stderr the default for both log/slog
+// and the standard log package. Every record carries the service name, the
+// request and trace IDs found on its context, and has sensitive values
+// redacted.
+func Setup(service string, c Config) {
+	handler := slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
+		Level:       c.Level,
+		ReplaceAttr: redact,
+	})
+	logger := slog.New(&contextHandler{handler}).With("service", service)
+	slog.SetDefault(logger)
+}
+
+// contextHandler adds the request ID and the active trace and span IDs to
+// records logged with a context.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestIDFromContext(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/fabian-emmanuel/go-ms/lifecycle"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"time"
+)
+
+// Handler serves everything registered with the default registry, including
+// the Go runtime and process collectors.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Serve exposes /metrics on port until ctx is done. It is for services whose
+// main listener speaks gRPC; the gateway mounts Handler on its own mux.
+func Serve(ctx context.Context, port int, shutdownTimeout time.Duration) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	srv := &http.Server{
+		Addr:    fmt.Sprintf(":%d", port),
+		Handler: mux,
+	}
+	return lifecycle.ServeHTTP(ctx, srv, shutdownTimeout)
+}
+
+// RegisterDB exports the connection pool statistics of db, labelled with name.
+// Registering the same name twice is a no-op.
+func RegisterDB(db *sql.DB, name string) error {
+	err := prometheus.Register(collectors.NewDBStatsCollector(db, name))
+	if are := (prometheus.AlreadyRegisteredError{}); errors.As(err, &are) {
+		return nil
+	}
+	return err
+}
+
+var externalRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "external_request_duration_seconds",
+	Help:    "Latency of HTTP requests to backing services such as Elasticsearch.",
+	Buckets: prometheus.DefBuckets,
+}, []string{"backend", "method", "code"})
+
+// InstrumentTransport records the latency of every request made through next
+// under backend. A nil next means http.DefaultTransport.
+func InstrumentTransport(backend string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	observer := externalRequestDuration.MustCurryWith(prometheus.Labels{"backend": backend})
+	return promhttp.InstrumentRoundTripperDuration(observer, next)
+}
@@ -0,0 +1,173 @@
+//go:generate protoc -I .. --go_out=.. --go_opt=module=github.com/fabian-emmanuel/go-ms ../money/money.proto
+
+package money
+
+import (
+	"fmt"
+	"github.com/fabian-emmanuel/go-ms/errs"
+	"github.com/fabian-emmanuel/go-ms/money/pb"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidCurrency  = errs.InvalidArgument("invalid currency code")
+	ErrInvalidAmount    = errs.InvalidArgument("invalid amount")
+	ErrCurrencyMismatch = errs.InvalidArgument("currency mismatch")
+	ErrOverflow         = errs.InvalidArgument("amount out of range")
+)
+
+// Money is an exact monetary amount held as an integer number of minor
+// units (e.g. cents or kobo) of an ISO 4217 currency.
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// minorUnitExceptions lists ISO 4217 currencies whose minor unit is not two decimal places.
+var minorUnitExceptions = map[string]int{
+	"BHD": 3, "BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "IQD": 3, "ISK": 0,
+	"JOD": 3, "JPY": 0, "KMF": 0, "KRW": 0, "KWD": 3, "LYD": 3, "OMR": 3,
+	"PYG": 0, "RWF": 0, "TND": 3, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0,
+	"XOF": 0, "XPF": 0,
+}
+
+func New(amount int64, currency string) (Money, error) {
+	currency, err := NormalizeCurrency(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+func Zero(currency string) Money {
+	return Money{Currency: strings.ToUpper(currency)}
+}
+
+// Parse reads a decimal string such as "1999.50" into minor units without going through float64.
+func Parse(amount, currency string) (Money, error) {
+	currency, err := NormalizeCurrency(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	exponent := MinorUnits(currency)
+
+	s := strings.TrimSpace(amount)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+
+	whole, fraction, _ := strings.Cut(s, ".")
+	if whole == "" && fraction == "" {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, amount)
+	}
+	if len(fraction) > exponent {
+		return Money{}, fmt.Errorf("%w: %q has more than %d decimal places for %s", ErrInvalidAmount, amount, exponent, currency)
+	}
+	fraction += strings.Repeat("0", exponent-len(fraction))
+
+	digits := whole + fraction
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, amount)
+		}
+	}
+
+	units, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("%w: %q", ErrOverflow, amount)
+	}
+	if negative {
+		units = -units
+	}
+
+	return Money{Amount: units, Currency: currency}, nil
+}
+
+func NormalizeCurrency(currency string) (string, error) {
+	code := strings.ToUpper(strings.TrimSpace(currency))
+	if len(code) != 3 {
+		return "", fmt.Errorf("%w: %q", ErrInvalidCurrency, currency)
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return "", fmt.Errorf("%w: %q", ErrInvalidCurrency, currency)
+		}
+	}
+	return code, nil
+}
+
+// MinorUnits returns the number of decimal places used by the currency.
+func MinorUnits(currency string) int {
+	if exponent, ok := minorUnitExceptions[currency]; ok {
+		return exponent
+	}
+	return 2
+}
+
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+	}
+	if (other.Amount > 0 && m.Amount > math.MaxInt64-other.Amount) ||
+		(other.Amount < 0 && m.Amount < math.MinInt64-other.Amount) {
+		return Money{}, ErrOverflow
+	}
+	return Money{Amount: m.Amount + other.Amount, Currency: m.Currency}, nil
+}
+
+func (m Money) Multiply(n int64) (Money, error) {
+	if n != 0 && m.Amount != 0 {
+		product := m.Amount * n
+		if product/n != m.Amount || (m.Amount == -1 && n == math.MinInt64) || (n == -1 && m.Amount == math.MinInt64) {
+			return Money{}, ErrOverflow
+		}
+		return Money{Amount: product, Currency: m.Currency}, nil
+	}
+	return Money{Currency: m.Currency}, nil
+}
+
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// Decimal formats the amount in major units, e.g. 199950 USD as "1999.50".
+func (m Money) Decimal() string {
+	exponent := MinorUnits(m.Currency)
+	units := m.Amount
+	sign := ""
+	if units < 0 {
+		sign = "-"
+	}
+
+	digits := strconv.FormatUint(absUint64(units), 10)
+	if exponent == 0 {
+		return sign + digits
+	}
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-exponent] + "." + digits[len(digits)-exponent:]
+}
+
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+func FromProto(p *pb.Money) Money {
+	if p == nil {
+		return Money{}
+	}
+	return Money{Amount: p.Amount, Currency: p.Currency}
+}
+
+func (m Money) Proto() *pb.Money {
+	return &pb.Money{Amount: m.Amount, Currency: m.Currency}
+}
+
+func absUint64(n int64) uint64 {
+	if n < 0 {
+		return uint64(-(n + 1)) + 1
+	}
+	return uint64(n)
+}
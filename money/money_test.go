@@ -0,0 +1,122 @@
+package money
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		want     Money
+		err      error
+	}{
+		{"19.99", "usd", Money{1999, "USD"}, nil},
+		{"19.9", "USD", Money{1990, "USD"}, nil},
+		{"19", "USD", Money{1900, "USD"}, nil},
+		{".5", "USD", Money{50, "USD"}, nil},
+		{"-3.25", "EUR", Money{-325, "EUR"}, nil},
+		{"+3.25", "EUR", Money{325, "EUR"}, nil},
+		{" 7.00 ", "EUR", Money{700, "EUR"}, nil},
+		{"1500", "JPY", Money{1500, "JPY"}, nil},
+		{"1.234", "KWD", Money{1234, "KWD"}, nil},
+		{"1.999", "USD", Money{}, ErrInvalidAmount},
+		{"1.5", "JPY", Money{}, ErrInvalidAmount},
+		{"", "USD", Money{}, ErrInvalidAmount},
+		{".", "USD", Money{}, ErrInvalidAmount},
+		{"1e3", "USD", Money{}, ErrInvalidAmount},
+		{"1,00", "USD", Money{}, ErrInvalidAmount},
+		{"99999999999999999999", "USD", Money{}, ErrOverflow},
+		{"1.00", "US", Money{}, ErrInvalidCurrency},
+		{"1.00", "U5D", Money{}, ErrInvalidCurrency},
+	}
+	for _, tt := range tests {
+		t.Run(tt.amount+" "+tt.currency, func(t *testing.T) {
+			got, err := Parse(tt.amount, tt.currency)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdd(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Money
+		want Money
+		err  error
+	}{
+		{"sum", Money{150, "USD"}, Money{275, "USD"}, Money{425, "USD"}, nil},
+		{"negative", Money{150, "USD"}, Money{-275, "USD"}, Money{-125, "USD"}, nil},
+		{"currency mismatch", Money{150, "USD"}, Money{150, "EUR"}, Money{}, ErrCurrencyMismatch},
+		{"overflow", Money{math.MaxInt64, "USD"}, Money{1, "USD"}, Money{}, ErrOverflow},
+		{"underflow", Money{math.MinInt64, "USD"}, Money{-1, "USD"}, Money{}, ErrOverflow},
+		{"at the limit", Money{math.MaxInt64 - 1, "USD"}, Money{1, "USD"}, Money{math.MaxInt64, "USD"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.a.Add(tt.b)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMultiply(t *testing.T) {
+	tests := []struct {
+		name string
+		m    Money
+		n    int64
+		want Money
+		err  error
+	}{
+		{"quantity", Money{1999, "USD"}, 3, Money{5997, "USD"}, nil},
+		{"zero quantity", Money{1999, "USD"}, 0, Money{0, "USD"}, nil},
+		{"zero amount", Money{0, "USD"}, 5, Money{0, "USD"}, nil},
+		{"negative", Money{-250, "USD"}, 2, Money{-500, "USD"}, nil},
+		{"overflow", Money{math.MaxInt64 / 2, "USD"}, 3, Money{}, ErrOverflow},
+		{"min by -1", Money{math.MinInt64, "USD"}, -1, Money{}, ErrOverflow},
+		{"-1 by min", Money{-1, "USD"}, math.MinInt64, Money{}, ErrOverflow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.m.Multiply(tt.n)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	tests := []struct {
+		m    Money
+		want string
+	}{
+		{Money{199950, "USD"}, "1999.50"},
+		{Money{5, "USD"}, "0.05"},
+		{Money{0, "USD"}, "0.00"},
+		{Money{-5, "USD"}, "-0.05"},
+		{Money{1500, "JPY"}, "1500"},
+		{Money{1234, "KWD"}, "1.234"},
+		{Money{math.MinInt64, "USD"}, "-92233720368547758.08"},
+	}
+	for _, tt := range tests {
+		if got := tt.m.Decimal(); got != tt.want {
+			t.Errorf("%+v.Decimal() = %q, want %q", tt.m, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package money
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/fabian-emmanuel/go-ms/errs"
+	"github.com/fabian-emmanuel/go-ms/money/pb"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// rateScale is the number of decimal places kept when deriving a cross rate.
+const rateScale = 10
+
+var ErrRateNotFound = errs.FailedPrecondition("exchange rate not found")
+
+// Rate converts amounts in From into To. Value is an exact decimal string so
+// the rate snapshotted onto an order is precisely the one that was applied.
+type Rate struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Value string `json:"value"`
+}
+
+type RateProvider interface {
+	Rate(ctx context.Context, from, to string) (Rate, error)
+}
+
+// Convert applies the rate to m, rounding half away from zero to the minor unit of the target currency.
+func (r Rate) Convert(m Money) (Money, error) {
+	if m.Currency != r.From {
+		return Money{}, fmt.Errorf("%w: cannot apply %s->%s rate to %s", ErrCurrencyMismatch, r.From, r.To, m.Currency)
+	}
+
+	rate, ok := new(big.Rat).SetString(r.Value)
+	if !ok || rate.Sign() <= 0 {
+		return Money{}, fmt.Errorf("invalid exchange rate %q for %s->%s", r.Value, r.From, r.To)
+	}
+
+	amount := new(big.Rat).Mul(new(big.Rat).SetInt64(m.Amount), rate)
+	shift := MinorUnits(r.To) - MinorUnits(r.From)
+	scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(absInt(shift))), nil))
+	if shift >= 0 {
+		amount.Mul(amount, scale)
+	} else {
+		amount.Quo(amount, scale)
+	}
+
+	rounded, ok := new(big.Int).SetString(amount.FloatString(0), 10)
+	if !ok || !rounded.IsInt64() {
+		return Money{}, ErrOverflow
+	}
+
+	return Money{Amount: rounded.Int64(), Currency: r.To}, nil
+}
+
+func RateFromProto(p *pb.ExchangeRate) Rate {
+	if p == nil {
+		return Rate{}
+	}
+	return Rate{From: p.From, To: p.To, Value: p.Rate}
+}
+
+func (r Rate) Proto() *pb.ExchangeRate {
+	return &pb.ExchangeRate{From: r.From, To: r.To, Rate: r.Value}
+}
+
+type staticRateProvider struct {
+	base  string
+	rates map[string]*big.Rat
+}
+
+type staticRatesFile struct {
+	Base  string            `json:"base"`
+	Rates map[string]string `json:"rates"`
+}
+
+// NewStaticRateProvider loads rates from a JSON file of the form
+//
+//	{"base": "USD", "rates": {"NGN": "1530.25", "EUR": "0.92"}}
+//
+// where each rate is the number of units of that currency per unit of base.
+// An empty path yields a provider that only knows same-currency rates.
+func NewStaticRateProvider(path string) (RateProvider, error) {
+	p := &staticRateProvider{rates: map[string]*big.Rat{}}
+	if path == "" {
+		return p, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read exchange rates file::{%s}::%w", path, err)
+	}
+
+	var file staticRatesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse exchange rates file::{%s}::%w", path, err)
+	}
+
+	p.base, err = NormalizeCurrency(file.Base)
+	if err != nil {
+		return nil, err
+	}
+	p.rates[p.base] = big.NewRat(1, 1)
+
+	for currency, value := range file.Rates {
+		code, err := NormalizeCurrency(currency)
+		if err != nil {
+			return nil, err
+		}
+		rate, ok := new(big.Rat).SetString(strings.TrimSpace(value))
+		if !ok || rate.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid exchange rate %q for %s", value, code)
+		}
+		p.rates[code] = rate
+	}
+
+	return p, nil
+}
+
+func (p *staticRateProvider) Rate(_ context.Context, from, to string) (Rate, error) {
+	if from == to {
+		return Rate{From: from, To: to, Value: "1"}, nil
+	}
+
+	fromRate, ok := p.rates[from]
+	if !ok {
+		return Rate{}, fmt.Errorf("%w: %s->%s", ErrRateNotFound, from, to)
+	}
+	toRate, ok := p.rates[to]
+	if !ok {
+		return Rate{}, fmt.Errorf("%w: %s->%s", ErrRateNotFound, from, to)
+	}
+
+	value := new(big.Rat).Quo(toRate, fromRate)
+	return Rate{From: from, To: to, Value: trimDecimal(value.FloatString(rateScale))}, nil
+}
+
+func trimDecimal(s string) string {
+	if !strings.Contains(s, ".") {
+		return s
+	}
+	return strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+}
+
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,78 @@
+package money
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRateConvert(t *testing.T) {
+	tests := []struct {
+		name string
+		rate Rate
+		m    Money
+		want Money
+		err  bool
+	}{
+		{"exact", Rate{"USD", "EUR", "0.5"}, Money{1000, "USD"}, Money{500, "EUR"}, false},
+		{"rounds down", Rate{"USD", "EUR", "0.92"}, Money{1001, "USD"}, Money{921, "EUR"}, false},
+		{"rounds half away from zero", Rate{"USD", "EUR", "0.5"}, Money{1001, "USD"}, Money{501, "EUR"}, false},
+		{"negative half away from zero", Rate{"USD", "EUR", "0.5"}, Money{-1001, "USD"}, Money{-501, "EUR"}, false},
+		{"to zero-decimal currency", Rate{"USD", "JPY", "150.5"}, Money{199, "USD"}, Money{299, "JPY"}, false},
+		{"from zero-decimal currency", Rate{"JPY", "USD", "0.0066"}, Money{1500, "JPY"}, Money{990, "USD"}, false},
+		{"to three-decimal currency", Rate{"USD", "KWD", "0.307"}, Money{1000, "USD"}, Money{3070, "KWD"}, false},
+		{"currency mismatch", Rate{"EUR", "USD", "1.1"}, Money{1000, "USD"}, Money{}, true},
+		{"zero rate", Rate{"USD", "EUR", "0"}, Money{1000, "USD"}, Money{}, true},
+		{"malformed rate", Rate{"USD", "EUR", "abc"}, Money{1000, "USD"}, Money{}, true},
+		{"overflow", Rate{"USD", "NGN", "1530.25"}, Money{1 << 62, "USD"}, Money{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.rate.Convert(tt.m)
+			if (err != nil) != tt.err {
+				t.Fatalf("err = %v, want error %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStaticRateProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	if err := os.WriteFile(path, []byte(`{"base": "usd", "rates": {"EUR": "0.8", "NGN": "1600"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewStaticRateProvider(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		from, to string
+		want     string
+		err      error
+	}{
+		{"USD", "USD", "1", nil},
+		{"USD", "EUR", "0.8", nil},
+		{"EUR", "USD", "1.25", nil},
+		{"EUR", "NGN", "2000", nil},
+		{"NGN", "EUR", "0.0005", nil},
+		{"USD", "GBP", "", ErrRateNotFound},
+		{"GBP", "USD", "", ErrRateNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+"->"+tt.to, func(t *testing.T) {
+			rate, err := p.Rate(context.Background(), tt.from, tt.to)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if rate.Value != tt.want {
+				t.Errorf("rate = %q, want %q", rate.Value, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+// Command devcerts generates a local CA and a certificate per service for
+// running the services over mutual TLS in development and tests:
+//
+//	go run ./mtls/cmd/devcerts -out certs
+//
+// Then point each service at certs/ca.pem, certs/<service>.pem and
+// certs/<service>-key.pem via TLS_CA_FILE, TLS_CERT_FILE and TLS_KEY_FILE.
+package main
+
+import (
+	"flag"
+	"github.com/fabian-emmanuel/go-ms/mtls"
+	"log"
+	"strings"
+)
+
+func main() {
+	out := flag.String("out", "certs", "directory to write the CA and certificates to")
+	names := flag.String("names", "account,catalog,order,graphql", "comma-separated service names to issue certificates for")
+	flag.Parse()
+
+	if err := mtls.GenerateDevCerts(*out, strings.Split(*names, ",")...); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Wrote dev CA and certificates to %s", *out)
+}
@@ -0,0 +1,104 @@
+package mtls
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// devCertTTL keeps generated certificates valid for a typical dev cycle.
+const devCertTTL = 90 * 24 * time.Hour
+
+// GenerateDevCerts writes a throwaway CA (ca.pem, ca-key.pem) to dir, plus a
+// <name>.pem and <name>-key.pem signed by it for every name. Each certificate
+// has the name as its common name, which is the identity RequirePeers checks,
+// and is valid for the name, localhost and 127.0.0.1, for both serving and
+// calling. Not for production use.
+func GenerateDevCerts(dir string, names ...string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	caTemplate := &x509.Certificate{
+		SerialNumber:          serialNumber(),
+		Subject:               pkix.Name{CommonName: "go-ms dev CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(devCertTTL),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDer, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, caKey.Public(), caKey)
+	if err != nil {
+		return err
+	}
+	ca, err := x509.ParseCertificate(caDer)
+	if err != nil {
+		return err
+	}
+	if err := writePEM(dir, "ca", caDer, caKey); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return err
+		}
+		template := &x509.Certificate{
+			SerialNumber: serialNumber(),
+			Subject:      pkix.Name{CommonName: name},
+			DNSNames:     []string{name, "localhost"},
+			IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+			NotBefore:    now.Add(-time.Hour),
+			NotAfter:     now.Add(devCertTTL),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, ca, key.Public(), caKey)
+		if err != nil {
+			return fmt.Errorf("failed to issue certificate for %s: %w", name, err)
+		}
+		if err := writePEM(dir, name, der, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writePEM(dir, name string, der []byte, key crypto.Signer) error {
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, name+".pem"), certPem, 0o644); err != nil {
+		return err
+	}
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer})
+	return os.WriteFile(filepath.Join(dir, name+"-key.pem"), keyPem, 0o600)
+}
+
+func serialNumber() *big.Int {
+	n, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
@@ -0,0 +1,59 @@
+package mtls
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"slices"
+	"strings"
+)
+
+// PeerIdentity returns the common name of the client certificate the caller
+// connected with, or "" when the connection is not mutual TLS.
+func PeerIdentity(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.PeerCertificates) == 0 {
+		return ""
+	}
+	return info.State.PeerCertificates[0].Subject.CommonName
+}
+
+// RequirePeers rejects calls from any service whose client certificate is not
+// issued to one of names. Health checks stay open to every verified client so
+// probes need no special identity. It only makes sense on a server that
+// verifies client certificates, see Config.MutualTLS.
+func RequirePeers(names ...string) grpc.UnaryServerInterceptor {
+	healthPrefix := "/" + healthpb.Health_ServiceDesc.ServiceName + "/"
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if strings.HasPrefix(info.FullMethod, healthPrefix) {
+			return handler(ctx, req)
+		}
+		if id := PeerIdentity(ctx); !slices.Contains(names, id) {
+			return nil, status.Errorf(codes.PermissionDenied, "peer %q may not call %s", id, info.FullMethod)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// RequireMethodPeers is RequirePeers for the methods in peers only, each
+// callable by the services listed for it. Other methods stay open.
+func RequireMethodPeers(peers map[string][]string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		names, ok := peers[info.FullMethod]
+		if !ok {
+			return handler(ctx, req)
+		}
+		if id := PeerIdentity(ctx); !slices.Contains(names, id) {
+			return nil, status.Errorf(codes.PermissionDenied, "peer %q may not call %s", id, info.FullMethod)
+		}
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,50 @@
+package mtls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func peerContext(commonName string) context.Context {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: commonName}}
+	return peer.NewContext(context.Background(), &peer.Peer{
+		AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}},
+	})
+}
+
+func TestRequirePeers(t *testing.T) {
+	interceptor := RequirePeers("order", "graphql")
+	handler := func(context.Context, any) (any, error) { return "ok", nil }
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		method string
+		want   codes.Code
+	}{
+		{name: "allowed peer", ctx: peerContext("order"), method: "/pb.AccountService/GetAccount", want: codes.OK},
+		{name: "other allowed peer", ctx: peerContext("graphql"), method: "/pb.AccountService/GetAccount", want: codes.OK},
+		{name: "peer outside the list", ctx: peerContext("catalog"), method: "/pb.AccountService/GetAccount", want: codes.PermissionDenied},
+		{name: "name differing in case", ctx: peerContext("Order"), method: "/pb.AccountService/GetAccount", want: codes.PermissionDenied},
+		{name: "no client certificate", ctx: context.Background(), method: "/pb.AccountService/GetAccount", want: codes.PermissionDenied},
+		{name: "health check", ctx: peerContext("prober"), method: healthpb.Health_Check_FullMethodName, want: codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := interceptor(tt.ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("code = %s, want %s (err %v)", got, tt.want, err)
+			}
+		})
+	}
+}
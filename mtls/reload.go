@@ -0,0 +1,138 @@
+package mtls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// reloadInterval is how often certificate files are checked for changes.
+const reloadInterval = 10 * time.Second
+
+// fileWatcher calls load whenever one of files changes on disk, until ctx is
+// done. A failed reload is logged and the previously loaded value stays in
+// use, so a half-written rotation does not take the service down.
+type fileWatcher struct {
+	files   []string
+	load    func() error
+	modTime map[string]time.Time
+}
+
+func watchFiles(ctx context.Context, load func() error, files ...string) error {
+	w := &fileWatcher{files: files, load: load, modTime: map[string]time.Time{}}
+	w.changed()
+	if err := load(); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(reloadInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if !w.changed() {
+				continue
+			}
+			if err := w.load(); err != nil {
+				slog.Error("Error reloading TLS files", "files", w.files, "err", err)
+				continue
+			}
+			slog.Info("Reloaded TLS files", "files", w.files)
+		}
+	}()
+	return nil
+}
+
+func (w *fileWatcher) changed() bool {
+	changed := false
+	for _, f := range w.files {
+		info, err := os.Stat(f)
+		if err != nil {
+			continue
+		}
+		if !info.ModTime().Equal(w.modTime[f]) {
+			w.modTime[f] = info.ModTime()
+			changed = true
+		}
+	}
+	return changed
+}
+
+type keyPairReloader struct {
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+func newKeyPairReloader(ctx context.Context, certFile, keyFile string) (*keyPairReloader, error) {
+	r := &keyPairReloader{}
+	err := watchFiles(ctx, func() error {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load key pair %s: %w", certFile, err)
+		}
+		r.mu.Lock()
+		r.cert = &cert
+		r.mu.Unlock()
+		return nil
+	}, certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *keyPairReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+func (r *keyPairReloader) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+type poolReloader struct {
+	mu    sync.RWMutex
+	roots *x509.CertPool
+}
+
+func newPoolReloader(ctx context.Context, caFile string) (*poolReloader, error) {
+	r := &poolReloader{}
+	err := watchFiles(ctx, func() error {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return fmt.Errorf("failed to read CA %s: %w", caFile, err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in CA %s", caFile)
+		}
+		r.mu.Lock()
+		r.roots = roots
+		r.mu.Unlock()
+		return nil
+	}, caFile)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *poolReloader) pool() *x509.CertPool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.roots
+}
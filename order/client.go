@@ -2,34 +2,71 @@ package order
 
 import (
 	"context"
+	"github.com/fabian-emmanuel/go-ms/auth"
+	"github.com/fabian-emmanuel/go-ms/errs"
+	"github.com/fabian-emmanuel/go-ms/healthcheck"
+	"github.com/fabian-emmanuel/go-ms/logging"
+	"github.com/fabian-emmanuel/go-ms/metrics"
+	"github.com/fabian-emmanuel/go-ms/money"
+	"github.com/fabian-emmanuel/go-ms/mtls"
 	"github.com/fabian-emmanuel/go-ms/order/pb"
+	"github.com/fabian-emmanuel/go-ms/tracing"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
-	"time"
 )
 
 type Client struct {
 	conn    *grpc.ClientConn
 	service pb.OrderServiceClient
+	// stopReload stops watching the TLS files once the client is closed
+	stopReload context.CancelFunc
 }
 
-func NewClient(url string) (*Client, error) {
-	conn, err := grpc.NewClient(url, grpc.WithTransportCredentials(insecure.NewCredentials()))
+func NewClient(url string, creds mtls.Config) (*Client, error) {
+	reloadCtx, stopReload := context.WithCancel(context.Background())
+	transport, err := creds.DialOption(reloadCtx)
 	if err != nil {
+		stopReload()
+		return nil, err
+	}
+	conn, err := grpc.NewClient(url,
+		transport,
+		tracing.DialOption(),
+		grpc.WithChainUnaryInterceptor(
+			// Outermost, so the other interceptors still see gRPC status errors
+			errs.UnaryClientInterceptor(),
+			metrics.UnaryClientInterceptor(),
+			logging.UnaryClientInterceptor(),
+			auth.UnaryClientInterceptor(),
+		),
+		grpc.WithChainStreamInterceptor(
+			errs.StreamClientInterceptor(),
+			metrics.StreamClientInterceptor(),
+			logging.StreamClientInterceptor(),
+			auth.StreamClientInterceptor(),
+		),
+	)
+	if err != nil {
+		stopReload()
 		return nil, err
 	}
 	client := pb.NewOrderServiceClient(conn)
-	return &Client{conn, client}, nil
+	return &Client{conn, client, stopReload}, nil
+}
+
+// Health fails unless the order service reports itself SERVING.
+func (c *Client) Health(ctx context.Context) error {
+	return healthcheck.Check(ctx, c.conn, pb.OrderService_ServiceDesc.ServiceName)
 }
 
 func (c *Client) Close() {
+	c.stopReload()
 	err := c.conn.Close()
 	if err != nil {
 		return
 	}
 }
 
-func (c *Client) CreateOrder(ctx context.Context, accountId string, products []OrderedProduct) (*Order, error) {
+func (c *Client) CreateOrder(ctx context.Context, accountId, currency string, products []OrderedProduct) (*Order, error) {
 	var productsProto []*pb.OrderProduct
 
 	for _, product := range products {
@@ -42,27 +79,14 @@ func (c *Client) CreateOrder(ctx context.Context, accountId string, products []O
 	resp, err := c.service.CreateOrder(ctx, &pb.CreateOrderRequest{
 		AccountId:     accountId,
 		OrderProducts: productsProto,
+		Currency:      currency,
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
-	newOrder := resp.Order
-	newOrderCreatedAt := time.Time{}
-	err = newOrderCreatedAt.UnmarshalBinary(newOrder.CreatedAt)
-	if err != nil {
-		return nil, err
-	}
-
-	return &Order{
-		ID:          newOrder.Id,
-		CreatedAt:   newOrderCreatedAt,
-		AccountId:   newOrder.AccountId,
-		TotalAmount: newOrder.TotalAmount,
-		Products:    products,
-	}, nil
-
+	return orderFromProto(resp.Order)
 }
 
 func (c *Client) GetOrdersForAccount(ctx context.Context, accountId string) ([]*Order, error) {
@@ -77,36 +101,178 @@ func (c *Client) GetOrdersForAccount(ctx context.Context, accountId string) ([]*
 	var orders []*Order
 
 	for _, op := range resp.Orders {
-		newOrder := &Order{
-			ID:          op.Id,
-			AccountId:   op.AccountId,
-			TotalAmount: op.TotalAmount,
+		newOrder, err := orderFromProto(op)
+		if err != nil {
+			return nil, err
 		}
+		orders = append(orders, newOrder)
+	}
+
+	return orders, nil
+
+}
+
+// GetOrdersForAccounts fetches the orders of several accounts at once. The
+// returned products only carry their id, quantity and ordered price.
+func (c *Client) GetOrdersForAccounts(ctx context.Context, accountIds []string) ([]*Order, error) {
+	resp, err := c.service.GetOrdersForAccounts(ctx, &pb.GetOrdersForAccountsRequest{
+		AccountIds: accountIds,
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		newOrderCreatedAt := time.Time{}
-		err = newOrderCreatedAt.UnmarshalBinary(op.CreatedAt)
+	orders := make([]*Order, 0, len(resp.Orders))
+	for _, op := range resp.Orders {
+		newOrder, err := orderFromProto(op)
 		if err != nil {
 			return nil, err
 		}
-		newOrder.CreatedAt = newOrderCreatedAt
+		orders = append(orders, newOrder)
+	}
+	return orders, nil
+}
+
+func (c *Client) GetOrder(ctx context.Context, id string) (*Order, error) {
+	resp, err := c.service.GetOrder(ctx, &pb.GetOrderRequest{Id: id})
+	if err != nil {
+		return nil, err
+	}
+
+	return orderFromProto(resp.Order)
+}
 
-		var products []OrderedProduct
+func (c *Client) ListOrders(ctx context.Context, query ListOrdersQuery) (*OrderPage, error) {
+	filter := &pb.OrderFilter{AccountId: query.Filter.AccountId}
+	for _, status := range query.Filter.Statuses {
+		filter.Statuses = append(filter.Statuses, string(status))
+	}
+	if !query.Filter.CreatedAfter.IsZero() {
+		filter.CreatedAfter, _ = query.Filter.CreatedAfter.MarshalBinary()
+	}
+	if !query.Filter.CreatedBefore.IsZero() {
+		filter.CreatedBefore, _ = query.Filter.CreatedBefore.MarshalBinary()
+	}
+	if query.Filter.MinTotal != nil {
+		filter.MinTotal = query.Filter.MinTotal.Proto()
+	}
 
-		for _, p := range op.OrderedProducts {
-			products = append(products, OrderedProduct{
-				ID:          accountId,
-				Quantity:    p.Quantity,
-				Name:        p.Name,
-				Description: p.Description,
-				Price:       p.Price,
-			})
+	resp, err := c.service.ListOrders(ctx, &pb.ListOrdersRequest{
+		Filter: filter,
+		Sort:   string(query.Sort),
+		First:  uint32(query.First),
+		After:  query.After,
+	})
+	if err != nil {
+		return nil, err
+	}
 
+	page := &OrderPage{
+		Orders:      []*Order{},
+		Cursors:     resp.Cursors,
+		EndCursor:   resp.EndCursor,
+		HasNextPage: resp.HasNextPage,
+		TotalCount:  int(resp.TotalCount),
+	}
+	for _, op := range resp.Orders {
+		o, err := orderFromProto(op)
+		if err != nil {
+			return nil, err
 		}
+		page.Orders = append(page.Orders, o)
+	}
+	return page, nil
+}
 
-		newOrder.Products = products
-		orders = append(orders, newOrder)
+func (c *Client) UpdateOrderStatus(ctx context.Context, orderId string, status OrderStatus, reason string) (*Order, error) {
+	resp, err := c.service.UpdateOrderStatus(ctx, &pb.UpdateOrderStatusRequest{
+		OrderId: orderId,
+		Status:  string(status),
+		Reason:  reason,
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return orders, nil
+	return orderFromProto(resp.Order)
+}
+
+func (c *Client) CancelOrder(ctx context.Context, orderId string, reason string) (*Order, error) {
+	resp, err := c.service.CancelOrder(ctx, &pb.CancelOrderRequest{
+		OrderId: orderId,
+		Reason:  reason,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return orderFromProto(resp.Order)
+}
+
+func orderFromProto(op *pb.Order) (*Order, error) {
+	newOrder := &Order{
+		ID:          op.Id,
+		AccountId:   op.AccountId,
+		TotalAmount: money.FromProto(op.TotalAmount),
+		Status:      OrderStatus(op.Status),
+	}
+
+	err := newOrder.CreatedAt.UnmarshalBinary(op.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
 
+	for _, p := range op.OrderedProducts {
+		newOrder.Products = append(newOrder.Products, OrderedProduct{
+			ID:          p.Id,
+			Quantity:    p.Quantity,
+			Name:        p.Name,
+			Description: p.Description,
+			Price:       money.FromProto(p.Price),
+		})
+	}
+
+	for _, rate := range op.ExchangeRates {
+		newOrder.ExchangeRates = append(newOrder.ExchangeRates, money.RateFromProto(rate))
+	}
+
+	for _, c := range op.StatusHistory {
+		change := StatusChange{
+			From:   OrderStatus(c.From),
+			To:     OrderStatus(c.To),
+			Reason: c.Reason,
+		}
+		if err := change.ChangedAt.UnmarshalBinary(c.ChangedAt); err != nil {
+			return nil, err
+		}
+		newOrder.StatusHistory = append(newOrder.StatusHistory, change)
+	}
+
+	return newOrder, nil
+}
+
+// OrderStream receives the orders of a WatchOrders call. It ends with the
+// context the call was made with.
+type OrderStream struct {
+	stream pb.OrderService_WatchOrdersClient
+}
+
+// Recv waits for the next order, returning io.EOF once the service ends the
+// stream cleanly.
+func (s *OrderStream) Recv() (*Order, error) {
+	resp, err := s.stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	return orderFromProto(resp.Order)
+}
+
+// WatchOrders follows the order orderId, or every order of accountId when
+// orderId is empty, as they are created or change status.
+func (c *Client) WatchOrders(ctx context.Context, orderId, accountId string) (*OrderStream, error) {
+	stream, err := c.service.WatchOrders(ctx, &pb.WatchOrdersRequest{OrderId: orderId, AccountId: accountId})
+	if err != nil {
+		return nil, err
+	}
+	return &OrderStream{stream}, nil
 }
@@ -1,38 +1,135 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"github.com/fabian-emmanuel/go-ms/auth"
+	"github.com/fabian-emmanuel/go-ms/events"
+	"github.com/fabian-emmanuel/go-ms/idempotency"
+	"github.com/fabian-emmanuel/go-ms/lifecycle"
+	"github.com/fabian-emmanuel/go-ms/logging"
+	"github.com/fabian-emmanuel/go-ms/metrics"
+	"github.com/fabian-emmanuel/go-ms/money"
+	"github.com/fabian-emmanuel/go-ms/mtls"
 	"github.com/fabian-emmanuel/go-ms/order"
+	"github.com/fabian-emmanuel/go-ms/tracing"
 	"github.com/kelseyhightower/envconfig"
 	"github.com/tinrab/retry"
-	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
 	"time"
 )
 
 type Config struct {
-	DatabaseUrl       string `envconfig:"DATABASE_URL"`
-	AccountServiceUrl string `envconfig:"ACCOUNT_SERVICE_URL"`
-	CatalogServiceUrl string `envconfig:"CATALOG_SERVICE_URL"`
-	OrderServicePort  int    `envconfig:"ORDER_SERVICE_PORT"`
+	DatabaseUrl       string        `envconfig:"DATABASE_URL"`
+	AccountServiceUrl string        `envconfig:"ACCOUNT_SERVICE_URL"`
+	CatalogServiceUrl string        `envconfig:"CATALOG_SERVICE_URL"`
+	OrderServicePort  int           `envconfig:"ORDER_SERVICE_PORT"`
+	JwksUrl           string        `envconfig:"JWKS_URL"`
+	ExchangeRatesFile string        `envconfig:"EXCHANGE_RATES_FILE"`
+	MetricsPort       int           `envconfig:"METRICS_PORT"`
+	ShutdownTimeout   time.Duration `envconfig:"SHUTDOWN_TIMEOUT"`
+
+	// The order service reserves stock as this account, which must hold the service role
+	ServiceAccountEmail        string `envconfig:"SERVICE_ACCOUNT_EMAIL"`
+	ServiceAccountPasswordFile string `envconfig:"SERVICE_ACCOUNT_PASSWORD_FILE"`
+
+	events.Config
+	TLS     mtls.Config
+	Tracing tracing.Config
+	Log     logging.Config
 }
 
 func main() {
+	if err := run(); err != nil {
+		slog.Error("Exiting", "err", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
 	var config Config
 	err := envconfig.Process("", &config)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+
+	logging.Setup("order", config.Log)
+
+	// Deferred first so spans from the rest of shutdown are still flushed
+	shutdownTracing, err := tracing.Setup(context.Background(), "order", config.Tracing)
+	if err != nil {
+		return err
+	}
+	defer shutdownTracing()
+
+	ctx, stop := lifecycle.SignalContext()
+	defer stop()
+
+	var servicePassword string
+	if config.ServiceAccountPasswordFile != "" {
+		data, err := os.ReadFile(config.ServiceAccountPasswordFile)
+		if err != nil {
+			return fmt.Errorf("failed to read service account password: %w", err)
+		}
+		servicePassword = strings.TrimSpace(string(data))
+	}
+
+	rates, err := money.NewStaticRateProvider(config.ExchangeRatesFile)
+	if err != nil {
+		return err
 	}
 
 	var repo order.Repository
 	retry.ForeverSleep(2*time.Second, func(_ int) (err error) {
 		repo, err = order.NewPostgresRepository(config.DatabaseUrl)
 		if err != nil {
-			log.Println(err)
+			slog.Error("Error connecting to database", "err", err)
 		}
 		return
 	})
-
 	defer repo.Close()
-	log.Printf("Listening on port :%v...\n", config.OrderServicePort)
-	s := order.NewOrderService(repo)
-	log.Fatal(order.ListenGRPC(s, config.AccountServiceUrl, config.CatalogServiceUrl, config.OrderServicePort))
+
+	watcher, err := order.NewWatcher(config.DatabaseUrl)
+	if err != nil {
+		return err
+	}
+	go watcher.Run(ctx)
+
+	publisher, err := events.NewPublisher(config.Config)
+	if err != nil {
+		return err
+	}
+	defer publisher.Close()
+
+	// Workers outlive ctx so events written by draining requests still get relayed
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	defer workers.Wait()
+	defer stopWorkers()
+	workers.Add(2)
+	go func() {
+		defer workers.Done()
+		events.NewRelay(repo, publisher).Run(workerCtx)
+	}()
+	go func() {
+		defer workers.Done()
+		idempotency.RunJanitor(workerCtx, repo, time.Hour)
+	}()
+
+	if config.MetricsPort != 0 {
+		go func() {
+			slog.Info("Serving metrics", "port", config.MetricsPort)
+			if err := metrics.Serve(ctx, config.MetricsPort, config.ShutdownTimeout); err != nil {
+				slog.ErrorContext(ctx, "Error serving metrics", "err", err)
+			}
+		}()
+	}
+
+	slog.Info("Listening", "port", config.OrderServicePort)
+	s := order.NewOrderService(repo, rates)
+	payments := order.NewManualPaymentGateway()
+	return order.ListenGRPC(ctx, s, repo, payments, watcher, auth.NewVerifier(config.JwksUrl), config.AccountServiceUrl, config.CatalogServiceUrl, config.ServiceAccountEmail, servicePassword, config.TLS, config.OrderServicePort, config.ShutdownTimeout)
 }
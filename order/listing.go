@@ -0,0 +1,116 @@
+package order
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/fabian-emmanuel/go-ms/errs"
+	"github.com/fabian-emmanuel/go-ms/money"
+	"time"
+)
+
+type OrderSort string
+
+const (
+	SortCreatedAtDesc OrderSort = "created_at_desc"
+	SortCreatedAtAsc  OrderSort = "created_at_asc"
+	SortTotalDesc     OrderSort = "total_desc"
+	SortTotalAsc      OrderSort = "total_asc"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+var (
+	ErrInvalidCursor = errs.InvalidArgument("invalid cursor")
+	ErrInvalidSort   = errs.InvalidArgument("invalid sort")
+)
+
+// OrderFilter narrows ListOrders. Zero values leave a criterion out. MinTotal
+// only matches orders totalled in its currency.
+type OrderFilter struct {
+	AccountId     string
+	Statuses      []OrderStatus
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	MinTotal      *money.Money
+}
+
+type ListOrdersQuery struct {
+	Filter OrderFilter
+	Sort   OrderSort
+	First  int
+	After  string
+}
+
+// OrderPage is one page of orders. Cursors holds the cursor of each order, in
+// the same order, and TotalCount counts every order matching the filter.
+type OrderPage struct {
+	Orders      []*Order
+	Cursors     []string
+	EndCursor   string
+	HasNextPage bool
+	TotalCount  int
+}
+
+// orderCursor marks the last order of a page by its sort key and ID, so the
+// next page starts strictly after it even when sort keys tie.
+type orderCursor struct {
+	Sort      OrderSort `json:"s"`
+	CreatedAt time.Time `json:"c,omitempty"`
+	Total     int64     `json:"t,omitempty"`
+	ID        string    `json:"i"`
+}
+
+func ParseOrderSort(s string) (OrderSort, error) {
+	switch sort := OrderSort(s); sort {
+	case "":
+		return SortCreatedAtDesc, nil
+	case SortCreatedAtDesc, SortCreatedAtAsc, SortTotalDesc, SortTotalAsc:
+		return sort, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrInvalidSort, s)
+	}
+}
+
+// column and direction of the ORDER BY clause for s.
+func (s OrderSort) orderBy() (string, string) {
+	switch s {
+	case SortCreatedAtAsc:
+		return "created_at", "ASC"
+	case SortTotalDesc:
+		return "total_amount", "DESC"
+	case SortTotalAsc:
+		return "total_amount", "ASC"
+	default:
+		return "created_at", "DESC"
+	}
+}
+
+func newOrderCursor(sort OrderSort, o *Order) string {
+	c := orderCursor{Sort: sort, ID: o.ID}
+	if column, _ := sort.orderBy(); column == "total_amount" {
+		c.Total = o.TotalAmount.Amount
+	} else {
+		c.CreatedAt = o.CreatedAt
+	}
+	body, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(body)
+}
+
+func parseOrderCursor(s string, sort OrderSort) (*orderCursor, error) {
+	body, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c orderCursor
+	if err := json.Unmarshal(body, &c); err != nil || c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort != sort {
+		return nil, fmt.Errorf("%w: cursor was issued for sort %q", ErrInvalidCursor, c.Sort)
+	}
+	return &c, nil
+}
@@ -0,0 +1,30 @@
+package order
+
+import (
+	"github.com/fabian-emmanuel/go-ms/money"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"math"
+)
+
+var (
+	ordersCreated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "orders_created_total",
+		Help: "Orders placed, by currency.",
+	}, []string{"currency"})
+
+	orderValue = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "order_value_total",
+		Help: "Total value of orders placed, in major units of each currency.",
+	}, []string{"currency"})
+)
+
+func recordOrderCreated(order *Order) {
+	total := order.TotalAmount
+	ordersCreated.WithLabelValues(total.Currency).Inc()
+	orderValue.WithLabelValues(total.Currency).Add(majorUnits(total))
+}
+
+func majorUnits(m money.Money) float64 {
+	return float64(m.Amount) / math.Pow10(money.MinorUnits(m.Currency))
+}
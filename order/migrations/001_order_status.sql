@@ -0,0 +1,24 @@
+-- Adds the order status lifecycle and its history. Fresh databases already get
+-- this layout from up.sql; run this once against databases created before it.
+BEGIN;
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'pending';
+
+CREATE TABLE IF NOT EXISTS order_status_history (
+    id BIGSERIAL PRIMARY KEY,
+    order_id CHAR(30) NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    from_status VARCHAR(16),
+    to_status VARCHAR(16) NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    changed_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS order_status_history_order_id_idx ON order_status_history (order_id, changed_at);
+
+-- Give existing orders the initial entry new orders are created with
+INSERT INTO order_status_history (order_id, to_status, changed_at)
+SELECT o.id, o.status, o.created_at
+FROM orders o
+WHERE NOT EXISTS (SELECT 1 FROM order_status_history h WHERE h.order_id = o.id);
+
+COMMIT;
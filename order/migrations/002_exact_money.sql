@@ -0,0 +1,20 @@
+-- Moves orders.total_amount from the locale-dependent MONEY type to integer
+-- minor units plus an ISO 4217 currency code. Fresh databases already get
+-- this layout from up.sql; run this once against databases created before it.
+BEGIN;
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS currency CHAR(3);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS total_amount_minor BIGINT;
+
+-- MONEY has two fractional digits under every locale we have deployed with.
+UPDATE orders
+SET total_amount_minor = ROUND(total_amount::numeric * 100)::BIGINT,
+    currency = COALESCE(currency, 'USD')
+WHERE total_amount_minor IS NULL;
+
+ALTER TABLE orders DROP COLUMN total_amount;
+ALTER TABLE orders RENAME COLUMN total_amount_minor TO total_amount;
+ALTER TABLE orders ALTER COLUMN total_amount SET NOT NULL;
+ALTER TABLE orders ALTER COLUMN currency SET NOT NULL;
+
+COMMIT;
@@ -0,0 +1,16 @@
+-- Snapshots unit prices and applied exchange rates onto orders. Rows created
+-- before this migration keep NULL prices and are priced from the catalog.
+BEGIN;
+
+ALTER TABLE ordered_products ADD COLUMN IF NOT EXISTS price BIGINT;
+ALTER TABLE ordered_products ADD COLUMN IF NOT EXISTS currency CHAR(3);
+
+CREATE TABLE IF NOT EXISTS order_exchange_rates (
+    order_id CHAR(30) REFERENCES orders(id) ON DELETE CASCADE,
+    from_currency CHAR(3) NOT NULL,
+    to_currency CHAR(3) NOT NULL,
+    rate NUMERIC NOT NULL,
+    PRIMARY KEY (order_id, from_currency)
+);
+
+COMMIT;
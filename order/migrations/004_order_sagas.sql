@@ -0,0 +1,16 @@
+-- Persists order creation sagas so in-flight orders survive a restart.
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS order_sagas (
+    id CHAR(30) PRIMARY KEY,
+    status VARCHAR(16) NOT NULL,
+    step VARCHAR(32) NOT NULL DEFAULT '',
+    payload JSONB NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS order_sagas_unfinished_idx ON order_sagas (updated_at) WHERE status IN ('running', 'compensating');
+
+COMMIT;
@@ -0,0 +1,17 @@
+-- Records domain events in the same transaction as the change that produced them.
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS outbox (
+    id CHAR(27) PRIMARY KEY,
+    event_type VARCHAR(64) NOT NULL,
+    aggregate_id VARCHAR(64) NOT NULL,
+    payload JSONB NOT NULL,
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    published_at TIMESTAMP WITH TIME ZONE,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (occurred_at, id) WHERE published_at IS NULL;
+
+COMMIT;
@@ -0,0 +1,8 @@
+-- Supports keyset pagination and filtering in ListOrders.
+BEGIN;
+
+CREATE INDEX IF NOT EXISTS orders_created_at_idx ON orders (created_at, id);
+CREATE INDEX IF NOT EXISTS orders_total_amount_idx ON orders (currency, total_amount, id);
+CREATE INDEX IF NOT EXISTS orders_account_id_created_at_idx ON orders (account_id, created_at, id);
+
+COMMIT;
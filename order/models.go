@@ -1,19 +1,40 @@
 package order
 
-import "time"
+import (
+	"github.com/fabian-emmanuel/go-ms/money"
+	"time"
+)
 
 type Order struct {
-	ID          string           `json:"id"`
-	CreatedAt   time.Time        `json:"created_at"`
-	AccountId   string           `json:"account_id"`
-	TotalAmount float64          `json:"total_amount"`
-	Products    []OrderedProduct `json:"products"`
+	ID            string           `json:"id"`
+	CreatedAt     time.Time        `json:"created_at"`
+	AccountId     string           `json:"account_id"`
+	TotalAmount   money.Money      `json:"total_amount"`
+	Status        OrderStatus      `json:"status"`
+	StatusHistory []StatusChange   `json:"status_history"`
+	ExchangeRates []money.Rate     `json:"exchange_rates"`
+	Products      []OrderedProduct `json:"products"`
 }
 
 type OrderedProduct struct {
-	ID          string  `json:"id"`
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
-	Quantity    uint32  `json:"quantity"`
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Price       money.Money `json:"price"`
+	Quantity    uint32      `json:"quantity"`
+}
+
+type StatusChange struct {
+	From      OrderStatus `json:"from"`
+	To        OrderStatus `json:"to"`
+	Reason    string      `json:"reason"`
+	ChangedAt time.Time   `json:"changed_at"`
+}
+
+func (o *Order) productIds() []string {
+	var ids []string
+	for _, p := range o.Products {
+		ids = append(ids, p.ID)
+	}
+	return ids
 }
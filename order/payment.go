@@ -0,0 +1,30 @@
+package order
+
+import (
+	"context"
+	"github.com/fabian-emmanuel/go-ms/money"
+)
+
+// PaymentGateway authorizes funds for an order before it is persisted and
+// voids the authorization if the order cannot be completed. Implementations
+// must treat repeated calls for the same order as the same authorization.
+type PaymentGateway interface {
+	Authorize(ctx context.Context, orderId string, amount money.Money) (string, error)
+	Void(ctx context.Context, authorizationId string) error
+}
+
+type manualPaymentGateway struct{}
+
+// NewManualPaymentGateway returns a gateway for payments collected out of band
+// (bank transfer, cash on delivery). Every authorization succeeds.
+func NewManualPaymentGateway() PaymentGateway {
+	return &manualPaymentGateway{}
+}
+
+func (g *manualPaymentGateway) Authorize(_ context.Context, orderId string, _ money.Money) (string, error) {
+	return "manual_" + orderId, nil
+}
+
+func (g *manualPaymentGateway) Void(_ context.Context, _ string) error {
+	return nil
+}
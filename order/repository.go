@@ -3,24 +3,45 @@ package order
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"github.com/fabian-emmanuel/go-ms/errs"
+	"github.com/fabian-emmanuel/go-ms/events"
+	"github.com/fabian-emmanuel/go-ms/idempotency"
+	"github.com/fabian-emmanuel/go-ms/metrics"
+	"github.com/fabian-emmanuel/go-ms/money"
+	"github.com/fabian-emmanuel/go-ms/tracing"
 	"github.com/lib/pq"
-	"log"
+	"log/slog"
+	"strings"
 	"time"
 )
 
 type Repository interface {
 	Close()
+	Ping(ctx context.Context) error
 	CreateOrder(ctx context.Context, order Order) error
+	GetOrderById(ctx context.Context, id string) (*Order, error)
 	GetOrdersForAccount(ctx context.Context, accountId string) ([]*Order, error)
+	GetOrdersForAccounts(ctx context.Context, accountIds []string) ([]*Order, error)
+	ListOrders(ctx context.Context, query ListOrdersQuery) (*OrderPage, error)
+	UpdateOrderStatus(ctx context.Context, id string, change StatusChange) error
+	CreateSaga(ctx context.Context, saga *Saga) error
+	UpdateSaga(ctx context.Context, saga *Saga) error
+	GetUnfinishedSagas(ctx context.Context, updatedBefore time.Time) ([]*Saga, error)
+	events.Outbox
+	idempotency.Store
 }
 
+var ErrOrderNotFound = errs.NotFound("order not found")
+
 type postgresRepository struct {
 	db *sql.DB
+	idempotency.Store
 }
 
 func NewPostgresRepository(url string) (Repository, error) {
-	db, err := sql.Open("postgres", url)
+	db, err := tracing.OpenPostgres(url)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database::{%s}::%w", url, err)
 	}
@@ -29,23 +50,26 @@ func NewPostgresRepository(url string) (Repository, error) {
 	if err := db.Ping(); err != nil {
 		return nil, fmt.Errorf("failed to connect to database::{%s}::%w", url, err)
 	}
+	if err := metrics.RegisterDB(db, "order"); err != nil {
+		return nil, err
+	}
 
-	return &postgresRepository{db: db}, nil
+	return &postgresRepository{db: db, Store: idempotency.NewPostgresStore(db)}, nil
 }
 
 func (r *postgresRepository) Close() {
 	if r.db == nil {
-		log.Println("Warning: Attempted to close a nil database connection")
+		slog.Warn("Attempted to close a nil database connection")
 		return
 	}
 
 	if err := r.db.Close(); err != nil {
-		log.Printf("Error closing database connection: %v", err)
+		slog.Error("Error closing database connection", "err", err)
 	}
 }
 
-func (r *postgresRepository) Ping() error {
-	return r.db.Ping()
+func (r *postgresRepository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
 }
 
 func (r *postgresRepository) CreateOrder(ctx context.Context, order Order) error {
@@ -68,17 +92,40 @@ func (r *postgresRepository) CreateOrder(ctx context.Context, order Order) error
 	// Execute query
 	_, err = tx.ExecContext(
 		ctx,
-		"INSERT INTO orders(id, created_at, account_id, total_amount) VALUES($1, $2, $3, $4)",
-		order.ID, order.CreatedAt, order.AccountId, order.TotalAmount,
+		"INSERT INTO orders(id, created_at, account_id, total_amount, currency, status) VALUES($1, $2, $3, $4, $5, $6)",
+		order.ID, order.CreatedAt, order.AccountId, order.TotalAmount.Amount, order.TotalAmount.Currency, order.Status,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to execute insert query: %w", err)
 	}
 
-	stmt, _ := tx.PrepareContext(ctx, pq.CopyIn("order_products", "order_id", "product_id", "quantity"))
+	_, err = tx.ExecContext(
+		ctx,
+		"INSERT INTO order_status_history(order_id, to_status, changed_at) VALUES($1, $2, $3)",
+		order.ID, order.Status, order.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record initial order status: %w", err)
+	}
+
+	for _, rate := range order.ExchangeRates {
+		_, err = tx.ExecContext(
+			ctx,
+			"INSERT INTO order_exchange_rates(order_id, from_currency, to_currency, rate) VALUES($1, $2, $3, $4)",
+			order.ID, rate.From, rate.To, rate.Value,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to record exchange rate: %w", err)
+		}
+	}
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("ordered_products", "order_id", "product_id", "quantity", "price", "currency"))
+	if err != nil {
+		return fmt.Errorf("failed to prepare copy statement: %w", err)
+	}
 
 	for _, orderedProduct := range order.Products {
-		_, err = stmt.ExecContext(ctx, order.ID, orderedProduct.ID, orderedProduct.Quantity)
+		_, err = stmt.ExecContext(ctx, order.ID, orderedProduct.ID, orderedProduct.Quantity, orderedProduct.Price.Amount, orderedProduct.Price.Currency)
 		if err != nil {
 			return fmt.Errorf("failed to execute insert query: %w", err)
 		}
@@ -94,29 +141,501 @@ func (r *postgresRepository) CreateOrder(ctx context.Context, order Order) error
 		return err
 	}
 
+	event, err := events.New(events.OrderCreated, order.ID, order)
+	if err != nil {
+		return err
+	}
+	err = events.WriteOutbox(ctx, tx, event)
+	if err != nil {
+		return err
+	}
+
+	err = notifyOrderChanged(ctx, tx, order.ID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
 	return err
 }
 
+func (r *postgresRepository) DispatchPendingEvents(ctx context.Context, limit int, publish func(ctx context.Context, e events.Event) error) (int, error) {
+	return events.DispatchPostgresOutbox(ctx, r.db, limit, publish)
+}
+
+func (r *postgresRepository) GetOrderById(ctx context.Context, id string) (*Order, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT o.id, o.created_at, o.account_id, o.total_amount, o.currency, o.status,
+		        op.product_id, op.quantity, op.price, op.currency
+		 FROM orders o
+		 JOIN ordered_products op ON o.id = op.order_id
+		 WHERE o.id = $1`,
+		id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query order: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Warn("failed to close rows", "err", err)
+		}
+	}()
+
+	orders, err := scanOrders(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(orders) == 0 {
+		return nil, ErrOrderNotFound.WithResource("order", id)
+	}
+
+	if err := r.loadStatusHistory(ctx, orders); err != nil {
+		return nil, err
+	}
+
+	if err := r.loadExchangeRates(ctx, orders); err != nil {
+		return nil, err
+	}
+
+	return orders[0], nil
+}
+
 func (r *postgresRepository) GetOrdersForAccount(ctx context.Context, accountId string) ([]*Order, error) {
+	return r.GetOrdersForAccounts(ctx, []string{accountId})
+}
+
+func (r *postgresRepository) GetOrdersForAccounts(ctx context.Context, accountIds []string) ([]*Order, error) {
 	rows, err := r.db.QueryContext(
 		ctx,
-		`SELECT o.id, o.created_at, o.account_id, o.total_amount::money::numeric::float8, 
-		        op.product_id, op.quantity 
+		`SELECT o.id, o.created_at, o.account_id, o.total_amount, o.currency, o.status,
+		        op.product_id, op.quantity, op.price, op.currency 
 		 FROM orders o 
 		 JOIN ordered_products op ON o.id = op.order_id 
-		 WHERE o.account_id = $1 
+		 WHERE o.account_id = ANY($1) 
 		 ORDER BY o.id`,
-		accountId,
+		pq.Array(accountIds),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query orders: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Warn("failed to close rows", "err", err)
+		}
+	}()
+
+	orders, err := scanOrders(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.loadStatusHistory(ctx, orders); err != nil {
+		return nil, err
+	}
+
+	if err := r.loadExchangeRates(ctx, orders); err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+// ListOrders pages through orders with keyset pagination on (sort column, id).
+// query must already be validated; First is the page size.
+func (r *postgresRepository) ListOrders(ctx context.Context, query ListOrdersQuery) (*OrderPage, error) {
+	var cursor *orderCursor
+	if query.After != "" {
+		var err error
+		if cursor, err = parseOrderCursor(query.After, query.Sort); err != nil {
+			return nil, err
+		}
+	}
+
+	var conditions []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	f := query.Filter
+	if f.AccountId != "" {
+		conditions = append(conditions, "account_id = "+arg(f.AccountId))
+	}
+	if len(f.Statuses) > 0 {
+		var statuses []string
+		for _, status := range f.Statuses {
+			statuses = append(statuses, string(status))
+		}
+		conditions = append(conditions, "status = ANY("+arg(pq.Array(statuses))+")")
+	}
+	if !f.CreatedAfter.IsZero() {
+		conditions = append(conditions, "created_at >= "+arg(f.CreatedAfter))
+	}
+	if !f.CreatedBefore.IsZero() {
+		conditions = append(conditions, "created_at < "+arg(f.CreatedBefore))
+	}
+	if f.MinTotal != nil {
+		conditions = append(conditions, "currency = "+arg(f.MinTotal.Currency))
+		conditions = append(conditions, "total_amount >= "+arg(f.MinTotal.Amount))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+	page := &OrderPage{Orders: []*Order{}, Cursors: []string{}}
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM orders "+where, args...).Scan(&page.TotalCount); err != nil {
+		return nil, fmt.Errorf("failed to count orders: %w", err)
+	}
+
+	column, direction := query.Sort.orderBy()
+	if cursor != nil {
+		var value interface{} = cursor.CreatedAt
+		if column == "total_amount" {
+			value = cursor.Total
+		}
+		op := "<"
+		if direction == "ASC" {
+			op = ">"
+		}
+		conditions = append(conditions, fmt.Sprintf("(%s, id) %s (%s, %s)", column, op, arg(value), arg(cursor.ID)))
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	// Fetch one extra row to learn whether another page follows
+	rows, err := r.db.QueryContext(
+		ctx,
+		fmt.Sprintf("SELECT id FROM orders %s ORDER BY %s %s, id %s LIMIT %s", where, column, direction, direction, arg(query.First+1)),
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query orders: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Warn("failed to close rows", "err", err)
+		}
+	}()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	if len(ids) > query.First {
+		page.HasNextPage = true
+		ids = ids[:query.First]
+	}
+	if len(ids) == 0 {
+		return page, nil
+	}
+
+	orders, err := r.getOrdersByIds(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	byId := map[string]*Order{}
+	for _, o := range orders {
+		byId[o.ID] = o
+	}
+	for _, id := range ids {
+		if o, ok := byId[id]; ok {
+			page.Orders = append(page.Orders, o)
+		}
+	}
+	for _, o := range page.Orders {
+		page.Cursors = append(page.Cursors, newOrderCursor(query.Sort, o))
+	}
+	if len(page.Cursors) > 0 {
+		page.EndCursor = page.Cursors[len(page.Cursors)-1]
+	}
+
+	return page, nil
+}
+
+func (r *postgresRepository) getOrdersByIds(ctx context.Context, ids []string) ([]*Order, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT o.id, o.created_at, o.account_id, o.total_amount, o.currency, o.status,
+		        op.product_id, op.quantity, op.price, op.currency
+		 FROM orders o
+		 JOIN ordered_products op ON o.id = op.order_id
+		 WHERE o.id = ANY($1)`,
+		pq.Array(ids),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query orders: %w", err)
 	}
 	defer func() {
 		if err := rows.Close(); err != nil {
-			log.Printf("failed to close rows: %v", err)
+			slog.Warn("failed to close rows", "err", err)
 		}
 	}()
 
+	orders, err := scanOrders(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.loadStatusHistory(ctx, orders); err != nil {
+		return nil, err
+	}
+
+	if err := r.loadExchangeRates(ctx, orders); err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+func (r *postgresRepository) UpdateOrderStatus(ctx context.Context, id string, change StatusChange) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	// Only move the order if nobody else changed its status in the meantime
+	res, err := tx.ExecContext(
+		ctx,
+		"UPDATE orders SET status = $1 WHERE id = $2 AND status = $3",
+		change.To, id, change.From,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update order status: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update order status: %w", err)
+	}
+	if affected == 0 {
+		err = fmt.Errorf("%w: order %s is no longer %s", ErrInvalidStatusTransition, id, change.From)
+		return err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		"INSERT INTO order_status_history(order_id, from_status, to_status, reason, changed_at) VALUES($1, $2, $3, $4, $5)",
+		id, change.From, change.To, change.Reason, change.ChangedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record order status change: %w", err)
+	}
+
+	err = notifyOrderChanged(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	return err
+}
+
+// notifyOrderChanged tells every Watcher about the order once tx commits.
+func notifyOrderChanged(ctx context.Context, tx *sql.Tx, id string) error {
+	_, err := tx.ExecContext(
+		ctx,
+		"SELECT pg_notify($1, json_build_object('order_id', id, 'account_id', account_id)::text) FROM orders WHERE id = $2",
+		orderChangesChannel, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to notify order change: %w", err)
+	}
+	return nil
+}
+
+// sagaPayload is the part of a saga stored as JSON.
+type sagaPayload struct {
+	Order           Order  `json:"order"`
+	AuthorizationId string `json:"authorization_id"`
+}
+
+func (r *postgresRepository) CreateSaga(ctx context.Context, saga *Saga) error {
+	payload, err := json.Marshal(sagaPayload{Order: saga.Order, AuthorizationId: saga.AuthorizationId})
+	if err != nil {
+		return fmt.Errorf("failed to marshal saga payload: %w", err)
+	}
+
+	_, err = r.db.ExecContext(
+		ctx,
+		"INSERT INTO order_sagas(id, status, step, payload, error, created_at, updated_at) VALUES($1, $2, $3, $4, $5, $6, $7)",
+		saga.ID, saga.Status, saga.Step, payload, saga.Error, saga.CreatedAt, saga.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert saga: %w", err)
+	}
+	return nil
+}
+
+func (r *postgresRepository) UpdateSaga(ctx context.Context, saga *Saga) error {
+	payload, err := json.Marshal(sagaPayload{Order: saga.Order, AuthorizationId: saga.AuthorizationId})
+	if err != nil {
+		return fmt.Errorf("failed to marshal saga payload: %w", err)
+	}
+
+	_, err = r.db.ExecContext(
+		ctx,
+		"UPDATE order_sagas SET status = $1, step = $2, payload = $3, error = $4, updated_at = $5 WHERE id = $6",
+		saga.Status, saga.Step, payload, saga.Error, saga.UpdatedAt, saga.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update saga: %w", err)
+	}
+	return nil
+}
+
+func (r *postgresRepository) GetUnfinishedSagas(ctx context.Context, updatedBefore time.Time) ([]*Saga, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT id, status, step, payload, error, created_at, updated_at
+		 FROM order_sagas
+		 WHERE status IN ($1, $2) AND updated_at < $3
+		 ORDER BY created_at`,
+		SagaRunning, SagaCompensating, updatedBefore,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sagas: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Warn("failed to close rows", "err", err)
+		}
+	}()
+
+	var sagas []*Saga
+	for rows.Next() {
+		saga := &Saga{}
+		var payload []byte
+		if err := rows.Scan(&saga.ID, &saga.Status, &saga.Step, &payload, &saga.Error, &saga.CreatedAt, &saga.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		var p sagaPayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal saga payload: %w", err)
+		}
+		saga.Order = p.Order
+		saga.AuthorizationId = p.AuthorizationId
+		sagas = append(sagas, saga)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return sagas, nil
+}
+
+func (r *postgresRepository) loadStatusHistory(ctx context.Context, orders []*Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	ordersMap := make(map[string]*Order, len(orders))
+	var orderIds []string
+	for _, o := range orders {
+		ordersMap[o.ID] = o
+		orderIds = append(orderIds, o.ID)
+	}
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT order_id, COALESCE(from_status, ''), to_status, reason, changed_at
+		 FROM order_status_history
+		 WHERE order_id = ANY($1)
+		 ORDER BY changed_at, id`,
+		pq.Array(orderIds),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to query order status history: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Warn("failed to close rows", "err", err)
+		}
+	}()
+
+	for rows.Next() {
+		var orderID string
+		var change StatusChange
+		if err := rows.Scan(&orderID, &change.From, &change.To, &change.Reason, &change.ChangedAt); err != nil {
+			return fmt.Errorf("failed to scan row: %w", err)
+		}
+		if o, ok := ordersMap[orderID]; ok {
+			o.StatusHistory = append(o.StatusHistory, change)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return nil
+}
+
+func (r *postgresRepository) loadExchangeRates(ctx context.Context, orders []*Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	ordersMap := make(map[string]*Order, len(orders))
+	var orderIds []string
+	for _, o := range orders {
+		ordersMap[o.ID] = o
+		orderIds = append(orderIds, o.ID)
+	}
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT order_id, from_currency, to_currency, rate::text
+		 FROM order_exchange_rates
+		 WHERE order_id = ANY($1)
+		 ORDER BY order_id, from_currency`,
+		pq.Array(orderIds),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to query order exchange rates: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Warn("failed to close rows", "err", err)
+		}
+	}()
+
+	for rows.Next() {
+		var orderID string
+		var rate money.Rate
+		if err := rows.Scan(&orderID, &rate.From, &rate.To, &rate.Value); err != nil {
+			return fmt.Errorf("failed to scan row: %w", err)
+		}
+		if o, ok := ordersMap[orderID]; ok {
+			o.ExchangeRates = append(o.ExchangeRates, rate)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return nil
+}
+
+func scanOrders(rows *sql.Rows) ([]*Order, error) {
 	// Grouping orders
 	ordersMap := make(map[string]*Order)
 	var orders []*Order
@@ -124,11 +643,14 @@ func (r *postgresRepository) GetOrdersForAccount(ctx context.Context, accountId
 	for rows.Next() {
 		var orderID, accountID string
 		var createdAt time.Time
-		var totalAmount float64
+		var totalAmount money.Money
+		var status OrderStatus
 		var productID string
 		var quantity uint32
+		var price sql.NullInt64
+		var currency sql.NullString
 
-		if err := rows.Scan(&orderID, &createdAt, &accountID, &totalAmount, &productID, &quantity); err != nil {
+		if err := rows.Scan(&orderID, &createdAt, &accountID, &totalAmount.Amount, &totalAmount.Currency, &status, &productID, &quantity, &price, &currency); err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
 
@@ -139,16 +661,22 @@ func (r *postgresRepository) GetOrdersForAccount(ctx context.Context, accountId
 				CreatedAt:   createdAt,
 				AccountId:   accountID,
 				TotalAmount: totalAmount,
+				Status:      status,
 				Products:    []OrderedProduct{},
 			}
 			orders = append(orders, ordersMap[orderID])
 		}
 
-		// Append the product to the order
-		ordersMap[orderID].Products = append(ordersMap[orderID].Products, OrderedProduct{
+		// Append the product to the order; orders placed before prices were
+		// snapshotted have no price here and are priced from the catalog
+		orderedProduct := OrderedProduct{
 			ID:       productID,
 			Quantity: quantity,
-		})
+		}
+		if price.Valid && currency.Valid {
+			orderedProduct.Price = money.Money{Amount: price.Int64, Currency: currency.String}
+		}
+		ordersMap[orderID].Products = append(ordersMap[orderID].Products, orderedProduct)
 	}
 
 	// Check for iteration errors
@@ -0,0 +1,339 @@
+package order
+
+import (
+	"context"
+	"fmt"
+	"github.com/fabian-emmanuel/go-ms/account"
+	"github.com/fabian-emmanuel/go-ms/catalog"
+	"github.com/fabian-emmanuel/go-ms/errs"
+	"log/slog"
+	"time"
+)
+
+var ErrAccountInactive = errs.FailedPrecondition("account is deactivated")
+
+type SagaStatus string
+
+const (
+	SagaRunning      SagaStatus = "running"
+	SagaCompensating SagaStatus = "compensating"
+	SagaCompleted    SagaStatus = "completed"
+	SagaAborted      SagaStatus = "aborted"
+)
+
+type SagaStep string
+
+const (
+	StepValidateAccount  SagaStep = "validate_account"
+	StepReserveStock     SagaStep = "reserve_stock"
+	StepAuthorizePayment SagaStep = "authorize_payment"
+	StepPersistOrder     SagaStep = "persist_order"
+	StepCommitStock      SagaStep = "commit_stock"
+)
+
+const (
+	// compensationTimeout bounds rollback work, which runs even if the caller has gone away.
+	compensationTimeout = 30 * time.Second
+	// sagaStaleAfter keeps recovery away from sagas another instance is still driving.
+	sagaStaleAfter = time.Minute
+	// recoveryInterval is how often unfinished sagas are looked for, and
+	// maxRecoveryBackoff how far that stretches while passes keep failing.
+	recoveryInterval   = 30 * time.Second
+	maxRecoveryBackoff = 10 * time.Minute
+)
+
+// Saga is the persisted state of one order creation. Step is the last step
+// that was started, which may not have finished; Order is the priced draft
+// the steps operate on.
+type Saga struct {
+	ID              string     `json:"id"`
+	Status          SagaStatus `json:"status"`
+	Step            SagaStep   `json:"step"`
+	Order           Order      `json:"order"`
+	AuthorizationId string     `json:"authorization_id"`
+	Error           string     `json:"error"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+type AccountGetter interface {
+	GetAccount(ctx context.Context, id string) (*account.Account, error)
+}
+
+type StockReserver interface {
+	ReserveStock(ctx context.Context, reservationId string, items []catalog.StockItem) error
+	ReleaseStock(ctx context.Context, reservationId string, productIds []string) error
+	CommitStock(ctx context.Context, reservationId string, productIds []string) error
+}
+
+type sagaStep struct {
+	name       SagaStep
+	action     func(ctx context.Context, saga *Saga) error
+	compensate func(ctx context.Context, saga *Saga) error
+	// pivot marks the step after which the saga only moves forward
+	pivot bool
+}
+
+// OrderSaga orchestrates order creation across the account, catalog and
+// payment services, undoing completed steps when a later one fails.
+type OrderSaga struct {
+	repo     Repository
+	service  Service
+	accounts AccountGetter
+	stock    StockReserver
+	payments PaymentGateway
+	steps    []sagaStep
+}
+
+func NewOrderSaga(repo Repository, service Service, accounts AccountGetter, stock StockReserver, payments PaymentGateway) *OrderSaga {
+	s := &OrderSaga{
+		repo:     repo,
+		service:  service,
+		accounts: accounts,
+		stock:    stock,
+		payments: payments,
+	}
+
+	s.steps = []sagaStep{
+		{name: StepValidateAccount, action: s.validateAccount},
+		{name: StepReserveStock, action: s.reserveStock, compensate: s.releaseStock},
+		{name: StepAuthorizePayment, action: s.authorizePayment, compensate: s.voidPayment},
+		{name: StepPersistOrder, action: s.persistOrder, pivot: true},
+		{name: StepCommitStock, action: s.commitStock},
+	}
+	return s
+}
+
+// Execute prices the order and runs every step. On failure before the order
+// is persisted, completed steps are compensated and the step's error is returned.
+func (s *OrderSaga) Execute(ctx context.Context, accountId, currency string, products []OrderedProduct) (*Order, error) {
+	order, err := s.service.PrepareOrder(ctx, accountId, currency, products)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	saga := &Saga{
+		ID:        order.ID,
+		Status:    SagaRunning,
+		Order:     *order,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.repo.CreateSaga(ctx, saga); err != nil {
+		return nil, err
+	}
+
+	return s.run(ctx, saga, 0)
+}
+
+// RunRecovery calls Recover until ctx is done, so sagas left behind by a
+// crash, a failed compensation or a failed step after the pivot are retried
+// for as long as the service runs. Passes that leave sagas unfinished back
+// off up to maxRecoveryBackoff.
+func (s *OrderSaga) RunRecovery(ctx context.Context) {
+	ticker := time.NewTicker(recoveryInterval)
+	defer ticker.Stop()
+
+	wait := recoveryInterval
+	for {
+		if err := s.Recover(ctx); err != nil {
+			slog.ErrorContext(ctx, "Error recovering order sagas", "err", err)
+			wait = min(wait*2, maxRecoveryBackoff)
+		} else {
+			wait = recoveryInterval
+		}
+		ticker.Reset(wait)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Recover finishes sagas that have not moved for sagaStaleAfter. Sagas that
+// reached the pivot are driven forward, retrying the step they were in;
+// anything earlier is rolled back, including the step that was in flight,
+// since its caller has already seen a failure. It fails if any saga is still
+// unfinished afterwards.
+func (s *OrderSaga) Recover(ctx context.Context) error {
+	sagas, err := s.repo.GetUnfinishedSagas(ctx, time.Now().UTC().Add(-sagaStaleAfter))
+	if err != nil {
+		return err
+	}
+
+	unfinished := 0
+	for _, saga := range sagas {
+		if saga.Status == SagaRunning && s.pastPivot(saga.Step) {
+			slog.InfoContext(ctx, "Resuming order saga", "saga_id", saga.ID, "step", saga.Step)
+			if _, err := s.run(ctx, saga, s.stepIndex(saga.Step)); err != nil {
+				slog.ErrorContext(ctx, "Error resuming order saga", "saga_id", saga.ID, "err", err)
+			}
+		} else {
+			slog.InfoContext(ctx, "Rolling back order saga", "saga_id", saga.ID, "step", saga.Step)
+			if saga.Error == "" {
+				saga.Error = "interrupted before completion"
+			}
+			s.compensate(ctx, saga)
+		}
+
+		if saga.Status != SagaCompleted && saga.Status != SagaAborted {
+			unfinished++
+		}
+	}
+
+	if unfinished > 0 {
+		return fmt.Errorf("%d of %d order sagas are still unfinished", unfinished, len(sagas))
+	}
+	return nil
+}
+
+// run executes the steps from index from on. Every step is saved before its
+// action runs, so a crash part way through still leaves the step on record to
+// be compensated or retried.
+func (s *OrderSaga) run(ctx context.Context, saga *Saga, from int) (*Order, error) {
+	for i := from; i < len(s.steps); i++ {
+		step := s.steps[i]
+		saga.Step = step.name
+		err := s.save(ctx, saga)
+		if err == nil {
+			err = step.action(ctx, saga)
+		}
+		if err != nil {
+			if s.pivotBefore(i) {
+				// The order exists; leave the saga running so recovery retries the remaining steps
+				saga.Error = err.Error()
+				s.save(ctx, saga)
+				slog.ErrorContext(ctx, "Order saga failed after the order was persisted", "saga_id", saga.ID, "step", step.name, "err", err)
+				return &saga.Order, nil
+			}
+			saga.Error = err.Error()
+			s.compensate(ctx, saga)
+			return nil, err
+		}
+	}
+
+	saga.Status = SagaCompleted
+	saga.Error = ""
+	s.save(ctx, saga)
+	return &saga.Order, nil
+}
+
+func (s *OrderSaga) compensate(ctx context.Context, saga *Saga) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), compensationTimeout)
+	defer cancel()
+
+	saga.Status = SagaCompensating
+	s.save(ctx, saga)
+
+	for i := s.stepIndex(saga.Step); i >= 0; i-- {
+		step := s.steps[i]
+		if step.compensate == nil {
+			continue
+		}
+		if err := step.compensate(ctx, saga); err != nil {
+			// Stay in compensating so the next recovery picks it up again
+			slog.ErrorContext(ctx, "Error compensating order saga step", "saga_id", saga.ID, "step", step.name, "err", err)
+			return
+		}
+	}
+
+	saga.Status = SagaAborted
+	s.save(ctx, saga)
+}
+
+func (s *OrderSaga) save(ctx context.Context, saga *Saga) error {
+	saga.UpdatedAt = time.Now().UTC()
+	if err := s.repo.UpdateSaga(ctx, saga); err != nil {
+		slog.ErrorContext(ctx, "Error saving order saga", "saga_id", saga.ID, "err", err)
+		return err
+	}
+	return nil
+}
+
+// stepIndex returns the position of step, or -1 when no step has started yet.
+func (s *OrderSaga) stepIndex(step SagaStep) int {
+	for i, st := range s.steps {
+		if st.name == step {
+			return i
+		}
+	}
+	return -1
+}
+
+// pivotBefore reports whether a step before position i is the pivot.
+func (s *OrderSaga) pivotBefore(i int) bool {
+	for j := 0; j < i; j++ {
+		if s.steps[j].pivot {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *OrderSaga) pastPivot(step SagaStep) bool {
+	for i := s.stepIndex(step); i >= 0; i-- {
+		if s.steps[i].pivot {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *OrderSaga) validateAccount(ctx context.Context, saga *Saga) error {
+	a, err := s.accounts.GetAccount(ctx, saga.Order.AccountId)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error getting account", "err", err)
+		return err
+	}
+	if !a.IsActive() {
+		return ErrAccountInactive
+	}
+	return nil
+}
+
+func (s *OrderSaga) reserveStock(ctx context.Context, saga *Saga) error {
+	var items []catalog.StockItem
+	for _, p := range saga.Order.Products {
+		items = append(items, catalog.StockItem{ProductID: p.ID, Quantity: p.Quantity})
+	}
+	return s.stock.ReserveStock(ctx, saga.ID, items)
+}
+
+func (s *OrderSaga) releaseStock(ctx context.Context, saga *Saga) error {
+	return s.stock.ReleaseStock(ctx, saga.ID, saga.Order.productIds())
+}
+
+func (s *OrderSaga) authorizePayment(ctx context.Context, saga *Saga) error {
+	authorizationId, err := s.payments.Authorize(ctx, saga.ID, saga.Order.TotalAmount)
+	if err != nil {
+		return fmt.Errorf("payment authorization failed: %w", err)
+	}
+	saga.AuthorizationId = authorizationId
+	return nil
+}
+
+// voidPayment also voids an authorization whose ID was lost to a crash.
+// Authorizing the same order again returns that authorization, and when it
+// fails there is nothing to void.
+func (s *OrderSaga) voidPayment(ctx context.Context, saga *Saga) error {
+	if saga.AuthorizationId == "" {
+		authorizationId, err := s.payments.Authorize(ctx, saga.ID, saga.Order.TotalAmount)
+		if err != nil {
+			slog.WarnContext(ctx, "No payment authorization to void", "saga_id", saga.ID, "err", err)
+			return nil
+		}
+		saga.AuthorizationId = authorizationId
+	}
+	return s.payments.Void(ctx, saga.AuthorizationId)
+}
+
+func (s *OrderSaga) persistOrder(ctx context.Context, saga *Saga) error {
+	return s.service.PlaceOrder(ctx, &saga.Order)
+}
+
+func (s *OrderSaga) commitStock(ctx context.Context, saga *Saga) error {
+	return s.stock.CommitStock(ctx, saga.ID, saga.Order.productIds())
+}
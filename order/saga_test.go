@@ -0,0 +1,338 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"github.com/fabian-emmanuel/go-ms/account"
+	"github.com/fabian-emmanuel/go-ms/catalog"
+	"github.com/fabian-emmanuel/go-ms/money"
+	"sync"
+	"testing"
+	"time"
+)
+
+// errCrash is panicked with to stop a saga as a process crash would.
+var errCrash = errors.New("crash")
+
+type sagaRepo struct {
+	Repository
+	mu        sync.Mutex
+	sagas     map[string]Saga
+	failSaves map[SagaStep]error
+}
+
+func newSagaRepo() *sagaRepo {
+	return &sagaRepo{sagas: map[string]Saga{}, failSaves: map[SagaStep]error{}}
+}
+
+func (r *sagaRepo) CreateSaga(_ context.Context, saga *Saga) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sagas[saga.ID] = *saga
+	return nil
+}
+
+func (r *sagaRepo) UpdateSaga(_ context.Context, saga *Saga) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.failSaves[saga.Step]; err != nil && saga.Status == SagaRunning {
+		return err
+	}
+	r.sagas[saga.ID] = *saga
+	return nil
+}
+
+func (r *sagaRepo) GetUnfinishedSagas(_ context.Context, _ time.Time) ([]*Saga, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var sagas []*Saga
+	for _, saga := range r.sagas {
+		if saga.Status == SagaRunning || saga.Status == SagaCompensating {
+			sagas = append(sagas, &saga)
+		}
+	}
+	return sagas, nil
+}
+
+func (r *sagaRepo) get(id string) Saga {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sagas[id]
+}
+
+type sagaService struct {
+	Service
+	placed map[string]bool
+	crash  bool
+}
+
+func (s *sagaService) PrepareOrder(_ context.Context, accountId, currency string, products []OrderedProduct) (*Order, error) {
+	return &Order{
+		ID:          "order1",
+		AccountId:   accountId,
+		TotalAmount: money.Money{Amount: 1000, Currency: currency},
+		Products:    products,
+	}, nil
+}
+
+func (s *sagaService) PlaceOrder(_ context.Context, order *Order) error {
+	s.placed[order.ID] = true
+	if s.crash {
+		panic(errCrash)
+	}
+	return nil
+}
+
+type sagaAccounts struct{}
+
+func (sagaAccounts) GetAccount(_ context.Context, id string) (*account.Account, error) {
+	return &account.Account{ID: id, Status: account.StatusActive}, nil
+}
+
+type sagaStock struct {
+	reserved  map[string]bool
+	committed map[string]bool
+	crash     SagaStep
+	// failReleases and failCommits are how many calls fail before one succeeds
+	failReleases int
+	failCommits  int
+}
+
+func (s *sagaStock) ReserveStock(_ context.Context, reservationId string, _ []catalog.StockItem) error {
+	s.reserved[reservationId] = true
+	if s.crash == StepReserveStock {
+		panic(errCrash)
+	}
+	return nil
+}
+
+func (s *sagaStock) ReleaseStock(_ context.Context, reservationId string, _ []string) error {
+	if s.failReleases > 0 {
+		s.failReleases--
+		return errors.New("catalog unavailable")
+	}
+	delete(s.reserved, reservationId)
+	return nil
+}
+
+func (s *sagaStock) CommitStock(_ context.Context, reservationId string, _ []string) error {
+	if s.failCommits > 0 {
+		s.failCommits--
+		return errors.New("catalog unavailable")
+	}
+	if s.reserved[reservationId] {
+		s.committed[reservationId] = true
+		delete(s.reserved, reservationId)
+	}
+	if s.crash == StepCommitStock {
+		panic(errCrash)
+	}
+	return nil
+}
+
+type sagaPayments struct {
+	authorized map[string]string
+	voided     map[string]bool
+	decline    bool
+	crash      bool
+}
+
+func (p *sagaPayments) Authorize(_ context.Context, orderId string, _ money.Money) (string, error) {
+	if p.decline {
+		return "", errors.New("card declined")
+	}
+	id := "auth_" + orderId
+	p.authorized[orderId] = id
+	if p.crash {
+		panic(errCrash)
+	}
+	return id, nil
+}
+
+func (p *sagaPayments) Void(_ context.Context, authorizationId string) error {
+	p.voided[authorizationId] = true
+	return nil
+}
+
+type sagaFixture struct {
+	repo     *sagaRepo
+	service  *sagaService
+	stock    *sagaStock
+	payments *sagaPayments
+}
+
+func newSagaFixture() *sagaFixture {
+	return &sagaFixture{
+		repo:     newSagaRepo(),
+		service:  &sagaService{placed: map[string]bool{}},
+		stock:    &sagaStock{reserved: map[string]bool{}, committed: map[string]bool{}},
+		payments: &sagaPayments{authorized: map[string]string{}, voided: map[string]bool{}},
+	}
+}
+
+func (f *sagaFixture) saga() *OrderSaga {
+	return NewOrderSaga(f.repo, f.service, sagaAccounts{}, f.stock, f.payments)
+}
+
+func (f *sagaFixture) execute() (*Order, error) {
+	return f.saga().Execute(context.Background(), "account1", "USD", []OrderedProduct{{ID: "product1", Quantity: 2}})
+}
+
+// executeUntilCrash runs a saga that one of the fakes stops with errCrash.
+func (f *sagaFixture) executeUntilCrash(t *testing.T) {
+	t.Helper()
+	defer func() {
+		if r := recover(); r != errCrash {
+			t.Fatalf("expected a crash, got %v", r)
+		}
+	}()
+	f.execute()
+}
+
+func TestOrderSagaCompletes(t *testing.T) {
+	f := newSagaFixture()
+	order, err := f.execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.service.placed[order.ID] || !f.stock.committed[order.ID] {
+		t.Errorf("order placed %v, stock committed %v", f.service.placed[order.ID], f.stock.committed[order.ID])
+	}
+	if saga := f.repo.get(order.ID); saga.Status != SagaCompleted || saga.AuthorizationId != "auth_order1" {
+		t.Errorf("saga = %+v", saga)
+	}
+}
+
+func TestOrderSagaCompensatesFailedStep(t *testing.T) {
+	f := newSagaFixture()
+	f.payments.decline = true
+
+	if _, err := f.execute(); err == nil {
+		t.Fatal("expected the declined payment to fail the saga")
+	}
+	if len(f.stock.reserved) != 0 {
+		t.Errorf("stock still reserved: %v", f.stock.reserved)
+	}
+	if len(f.payments.voided) != 0 {
+		t.Errorf("voided %v without an authorization", f.payments.voided)
+	}
+	if saga := f.repo.get("order1"); saga.Status != SagaAborted || saga.Error == "" {
+		t.Errorf("saga = %+v", saga)
+	}
+}
+
+func TestOrderSagaCompensatesFailedSave(t *testing.T) {
+	f := newSagaFixture()
+	f.repo.failSaves[StepAuthorizePayment] = errors.New("database down")
+
+	if _, err := f.execute(); err == nil {
+		t.Fatal("expected the failed save to fail the saga")
+	}
+	if len(f.stock.reserved) != 0 {
+		t.Errorf("stock still reserved: %v", f.stock.reserved)
+	}
+	if f.service.placed["order1"] {
+		t.Error("order placed although the saga failed")
+	}
+	if saga := f.repo.get("order1"); saga.Status != SagaAborted {
+		t.Errorf("saga = %+v", saga)
+	}
+}
+
+func TestOrderSagaRecoversFromCrash(t *testing.T) {
+	tests := []struct {
+		name  string
+		crash func(f *sagaFixture)
+		// rolledBack sagas must have released their stock and voided their
+		// payment; the others must have completed
+		rolledBack bool
+	}{
+		{"after reserving stock", func(f *sagaFixture) { f.stock.crash = StepReserveStock }, true},
+		{"after authorizing payment", func(f *sagaFixture) { f.payments.crash = true }, true},
+		{"after persisting the order", func(f *sagaFixture) { f.service.crash = true }, false},
+		{"after committing stock", func(f *sagaFixture) { f.stock.crash = StepCommitStock }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newSagaFixture()
+			tt.crash(f)
+			f.executeUntilCrash(t)
+
+			// Restart with fakes that no longer crash
+			f.stock.crash = ""
+			f.payments.crash = false
+			f.service.crash = false
+			if err := f.saga().Recover(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			saga := f.repo.get("order1")
+			if tt.rolledBack {
+				if saga.Status != SagaAborted {
+					t.Errorf("status = %s, want %s", saga.Status, SagaAborted)
+				}
+				if len(f.stock.reserved) != 0 {
+					t.Errorf("stock still reserved: %v", f.stock.reserved)
+				}
+				if id, ok := f.payments.authorized["order1"]; ok && !f.payments.voided[id] {
+					t.Errorf("authorization %s not voided", id)
+				}
+				return
+			}
+
+			if saga.Status != SagaCompleted {
+				t.Errorf("status = %s, want %s", saga.Status, SagaCompleted)
+			}
+			if !f.service.placed["order1"] || !f.stock.committed["order1"] {
+				t.Errorf("order placed %v, stock committed %v", f.service.placed["order1"], f.stock.committed["order1"])
+			}
+			if len(f.payments.voided) != 0 {
+				t.Errorf("voided %v for a completed order", f.payments.voided)
+			}
+		})
+	}
+}
+
+func TestOrderSagaRetriesOnLaterPasses(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(f *sagaFixture)
+		// passes is how many recovery passes leave the saga unfinished
+		passes int
+		want   SagaStatus
+	}{
+		{"failed compensation", func(f *sagaFixture) {
+			f.payments.decline = true
+			f.stock.failReleases = 2
+		}, 1, SagaAborted},
+		{"failed commit after the pivot", func(f *sagaFixture) {
+			f.stock.failCommits = 2
+		}, 1, SagaCompleted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newSagaFixture()
+			tt.setup(f)
+			f.execute()
+
+			if saga := f.repo.get("order1"); saga.Status == tt.want {
+				t.Fatalf("saga finished before recovery: %+v", saga)
+			}
+			for i := 0; i < tt.passes; i++ {
+				if err := f.saga().Recover(context.Background()); err == nil {
+					t.Fatalf("pass %d reported success with the saga unfinished", i+1)
+				}
+			}
+			if err := f.saga().Recover(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			if saga := f.repo.get("order1"); saga.Status != tt.want {
+				t.Errorf("status = %s, want %s", saga.Status, tt.want)
+			}
+			if len(f.stock.reserved) != 0 {
+				t.Errorf("stock still reserved: %v", f.stock.reserved)
+			}
+		})
+	}
+}
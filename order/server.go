@@ -1,33 +1,59 @@
-//go:generate protoc --go_out=./pb --go-grpc_out=./pb order.proto
+//go:generate protoc -I . -I .. --go_out=./pb --go-grpc_out=./pb order.proto
 package order
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"github.com/fabian-emmanuel/go-ms/account"
+	"github.com/fabian-emmanuel/go-ms/auth"
 	"github.com/fabian-emmanuel/go-ms/catalog"
+	"github.com/fabian-emmanuel/go-ms/errs"
+	"github.com/fabian-emmanuel/go-ms/healthcheck"
+	"github.com/fabian-emmanuel/go-ms/idempotency"
+	"github.com/fabian-emmanuel/go-ms/lifecycle"
+	"github.com/fabian-emmanuel/go-ms/logging"
+	"github.com/fabian-emmanuel/go-ms/metrics"
+	"github.com/fabian-emmanuel/go-ms/money"
+	"github.com/fabian-emmanuel/go-ms/mtls"
 	"github.com/fabian-emmanuel/go-ms/order/pb"
+	"github.com/fabian-emmanuel/go-ms/tracing"
+	"github.com/fabian-emmanuel/go-ms/validation"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/reflection"
-	"log"
+	"google.golang.org/grpc/status"
+	"log/slog"
 	"net"
+	"time"
 )
 
 type grpcServer struct {
 	service       Service
+	saga          *OrderSaga
 	accountClient *account.Client
 	catalogClient *catalog.Client
+	watcher       *Watcher
 	pb.UnimplementedOrderServiceServer
 }
 
-func ListenGRPC(s Service, accountServiceUrl, catalogServiceUrl string, port int) error {
-	accountClient, err := account.NewClient(accountServiceUrl)
+// permissions are what callers need for the RPCs that are not open to every
+// account holder. The others check that the caller owns the orders involved.
+var permissions = map[string]auth.Permission{
+	pb.OrderService_UpdateOrderStatus_FullMethodName: auth.PermManageOrders,
+}
+
+func ListenGRPC(ctx context.Context, s Service, r Repository, payments PaymentGateway, watcher *Watcher, verifier auth.TokenVerifier, accountServiceUrl, catalogServiceUrl, serviceAccountEmail, serviceAccountPassword string, creds mtls.Config, port int, shutdownTimeout time.Duration) error {
+	transport, err := creds.ServerOption(ctx)
+	if err != nil {
+		return err
+	}
+
+	accountClient, err := account.NewClient(accountServiceUrl, creds)
 	if err != nil {
 		return err
 	}
 
-	catalogClient, err := catalog.NewClient(catalogServiceUrl)
+	catalogClient, err := catalog.NewClient(catalogServiceUrl, creds)
 	if err != nil {
 		accountClient.Close()
 		return err
@@ -40,32 +66,86 @@ func ListenGRPC(s Service, accountServiceUrl, catalogServiceUrl string, port int
 		return err
 	}
 
-	serv := grpc.NewServer()
-	pb.RegisterOrderServiceServer(serv, &grpcServer{s, accountClient, catalogClient, pb.UnimplementedOrderServiceServer{}})
+	stock := serviceStock{catalog: catalogClient}
+	if serviceAccountEmail != "" {
+		stock.tokens = accountClient.TokenSource(serviceAccountEmail, serviceAccountPassword)
+	} else if !creds.MutualTLS() {
+		slog.WarnContext(ctx, "No service account configured and mutual TLS is off, the catalog will refuse stock reservations")
+	}
+
+	saga := NewOrderSaga(r, s, accountClient, stock, payments)
+	go saga.RunRecovery(ctx)
+
+	serv := grpc.NewServer(
+		transport,
+		tracing.ServerOption(),
+		grpc.ChainUnaryInterceptor(
+			logging.UnaryServerInterceptor(),
+			metrics.UnaryServerInterceptor(),
+			errs.UnaryServerInterceptor(),
+			auth.UnaryServerInterceptor(verifier, permissions),
+			validation.UnaryServerInterceptor(validators),
+			idempotency.UnaryServerInterceptor(r, idempotency.DefaultTTL, nil, pb.OrderService_CreateOrder_FullMethodName),
+		),
+		grpc.ChainStreamInterceptor(
+			logging.StreamServerInterceptor(),
+			metrics.StreamServerInterceptor(),
+			errs.StreamServerInterceptor(),
+			auth.StreamServerInterceptor(verifier, permissions),
+			validation.StreamServerInterceptor(validators),
+		),
+	)
+	h := healthcheck.Register(ctx, serv, pb.OrderService_ServiceDesc.ServiceName, r.Ping)
+	pb.RegisterOrderServiceServer(serv, &grpcServer{s, saga, accountClient, catalogClient, watcher, pb.UnimplementedOrderServiceServer{}})
 	reflection.Register(serv)
-	return serv.Serve(lis)
+	err = lifecycle.ServeGRPC(ctx, serv, lis, h, shutdownTimeout)
+
+	// Only close the downstream clients once no RPC can use them any more
+	catalogClient.Close()
+	accountClient.Close()
+	return err
 }
 
 func (s *grpcServer) CreateOrder(ctx context.Context, req *pb.CreateOrderRequest) (*pb.CreateOrderResponse, error) {
-	_, err := s.accountClient.GetAccount(ctx, req.AccountId)
-	if err != nil {
-		log.Println("Error getting account: ", err)
-		return nil, errors.New("account not found")
+	if err := auth.RequireAccountAccess(ctx, req.AccountId); err != nil {
+		return nil, err
+	}
+
+	// List prices are keyed by the normalized code, so "usd" must find "USD"
+	currency := req.Currency
+	if currency != "" {
+		var err error
+		if currency, err = money.NormalizeCurrency(currency); err != nil {
+			return nil, err
+		}
 	}
 
 	var productIds []string
+	for _, rp := range req.OrderProducts {
+		productIds = append(productIds, rp.ProductId)
+	}
+
 	orderedProducts, err := s.catalogClient.GetProductsByIds(ctx, productIds, 0, 0)
 	if err != nil {
-		log.Println("Error getting products: ", err)
-		return nil, errors.New("products not found")
+		slog.ErrorContext(ctx, "Error getting products", "err", err)
+		return nil, err
+	}
+	if err := checkProductsFound(productIds, orderedProducts); err != nil {
+		return nil, err
 	}
 
 	var products []OrderedProduct
 	for _, p := range orderedProducts {
+		// Prefer a list price in the checkout currency over converting the base price
+		price := p.Price
+		if listPrice, ok := p.PriceIn(currency); ok {
+			price = listPrice
+		}
+
 		product := OrderedProduct{
 			ID:          p.ID,
 			Quantity:    0,
-			Price:       p.Price,
+			Price:       price,
 			Name:        p.Name,
 			Description: p.Description,
 		}
@@ -83,55 +163,156 @@ func (s *grpcServer) CreateOrder(ctx context.Context, req *pb.CreateOrderRequest
 
 	}
 
-	order, err := s.service.CreateOrder(ctx, req.AccountId, products)
+	order, err := s.saga.Execute(ctx, req.AccountId, currency, products)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error creating order", "err", err)
+		return nil, err
+	}
+
+	return &pb.CreateOrderResponse{Order: orderToProto(order)}, nil
+
+}
+
+func (s *grpcServer) GetOrdersForAccount(ctx context.Context, req *pb.GetOrdersForAccountRequest) (*pb.GetOrdersForAccountResponse, error) {
+	if err := auth.RequireAccountAccess(ctx, req.AccountId); err != nil {
+		return nil, err
+	}
 
+	_, err := s.accountClient.GetAccount(ctx, req.AccountId)
 	if err != nil {
-		log.Println("Error creating order: ", err)
-		return nil, errors.New("error creating order")
+		slog.ErrorContext(ctx, "Error getting account", "err", err)
+		return nil, err
 	}
 
-	orderProto := &pb.Order{
-		Id:              order.ID,
-		AccountId:       order.AccountId,
-		TotalAmount:     order.TotalAmount,
-		OrderedProducts: []*pb.OrderedProduct{},
+	accountOrders, err := s.service.GetOrdersForAccount(ctx, req.AccountId)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error getting orders", "err", err)
+		return nil, err
 	}
 
-	orderProto.CreatedAt, _ = order.CreatedAt.MarshalBinary()
+	if err := s.addProductDetails(ctx, accountOrders); err != nil {
+		return nil, err
+	}
 
-	for _, p := range order.Products {
-		orderProto.OrderedProducts = append(orderProto.OrderedProducts, &pb.OrderedProduct{
-			Id:          p.ID,
-			Name:        p.Name,
-			Description: p.Description,
-			Quantity:    p.Quantity,
-			Price:       p.Price,
-		})
+	var orders []*pb.Order
+	for _, o := range accountOrders {
+		orders = append(orders, orderToProto(o))
+	}
+
+	return &pb.GetOrdersForAccountResponse{Orders: orders}, nil
+}
+
+// GetOrdersForAccounts returns the orders of every account in one call. Unlike
+// GetOrdersForAccount it neither checks that the accounts exist nor fills in
+// product names and descriptions; callers batching lookups do that themselves.
+func (s *grpcServer) GetOrdersForAccounts(ctx context.Context, req *pb.GetOrdersForAccountsRequest) (*pb.GetOrdersForAccountsResponse, error) {
+	for _, id := range req.AccountIds {
+		if err := auth.RequireAccountAccess(ctx, id); err != nil {
+			return nil, err
+		}
 	}
 
-	return &pb.CreateOrderResponse{Order: orderProto}, nil
+	accountOrders, err := s.service.GetOrdersForAccounts(ctx, req.AccountIds)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error getting orders", "err", err)
+		return nil, err
+	}
 
+	res := &pb.GetOrdersForAccountsResponse{}
+	for _, o := range accountOrders {
+		res.Orders = append(res.Orders, orderToProto(o))
+	}
+	return res, nil
 }
 
-func (s *grpcServer) GetOrdersForAccount(ctx context.Context, req *pb.GetOrdersForAccountRequest) (*pb.GetOrdersForAccountResponse, error) {
-	_, err := s.accountClient.GetAccount(ctx, req.AccountId)
+func (s *grpcServer) GetOrder(ctx context.Context, req *pb.GetOrderRequest) (*pb.GetOrderResponse, error) {
+	order, err := s.service.GetOrder(ctx, req.Id)
 	if err != nil {
-		log.Println("Error getting account: ", err)
-		return nil, errors.New("account not found")
+		slog.ErrorContext(ctx, "Error getting order", "err", err)
+		return nil, err
+	}
+	if err := requireOrderAccess(ctx, order); err != nil {
+		return nil, err
 	}
 
-	accountOrders, err := s.service.GetOrdersForAccount(ctx, req.AccountId)
+	if err := s.addProductDetails(ctx, []*Order{order}); err != nil {
+		return nil, err
+	}
+
+	return &pb.GetOrderResponse{Order: orderToProto(order)}, nil
+}
+
+func (s *grpcServer) ListOrders(ctx context.Context, req *pb.ListOrdersRequest) (*pb.ListOrdersResponse, error) {
+	query := ListOrdersQuery{
+		Sort:  OrderSort(req.Sort),
+		First: int(req.First),
+		After: req.After,
+	}
+
+	if f := req.Filter; f != nil {
+		query.Filter.AccountId = f.AccountId
+		for _, status := range f.Statuses {
+			query.Filter.Statuses = append(query.Filter.Statuses, OrderStatus(status))
+		}
+		if len(f.CreatedAfter) > 0 {
+			if err := query.Filter.CreatedAfter.UnmarshalBinary(f.CreatedAfter); err != nil {
+				return nil, err
+			}
+		}
+		if len(f.CreatedBefore) > 0 {
+			if err := query.Filter.CreatedBefore.UnmarshalBinary(f.CreatedBefore); err != nil {
+				return nil, err
+			}
+		}
+		if f.MinTotal != nil {
+			minTotal := money.FromProto(f.MinTotal)
+			query.Filter.MinTotal = &minTotal
+		}
+	}
+
+	// Only order managers may list the orders of every account
+	if query.Filter.AccountId == "" {
+		if err := auth.RequirePermission(ctx, auth.PermManageOrders); err != nil {
+			return nil, err
+		}
+	} else if err := auth.RequireAccountAccess(ctx, query.Filter.AccountId); err != nil {
+		return nil, err
+	}
+
+	page, err := s.service.ListOrders(ctx, query)
 	if err != nil {
-		log.Println("Error getting orders: ", err)
-		return nil, errors.New("error getting orders")
+		slog.ErrorContext(ctx, "Error listing orders", "err", err)
+		return nil, err
+	}
+
+	if err := s.addProductDetails(ctx, page.Orders); err != nil {
+		return nil, err
 	}
 
+	res := &pb.ListOrdersResponse{
+		EndCursor:   page.EndCursor,
+		HasNextPage: page.HasNextPage,
+		Cursors:     page.Cursors,
+		TotalCount:  uint64(page.TotalCount),
+	}
+	for _, o := range page.Orders {
+		res.Orders = append(res.Orders, orderToProto(o))
+	}
+	return res, nil
+}
+
+// addProductDetails fills in product names and descriptions from the catalog,
+// and prices for orders placed before prices were snapshotted.
+func (s *grpcServer) addProductDetails(ctx context.Context, orders []*Order) error {
 	productIdMap := map[string]bool{}
-	for _, o := range accountOrders {
+	for _, o := range orders {
 		for _, p := range o.Products {
 			productIdMap[p.ID] = true
 		}
 	}
+	if len(productIdMap) == 0 {
+		return nil
+	}
 
 	var productIds []string
 	for id := range productIdMap {
@@ -140,40 +321,172 @@ func (s *grpcServer) GetOrdersForAccount(ctx context.Context, req *pb.GetOrdersF
 
 	products, err := s.catalogClient.GetProductsByIds(ctx, productIds, 0, 0)
 	if err != nil {
-		log.Println("Error getting products: ", err)
-		return nil, errors.New("products not found")
+		slog.ErrorContext(ctx, "Error getting products", "err", err)
+		return err
 	}
 
-	var orders []*pb.Order
-	for _, o := range accountOrders {
-		op := &pb.Order{
-			Id:              o.ID,
-			AccountId:       o.AccountId,
-			TotalAmount:     o.TotalAmount,
-			OrderedProducts: []*pb.OrderedProduct{},
-		}
-
-		op.CreatedAt, _ = o.CreatedAt.MarshalBinary()
-
-		for _, product := range o.Products {
+	for _, o := range orders {
+		for i, product := range o.Products {
 			for _, p := range products {
 				if p.ID == product.ID {
-					product.Name = p.Name
-					product.Description = p.Description
-					product.Price = p.Price
+					o.Products[i].Name = p.Name
+					o.Products[i].Description = p.Description
+					if product.Price.Currency == "" {
+						o.Products[i].Price = p.Price
+					}
 				}
 			}
-			op.OrderedProducts = append(op.OrderedProducts, &pb.OrderedProduct{
-				Id:          product.ID,
-				Name:        product.Name,
-				Description: product.Description,
-				Quantity:    product.Quantity,
-				Price:       product.Price,
-			})
 		}
+	}
+
+	return nil
+}
 
-		orders = append(orders, op)
+func (s *grpcServer) UpdateOrderStatus(ctx context.Context, req *pb.UpdateOrderStatusRequest) (*pb.UpdateOrderStatusResponse, error) {
+	status, err := ParseOrderStatus(req.Status)
+	if err != nil {
+		return nil, err
 	}
 
-	return &pb.GetOrdersForAccountResponse{Orders: orders}, nil
+	order, err := s.service.UpdateOrderStatus(ctx, req.OrderId, status, req.Reason)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error updating order status", "err", err)
+		return nil, err
+	}
+
+	return &pb.UpdateOrderStatusResponse{Order: orderToProto(order)}, nil
+}
+
+func (s *grpcServer) CancelOrder(ctx context.Context, req *pb.CancelOrderRequest) (*pb.CancelOrderResponse, error) {
+	existing, err := s.service.GetOrder(ctx, req.OrderId)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error getting order", "err", err)
+		return nil, err
+	}
+	if err := requireOrderAccess(ctx, existing); err != nil {
+		return nil, err
+	}
+
+	order, err := s.service.CancelOrder(ctx, req.OrderId, req.Reason)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error cancelling order", "err", err)
+		return nil, err
+	}
+
+	return &pb.CancelOrderResponse{Order: orderToProto(order)}, nil
+}
+
+// WatchOrders streams an order every time it is created or changes status,
+// starting with its current state when watching a single order. Orders that
+// change again before the client has received them are sent once, and a
+// client that falls too far behind has its stream ended with
+// ErrWatchTooSlow.
+func (s *grpcServer) WatchOrders(req *pb.WatchOrdersRequest, stream pb.OrderService_WatchOrdersServer) error {
+	ctx := stream.Context()
+
+	if req.OrderId == "" {
+		if err := auth.RequireAccountAccess(ctx, req.AccountId); err != nil {
+			return err
+		}
+	}
+
+	// Watch before looking anything up, so no change slips in between
+	watch := s.watcher.Watch(req.OrderId, req.AccountId)
+	defer watch.Close()
+
+	var ids []string
+	if req.OrderId != "" {
+		ids = []string{req.OrderId}
+	} else if _, err := s.accountClient.GetAccount(ctx, req.AccountId); err != nil {
+		slog.ErrorContext(ctx, "Error getting account", "err", err)
+		return err
+	}
+
+	for {
+		for _, id := range ids {
+			order, err := s.service.GetOrder(ctx, id)
+			if err != nil {
+				slog.ErrorContext(ctx, "Error getting order", "err", err)
+				return err
+			}
+			// The owner of a watched order is only known once it is loaded
+			if err := requireOrderAccess(ctx, order); err != nil {
+				return err
+			}
+			if err := s.addProductDetails(ctx, []*Order{order}); err != nil {
+				return err
+			}
+			if err := stream.Send(&pb.WatchOrdersResponse{Order: orderToProto(order)}); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		if ids, err = watch.Next(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// requireOrderAccess fails unless the caller may see the order. Orders of other
+// accounts are reported missing so their IDs can't be probed.
+func requireOrderAccess(ctx context.Context, o *Order) error {
+	err := auth.RequireAccountAccess(ctx, o.AccountId)
+	if status.Code(err) == codes.PermissionDenied {
+		return ErrOrderNotFound.WithResource("order", o.ID)
+	}
+	return err
+}
+
+// checkProductsFound reports the first requested product the catalog does not have.
+func checkProductsFound(ids []string, products []*catalog.Product) error {
+	found := map[string]bool{}
+	for _, p := range products {
+		found[p.ID] = true
+	}
+	for _, id := range ids {
+		if !found[id] {
+			return catalog.ErrProductNotFound.WithResource("product", id)
+		}
+	}
+	return nil
+}
+
+func orderToProto(o *Order) *pb.Order {
+	op := &pb.Order{
+		Id:              o.ID,
+		AccountId:       o.AccountId,
+		TotalAmount:     o.TotalAmount.Proto(),
+		Status:          string(o.Status),
+		OrderedProducts: []*pb.OrderedProduct{},
+		StatusHistory:   []*pb.OrderStatusChange{},
+	}
+
+	op.CreatedAt, _ = o.CreatedAt.MarshalBinary()
+
+	for _, p := range o.Products {
+		op.OrderedProducts = append(op.OrderedProducts, &pb.OrderedProduct{
+			Id:          p.ID,
+			Name:        p.Name,
+			Description: p.Description,
+			Quantity:    p.Quantity,
+			Price:       p.Price.Proto(),
+		})
+	}
+
+	for _, rate := range o.ExchangeRates {
+		op.ExchangeRates = append(op.ExchangeRates, rate.Proto())
+	}
+
+	for _, c := range o.StatusHistory {
+		change := &pb.OrderStatusChange{
+			From:   string(c.From),
+			To:     string(c.To),
+			Reason: c.Reason,
+		}
+		change.ChangedAt, _ = c.ChangedAt.MarshalBinary()
+		op.StatusHistory = append(op.StatusHistory, change)
+	}
+
+	return op
 }
@@ -2,46 +2,217 @@ package order
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"github.com/fabian-emmanuel/go-ms/errs"
+	"github.com/fabian-emmanuel/go-ms/money"
 	"github.com/segmentio/ksuid"
 	"time"
 )
 
 type Service interface {
-	CreateOrder(ctx context.Context, accountId string, orderedProducts []OrderedProduct) (*Order, error)
+	CreateOrder(ctx context.Context, accountId, currency string, orderedProducts []OrderedProduct) (*Order, error)
+	PrepareOrder(ctx context.Context, accountId, currency string, orderedProducts []OrderedProduct) (*Order, error)
+	PlaceOrder(ctx context.Context, order *Order) error
+	GetOrder(ctx context.Context, id string) (*Order, error)
 	GetOrdersForAccount(ctx context.Context, accountId string) ([]*Order, error)
+	GetOrdersForAccounts(ctx context.Context, accountIds []string) ([]*Order, error)
+	ListOrders(ctx context.Context, query ListOrdersQuery) (*OrderPage, error)
+	UpdateOrderStatus(ctx context.Context, id string, status OrderStatus, reason string) (*Order, error)
+	CancelOrder(ctx context.Context, id string, reason string) (*Order, error)
 }
 
 type orderService struct {
-	repo Repository
+	repo  Repository
+	rates money.RateProvider
 }
 
-func NewOrderService(repo Repository) Service {
-	return &orderService{repo}
+func NewOrderService(repo Repository, rates money.RateProvider) Service {
+	return &orderService{repo, rates}
 }
 
-func (s *orderService) CreateOrder(ctx context.Context, accountId string, orderedProducts []OrderedProduct) (*Order, error) {
+func (s *orderService) CreateOrder(ctx context.Context, accountId, currency string, orderedProducts []OrderedProduct) (*Order, error) {
+	order, err := s.PrepareOrder(ctx, accountId, currency, orderedProducts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.PlaceOrder(ctx, order); err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// PrepareOrder builds a priced, unsaved order in the checkout currency, falling
+// back to the currency of the first product when none is given. Products priced
+// in another currency are converted and the rates used are kept on the order.
+func (s *orderService) PrepareOrder(ctx context.Context, accountId, currency string, orderedProducts []OrderedProduct) (*Order, error) {
+	if currency == "" && len(orderedProducts) > 0 {
+		currency = orderedProducts[0].Price.Currency
+	}
+	currency, err := money.NormalizeCurrency(currency)
+	if err != nil {
+		return nil, err
+	}
+
 	order := &Order{
 		ID:        ksuid.New().String(),
 		CreatedAt: time.Now().UTC(),
 		AccountId: accountId,
-		Products:  orderedProducts,
+		Status:    StatusPending,
+		Products:  make([]OrderedProduct, len(orderedProducts)),
 	}
+	copy(order.Products, orderedProducts)
 
-	order.TotalAmount = 0.0
+	rates := map[string]money.Rate{}
+	for i, product := range order.Products {
+		if product.Price.Currency == currency {
+			continue
+		}
 
-	for _, orderedProduct := range orderedProducts {
-		order.TotalAmount += orderedProduct.Price * float64(orderedProduct.Quantity)
+		rate, ok := rates[product.Price.Currency]
+		if !ok {
+			rate, err = s.rates.Rate(ctx, product.Price.Currency, currency)
+			if err != nil {
+				return nil, err
+			}
+			rates[product.Price.Currency] = rate
+			order.ExchangeRates = append(order.ExchangeRates, rate)
+		}
+
+		order.Products[i].Price, err = rate.Convert(product.Price)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	err := s.repo.CreateOrder(ctx, *order)
+	total, err := orderTotal(order.Products)
 	if err != nil {
 		return nil, err
 	}
+	order.TotalAmount = total
+
+	order.StatusHistory = []StatusChange{{To: StatusPending, ChangedAt: order.CreatedAt}}
 
 	return order, nil
+}
+
+// PlaceOrder persists a prepared order. Placing an order that already exists is a no-op.
+func (s *orderService) PlaceOrder(ctx context.Context, order *Order) error {
+	_, err := s.repo.GetOrderById(ctx, order.ID)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, ErrOrderNotFound) {
+		return err
+	}
 
+	if err := s.repo.CreateOrder(ctx, *order); err != nil {
+		return err
+	}
+	recordOrderCreated(order)
+	return nil
+}
+
+// orderTotal sums price * quantity over the ordered products. All products must share one currency.
+func orderTotal(orderedProducts []OrderedProduct) (money.Money, error) {
+	if len(orderedProducts) == 0 {
+		return money.Money{}, fmt.Errorf("%w: order has no products", money.ErrInvalidAmount)
+	}
+
+	total := money.Zero(orderedProducts[0].Price.Currency)
+	for _, orderedProduct := range orderedProducts {
+		lineTotal, err := orderedProduct.Price.Multiply(int64(orderedProduct.Quantity))
+		if err != nil {
+			return money.Money{}, err
+		}
+		total, err = total.Add(lineTotal)
+		if err != nil {
+			return money.Money{}, err
+		}
+	}
+	return total, nil
+}
+
+func (s *orderService) GetOrder(ctx context.Context, id string) (*Order, error) {
+	return s.repo.GetOrderById(ctx, id)
 }
 
 func (s *orderService) GetOrdersForAccount(ctx context.Context, accountId string) ([]*Order, error) {
 	return s.repo.GetOrdersForAccount(ctx, accountId)
 }
+
+func (s *orderService) GetOrdersForAccounts(ctx context.Context, accountIds []string) ([]*Order, error) {
+	return s.repo.GetOrdersForAccounts(ctx, accountIds)
+}
+
+func (s *orderService) ListOrders(ctx context.Context, query ListOrdersQuery) (*OrderPage, error) {
+	sort, err := ParseOrderSort(string(query.Sort))
+	if err != nil {
+		return nil, err
+	}
+	query.Sort = sort
+
+	if query.First <= 0 {
+		query.First = defaultPageSize
+	}
+	if query.First > maxPageSize {
+		query.First = maxPageSize
+	}
+
+	for _, status := range query.Filter.Statuses {
+		if _, err := ParseOrderStatus(string(status)); err != nil {
+			return nil, err
+		}
+	}
+
+	f := query.Filter
+	if !f.CreatedAfter.IsZero() && !f.CreatedBefore.IsZero() && !f.CreatedAfter.Before(f.CreatedBefore) {
+		return nil, errs.Invalid(errs.FieldViolation{Field: "filter.createdAfter", Description: "must be before createdBefore"})
+	}
+
+	if f.MinTotal != nil {
+		minTotal, err := money.New(f.MinTotal.Amount, f.MinTotal.Currency)
+		if err != nil {
+			return nil, err
+		}
+		query.Filter.MinTotal = &minTotal
+	}
+
+	return s.repo.ListOrders(ctx, query)
+}
+
+func (s *orderService) UpdateOrderStatus(ctx context.Context, id string, status OrderStatus, reason string) (*Order, error) {
+	if _, err := ParseOrderStatus(string(status)); err != nil {
+		return nil, err
+	}
+
+	order, err := s.repo.GetOrderById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !order.Status.CanTransitionTo(status) {
+		return nil, fmt.Errorf("%w: %s -> %s", ErrInvalidStatusTransition, order.Status, status)
+	}
+
+	change := StatusChange{
+		From:      order.Status,
+		To:        status,
+		Reason:    reason,
+		ChangedAt: time.Now().UTC(),
+	}
+
+	if err := s.repo.UpdateOrderStatus(ctx, id, change); err != nil {
+		return nil, err
+	}
+
+	order.Status = status
+	order.StatusHistory = append(order.StatusHistory, change)
+	return order, nil
+}
+
+func (s *orderService) CancelOrder(ctx context.Context, id string, reason string) (*Order, error) {
+	return s.UpdateOrderStatus(ctx, id, StatusCancelled, reason)
+}
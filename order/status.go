@@ -0,0 +1,52 @@
+package order
+
+import (
+	"fmt"
+	"github.com/fabian-emmanuel/go-ms/errs"
+)
+
+type OrderStatus string
+
+const (
+	StatusPending   OrderStatus = "pending"
+	StatusPaid      OrderStatus = "paid"
+	StatusFulfilled OrderStatus = "fulfilled"
+	StatusShipped   OrderStatus = "shipped"
+	StatusDelivered OrderStatus = "delivered"
+	StatusCancelled OrderStatus = "cancelled"
+	StatusRefunded  OrderStatus = "refunded"
+)
+
+var (
+	ErrUnknownOrderStatus      = errs.InvalidArgument("unknown order status")
+	ErrInvalidStatusTransition = errs.FailedPrecondition("invalid order status transition")
+)
+
+// statusTransitions lists, for every status, the statuses an order may move to next.
+// Cancelled and refunded are terminal.
+var statusTransitions = map[OrderStatus][]OrderStatus{
+	StatusPending:   {StatusPaid, StatusCancelled},
+	StatusPaid:      {StatusFulfilled, StatusCancelled, StatusRefunded},
+	StatusFulfilled: {StatusShipped, StatusRefunded},
+	StatusShipped:   {StatusDelivered},
+	StatusDelivered: {StatusRefunded},
+	StatusCancelled: {},
+	StatusRefunded:  {},
+}
+
+func ParseOrderStatus(s string) (OrderStatus, error) {
+	status := OrderStatus(s)
+	if _, ok := statusTransitions[status]; !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownOrderStatus, s)
+	}
+	return status, nil
+}
+
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range statusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package order
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseOrderStatus(t *testing.T) {
+	for status := range statusTransitions {
+		got, err := ParseOrderStatus(string(status))
+		if err != nil || got != status {
+			t.Errorf("ParseOrderStatus(%q) = %q, %v", status, got, err)
+		}
+	}
+	for _, s := range []string{"", "PENDING", "lost"} {
+		if _, err := ParseOrderStatus(s); !errors.Is(err, ErrUnknownOrderStatus) {
+			t.Errorf("ParseOrderStatus(%q) err = %v, want %v", s, err, ErrUnknownOrderStatus)
+		}
+	}
+}
+
+func TestCanTransitionTo(t *testing.T) {
+	statuses := []OrderStatus{StatusPending, StatusPaid, StatusFulfilled, StatusShipped, StatusDelivered, StatusCancelled, StatusRefunded}
+	allowed := map[OrderStatus][]OrderStatus{
+		StatusPending:   {StatusPaid, StatusCancelled},
+		StatusPaid:      {StatusFulfilled, StatusCancelled, StatusRefunded},
+		StatusFulfilled: {StatusShipped, StatusRefunded},
+		StatusShipped:   {StatusDelivered},
+		StatusDelivered: {StatusRefunded},
+	}
+
+	for _, from := range statuses {
+		for _, to := range statuses {
+			want := false
+			for _, next := range allowed[from] {
+				if next == to {
+					want = true
+				}
+			}
+			if got := from.CanTransitionTo(to); got != want {
+				t.Errorf("%s -> %s = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+}
+
+func TestTerminalStatuses(t *testing.T) {
+	for _, status := range []OrderStatus{StatusCancelled, StatusRefunded} {
+		if next := statusTransitions[status]; len(next) != 0 {
+			t.Errorf("%s can move to %v, want none", status, next)
+		}
+	}
+}
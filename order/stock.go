@@ -0,0 +1,52 @@
+package order
+
+import (
+	"context"
+	"github.com/fabian-emmanuel/go-ms/account"
+	"github.com/fabian-emmanuel/go-ms/auth"
+	"github.com/fabian-emmanuel/go-ms/catalog"
+)
+
+// serviceStock calls the catalog's stock RPCs as the order service's own
+// account, which holds auth.PermManageStock, rather than as whoever placed the
+// order. Without a token source the calls carry no token of their own, which
+// the catalog only accepts over mutual TLS.
+type serviceStock struct {
+	catalog *catalog.Client
+	tokens  *account.TokenSource
+}
+
+func (s serviceStock) withToken(ctx context.Context) (context.Context, error) {
+	if s.tokens == nil {
+		return ctx, nil
+	}
+	token, err := s.tokens.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return auth.WithToken(ctx, token), nil
+}
+
+func (s serviceStock) ReserveStock(ctx context.Context, reservationId string, items []catalog.StockItem) error {
+	ctx, err := s.withToken(ctx)
+	if err != nil {
+		return err
+	}
+	return s.catalog.ReserveStock(ctx, reservationId, items)
+}
+
+func (s serviceStock) ReleaseStock(ctx context.Context, reservationId string, productIds []string) error {
+	ctx, err := s.withToken(ctx)
+	if err != nil {
+		return err
+	}
+	return s.catalog.ReleaseStock(ctx, reservationId, productIds)
+}
+
+func (s serviceStock) CommitStock(ctx context.Context, reservationId string, productIds []string) error {
+	ctx, err := s.withToken(ctx)
+	if err != nil {
+		return err
+	}
+	return s.catalog.CommitStock(ctx, reservationId, productIds)
+}
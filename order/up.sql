@@ -2,12 +2,76 @@ CREATE TABLE IF NOT EXISTS orders (
     id CHAR(30) PRIMARY KEY,
     created_at TIMESTAMP WITH TIME ZONE NOT NULL,
     account_id CHAR(30) NOT NULL,
-    total_amount MONEY NOT NULL
+    total_amount BIGINT NOT NULL,
+    currency CHAR(3) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending'
 );
 
+CREATE INDEX IF NOT EXISTS orders_created_at_idx ON orders (created_at, id);
+CREATE INDEX IF NOT EXISTS orders_total_amount_idx ON orders (currency, total_amount, id);
+CREATE INDEX IF NOT EXISTS orders_account_id_created_at_idx ON orders (account_id, created_at, id);
+
 CREATE TABLE IF NOT EXISTS ordered_products (
     order_id CHAR(30) REFERENCES orders(id) ON DELETE CASCADE,
     product_id CHAR(30) NOT NULL,
     quantity INT NOT NULL,
+    price BIGINT NOT NULL,
+    currency CHAR(3) NOT NULL,
     PRIMARY KEY (order_id, product_id)
-);
\ No newline at end of file
+);
+
+CREATE TABLE IF NOT EXISTS order_exchange_rates (
+    order_id CHAR(30) REFERENCES orders(id) ON DELETE CASCADE,
+    from_currency CHAR(3) NOT NULL,
+    to_currency CHAR(3) NOT NULL,
+    rate NUMERIC NOT NULL,
+    PRIMARY KEY (order_id, from_currency)
+);
+
+CREATE TABLE IF NOT EXISTS order_status_history (
+    id BIGSERIAL PRIMARY KEY,
+    order_id CHAR(30) NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    from_status VARCHAR(16),
+    to_status VARCHAR(16) NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    changed_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS order_status_history_order_id_idx ON order_status_history (order_id, changed_at);
+
+CREATE TABLE IF NOT EXISTS order_sagas (
+    id CHAR(30) PRIMARY KEY,
+    status VARCHAR(16) NOT NULL,
+    step VARCHAR(32) NOT NULL DEFAULT '',
+    payload JSONB NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS order_sagas_unfinished_idx ON order_sagas (updated_at) WHERE status IN ('running', 'compensating');
+
+CREATE TABLE IF NOT EXISTS outbox (
+    id CHAR(27) PRIMARY KEY,
+    event_type VARCHAR(64) NOT NULL,
+    aggregate_id VARCHAR(64) NOT NULL,
+    payload JSONB NOT NULL,
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    published_at TIMESTAMP WITH TIME ZONE,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (occurred_at, id) WHERE published_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    method VARCHAR(128) NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL,
+    request_hash BYTEA NOT NULL,
+    response BYTEA,
+    locked_until TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (method, idempotency_key)
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
@@ -0,0 +1,97 @@
+package order
+
+import (
+	"fmt"
+	"github.com/fabian-emmanuel/go-ms/order/pb"
+	"github.com/fabian-emmanuel/go-ms/validation"
+	"time"
+)
+
+const maxReasonLength = 500
+
+// validators check every OrderService request before it reaches the service.
+var validators = map[string]validation.Validator{
+	pb.OrderService_CreateOrder_FullMethodName: validation.For(func(r *pb.CreateOrderRequest, v *validation.Violations) {
+		v.ID("accountId", r.AccountId)
+		if r.Currency != "" {
+			v.Currency("currency", r.Currency)
+		}
+		if len(r.OrderProducts) == 0 {
+			v.Add("orderProducts", "must not be empty")
+		}
+		for i, p := range r.OrderProducts {
+			v.ID(fmt.Sprintf("orderProducts[%d].productId", i), p.ProductId)
+			if p.Quantity == 0 {
+				v.Add(fmt.Sprintf("orderProducts[%d].quantity", i), "must be greater than zero")
+			}
+		}
+	}),
+	pb.OrderService_GetOrdersForAccount_FullMethodName: validation.For(func(r *pb.GetOrdersForAccountRequest, v *validation.Violations) {
+		v.ID("accountId", r.AccountId)
+	}),
+	pb.OrderService_GetOrdersForAccounts_FullMethodName: validation.For(func(r *pb.GetOrdersForAccountsRequest, v *validation.Violations) {
+		v.IDs("accountIds", r.AccountIds)
+		if len(r.AccountIds) > maxPageSize {
+			v.Add("accountIds", fmt.Sprintf("must have at most %d entries", maxPageSize))
+		}
+	}),
+	pb.OrderService_GetOrder_FullMethodName: validation.For(func(r *pb.GetOrderRequest, v *validation.Violations) {
+		v.ID("id", r.Id)
+	}),
+	pb.OrderService_ListOrders_FullMethodName: validation.For(func(r *pb.ListOrdersRequest, v *validation.Violations) {
+		if _, err := ParseOrderSort(r.Sort); err != nil {
+			v.Add("sort", "must be a known sort order")
+		}
+
+		f := r.Filter
+		if f == nil {
+			return
+		}
+		v.OptionalID("filter.accountId", f.AccountId)
+		for i, status := range f.Statuses {
+			if _, err := ParseOrderStatus(status); err != nil {
+				v.Add(fmt.Sprintf("filter.statuses[%d]", i), "must be a known order status")
+			}
+		}
+		createdAfter := checkTime(v, "filter.createdAfter", f.CreatedAfter)
+		createdBefore := checkTime(v, "filter.createdBefore", f.CreatedBefore)
+		if !createdAfter.IsZero() && !createdBefore.IsZero() && !createdAfter.Before(createdBefore) {
+			v.Add("filter.createdAfter", "must be before createdBefore")
+		}
+		if f.MinTotal != nil {
+			v.Price("filter.minTotal", f.MinTotal)
+		}
+	}),
+	pb.OrderService_UpdateOrderStatus_FullMethodName: validation.For(func(r *pb.UpdateOrderStatusRequest, v *validation.Violations) {
+		v.ID("orderId", r.OrderId)
+		if _, err := ParseOrderStatus(r.Status); err != nil {
+			v.Add("status", "must be a known order status")
+		}
+		v.MaxLength("reason", r.Reason, maxReasonLength)
+	}),
+	pb.OrderService_CancelOrder_FullMethodName: validation.For(func(r *pb.CancelOrderRequest, v *validation.Violations) {
+		v.ID("orderId", r.OrderId)
+		v.MaxLength("reason", r.Reason, maxReasonLength)
+	}),
+	pb.OrderService_WatchOrders_FullMethodName: validation.For(func(r *pb.WatchOrdersRequest, v *validation.Violations) {
+		switch {
+		case r.OrderId != "" && r.AccountId != "":
+			v.Add("orderId", "must not be set together with accountId")
+		case r.OrderId != "":
+			v.ID("orderId", r.OrderId)
+		default:
+			v.ID("accountId", r.AccountId)
+		}
+	}),
+}
+
+// checkTime decodes an optional binary-encoded time.
+func checkTime(v *validation.Violations, field string, b []byte) time.Time {
+	var t time.Time
+	if len(b) > 0 {
+		if err := t.UnmarshalBinary(b); err != nil {
+			v.Add(field, "must be a valid time")
+		}
+	}
+	return t
+}
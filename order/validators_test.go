@@ -0,0 +1,100 @@
+package order
+
+import (
+	"github.com/fabian-emmanuel/go-ms/errs"
+	moneypb "github.com/fabian-emmanuel/go-ms/money/pb"
+	"github.com/fabian-emmanuel/go-ms/order/pb"
+	"github.com/fabian-emmanuel/go-ms/validation"
+	"github.com/segmentio/ksuid"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidators(t *testing.T) {
+	id := ksuid.New().String()
+	now := time.Now()
+	before, _ := now.Add(-time.Hour).MarshalBinary()
+	after, _ := now.MarshalBinary()
+
+	tests := []struct {
+		name   string
+		method string
+		req    any
+		// fields are the fields reported invalid, in order
+		fields []string
+	}{
+		{"create order", pb.OrderService_CreateOrder_FullMethodName, &pb.CreateOrderRequest{
+			AccountId:     id,
+			Currency:      "eur",
+			OrderProducts: []*pb.OrderProduct{{ProductId: id, Quantity: 1}},
+		}, nil},
+		{"create order without products", pb.OrderService_CreateOrder_FullMethodName, &pb.CreateOrderRequest{
+			AccountId: id,
+		}, []string{"orderProducts"}},
+		{"create order with bad products", pb.OrderService_CreateOrder_FullMethodName, &pb.CreateOrderRequest{
+			AccountId: "42",
+			Currency:  "euros",
+			OrderProducts: []*pb.OrderProduct{
+				{ProductId: id, Quantity: 1},
+				{ProductId: "", Quantity: 0},
+			},
+		}, []string{"accountId", "currency", "orderProducts[1].productId", "orderProducts[1].quantity"}},
+		{"orders for too many accounts", pb.OrderService_GetOrdersForAccounts_FullMethodName, &pb.GetOrdersForAccountsRequest{
+			AccountIds: slices.Repeat([]string{id}, maxPageSize+1),
+		}, []string{"accountIds"}},
+		{"list orders", pb.OrderService_ListOrders_FullMethodName, &pb.ListOrdersRequest{
+			Filter: &pb.OrderFilter{
+				AccountId:     id,
+				Statuses:      []string{"pending", "shipped"},
+				CreatedAfter:  before,
+				CreatedBefore: after,
+				MinTotal:      &moneypb.Money{Amount: 100, Currency: "USD"},
+			},
+		}, nil},
+		{"list orders with a bad filter", pb.OrderService_ListOrders_FullMethodName, &pb.ListOrdersRequest{
+			Sort: "cheapest",
+			Filter: &pb.OrderFilter{
+				AccountId:     "42",
+				Statuses:      []string{"pending", "lost"},
+				CreatedAfter:  after,
+				CreatedBefore: before,
+				MinTotal:      &moneypb.Money{Amount: -1, Currency: "USD"},
+			},
+		}, []string{"sort", "filter.accountId", "filter.statuses[1]", "filter.createdAfter", "filter.minTotal.amount"}},
+		{"list orders with a malformed time", pb.OrderService_ListOrders_FullMethodName, &pb.ListOrdersRequest{
+			Filter: &pb.OrderFilter{CreatedAfter: []byte("yesterday")},
+		}, []string{"filter.createdAfter"}},
+		{"update order status", pb.OrderService_UpdateOrderStatus_FullMethodName, &pb.UpdateOrderStatusRequest{
+			OrderId: id,
+			Status:  "shipped",
+		}, nil},
+		{"update to an unknown status", pb.OrderService_UpdateOrderStatus_FullMethodName, &pb.UpdateOrderStatusRequest{
+			OrderId: id,
+			Status:  "lost",
+			Reason:  strings.Repeat("a", maxReasonLength+1),
+		}, []string{"status", "reason"}},
+		{"watch an order", pb.OrderService_WatchOrders_FullMethodName, &pb.WatchOrdersRequest{OrderId: id}, nil},
+		{"watch an account", pb.OrderService_WatchOrders_FullMethodName, &pb.WatchOrdersRequest{AccountId: id}, nil},
+		{"watch nothing", pb.OrderService_WatchOrders_FullMethodName, &pb.WatchOrdersRequest{}, []string{"accountId"}},
+		{"watch both", pb.OrderService_WatchOrders_FullMethodName, &pb.WatchOrdersRequest{OrderId: id, AccountId: id}, []string{"orderId"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v validation.Violations
+			validators[tt.method](tt.req, &v)
+
+			var fields []string
+			if e, ok := errs.As(v.Err()); ok {
+				for _, violation := range e.Violations {
+					fields = append(fields, violation.Field)
+				}
+			}
+			if !reflect.DeepEqual(fields, tt.fields) {
+				t.Errorf("invalid fields = %v, want %v", fields, tt.fields)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package order
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/fabian-emmanuel/go-ms/errs"
+	"github.com/lib/pq"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// orderChangesChannel is the Postgres channel the repository notifies on
+	// whenever an order is created or changes status.
+	orderChangesChannel = "order_changes"
+	// maxPendingChanges is how many changed orders a watch may fall behind by
+	// before it is ended.
+	maxPendingChanges = 100
+)
+
+var (
+	ErrWatchTooSlow     = errs.ResourceExhausted("too many order changes pending, watch again")
+	ErrWatchInterrupted = errs.Unavailable("order changes may have been missed, watch again")
+)
+
+// orderChange is the payload of a notification on orderChangesChannel.
+type orderChange struct {
+	OrderId   string `json:"order_id"`
+	AccountId string `json:"account_id"`
+}
+
+// Watcher listens for order changes committed by any replica of the order
+// service and hands them out to the watches interested in them.
+type Watcher struct {
+	listener *pq.Listener
+
+	mu      sync.Mutex
+	watches map[*Watch]struct{}
+	closed  bool
+}
+
+func NewWatcher(url string) (*Watcher, error) {
+	listener := pq.NewListener(url, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			slog.Error("Order change listener error", "event", event, "err", err)
+		}
+	})
+	if err := listener.Listen(orderChangesChannel); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to listen for order changes: %w", err)
+	}
+	return &Watcher{listener: listener, watches: map[*Watch]struct{}{}}, nil
+}
+
+// Run hands out changes until ctx is done, then ends every watch so streams
+// serving them return and the server can stop.
+func (w *Watcher) Run(ctx context.Context) {
+	defer w.listener.Close()
+	for {
+		select {
+		case <-ctx.Done():
+			w.close(errs.Unavailable("order service is shutting down"))
+			return
+		case n := <-w.listener.Notify:
+			// A nil notification follows a reconnect, after which anything
+			// committed while the connection was down is lost
+			if n == nil {
+				w.interrupt()
+				continue
+			}
+			var change orderChange
+			if err := json.Unmarshal([]byte(n.Extra), &change); err != nil {
+				slog.Error("Error decoding order change", "payload", n.Extra, "err", err)
+				continue
+			}
+			w.dispatch(change)
+		case <-time.After(time.Minute):
+			// Catch a connection that died without the listener noticing
+			go w.listener.Ping()
+		}
+	}
+}
+
+// Watch follows a single order when orderId is set, or else every order of
+// accountId. It must be closed when no longer needed.
+func (w *Watcher) Watch(orderId, accountId string) *Watch {
+	watch := &Watch{
+		orderId:   orderId,
+		accountId: accountId,
+		pending:   map[string]struct{}{},
+		signal:    make(chan struct{}, 1),
+		watcher:   w,
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		watch.fail(errs.Unavailable("order service is shutting down"))
+	} else {
+		w.watches[watch] = struct{}{}
+	}
+	return watch
+}
+
+func (w *Watcher) dispatch(change orderChange) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for watch := range w.watches {
+		if watch.matches(change) && !watch.add(change.OrderId) {
+			delete(w.watches, watch)
+		}
+	}
+}
+
+func (w *Watcher) interrupt() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for watch := range w.watches {
+		watch.fail(ErrWatchInterrupted)
+		delete(w.watches, watch)
+	}
+}
+
+func (w *Watcher) close(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	for watch := range w.watches {
+		watch.fail(err)
+		delete(w.watches, watch)
+	}
+}
+
+func (w *Watcher) remove(watch *Watch) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.watches, watch)
+}
+
+// Watch collects the IDs of changed orders until they are taken with Next.
+// An order that changes several times before then is only reported once, so
+// a slow reader only ever loads its latest state.
+type Watch struct {
+	orderId   string
+	accountId string
+	watcher   *Watcher
+	signal    chan struct{}
+
+	mu      sync.Mutex
+	pending map[string]struct{}
+	err     error
+}
+
+func (w *Watch) matches(change orderChange) bool {
+	if w.orderId != "" {
+		return change.OrderId == w.orderId
+	}
+	return change.AccountId == w.accountId
+}
+
+// add records a changed order and reports whether the watch is still alive.
+func (w *Watch) add(orderId string) bool {
+	w.mu.Lock()
+	if w.err != nil {
+		w.mu.Unlock()
+		return false
+	}
+	w.pending[orderId] = struct{}{}
+	tooSlow := len(w.pending) > maxPendingChanges
+	w.mu.Unlock()
+
+	if tooSlow {
+		w.fail(ErrWatchTooSlow)
+		return false
+	}
+	w.wake()
+	return true
+}
+
+func (w *Watch) fail(err error) {
+	w.mu.Lock()
+	if w.err == nil {
+		w.err = err
+	}
+	w.mu.Unlock()
+	w.wake()
+}
+
+func (w *Watch) wake() {
+	select {
+	case w.signal <- struct{}{}:
+	default:
+	}
+}
+
+// Next waits for orders to change and returns their IDs. It fails once the
+// watch has fallen too far behind or changes may have been missed.
+func (w *Watch) Next(ctx context.Context) ([]string, error) {
+	for {
+		w.mu.Lock()
+		if w.err != nil {
+			err := w.err
+			w.mu.Unlock()
+			return nil, err
+		}
+		if len(w.pending) > 0 {
+			ids := make([]string, 0, len(w.pending))
+			for id := range w.pending {
+				ids = append(ids, id)
+			}
+			clear(w.pending)
+			w.mu.Unlock()
+			return ids, nil
+		}
+		w.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-w.signal:
+		}
+	}
+}
+
+func (w *Watch) Close() {
+	w.watcher.remove(w)
+}
@@ -0,0 +1,116 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestWatch(t *testing.T) {
+	tooMany := make([]orderChange, maxPendingChanges+1)
+	for i := range tooMany {
+		tooMany[i] = orderChange{OrderId: fmt.Sprintf("order%d", i), AccountId: "account1"}
+	}
+
+	tests := []struct {
+		name      string
+		orderId   string
+		accountId string
+		changes   []orderChange
+		want      []string
+		err       error
+	}{
+		{"single order", "order1", "", []orderChange{
+			{OrderId: "order1", AccountId: "account1"},
+			{OrderId: "order2", AccountId: "account1"},
+		}, []string{"order1"}, nil},
+		{"account orders", "", "account1", []orderChange{
+			{OrderId: "order1", AccountId: "account1"},
+			{OrderId: "order2", AccountId: "account2"},
+			{OrderId: "order3", AccountId: "account1"},
+		}, []string{"order1", "order3"}, nil},
+		{"repeated changes reported once", "", "account1", []orderChange{
+			{OrderId: "order1", AccountId: "account1"},
+			{OrderId: "order1", AccountId: "account1"},
+		}, []string{"order1"}, nil},
+		{"too many pending changes", "", "account1", tooMany, nil, ErrWatchTooSlow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &Watcher{watches: map[*Watch]struct{}{}}
+			watch := w.Watch(tt.orderId, tt.accountId)
+			defer watch.Close()
+
+			for _, change := range tt.changes {
+				w.dispatch(change)
+			}
+
+			ids, err := watch.Next(context.Background())
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			slices.Sort(ids)
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("ids = %v, want %v", ids, tt.want)
+			}
+			if tt.err != nil && len(w.watches) != 0 {
+				t.Error("failed watch still receives changes")
+			}
+		})
+	}
+}
+
+func TestWatchEnds(t *testing.T) {
+	errShutdown := errors.New("shutting down")
+
+	tests := []struct {
+		name string
+		end  func(w *Watcher)
+		err  error
+	}{
+		{"interrupted", func(w *Watcher) { w.interrupt() }, ErrWatchInterrupted},
+		{"closed", func(w *Watcher) { w.close(errShutdown) }, errShutdown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &Watcher{watches: map[*Watch]struct{}{}}
+			watch := w.Watch("", "account1")
+			defer watch.Close()
+
+			// Changes already pending are dropped, as later ones may be missing
+			w.dispatch(orderChange{OrderId: "order1", AccountId: "account1"})
+			tt.end(w)
+
+			if _, err := watch.Next(context.Background()); !errors.Is(err, tt.err) {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+			if len(w.watches) != 0 {
+				t.Error("ended watch still receives changes")
+			}
+		})
+	}
+}
+
+func TestWatchAfterClose(t *testing.T) {
+	w := &Watcher{watches: map[*Watch]struct{}{}}
+	w.close(ErrWatchInterrupted)
+
+	watch := w.Watch("order1", "")
+	if _, err := watch.Next(context.Background()); err == nil {
+		t.Error("watch on a closed watcher did not fail")
+	}
+}
+
+func TestWatchNextHonorsContext(t *testing.T) {
+	w := &Watcher{watches: map[*Watch]struct{}{}}
+	watch := w.Watch("order1", "")
+	defer watch.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := watch.Next(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,175 @@
+// Command register extracts the GraphQL operations from client code and
+// registers them in a persisted query store, so a gateway running with
+// STRICT_OPERATIONS accepts them. It reads .graphql and .gql files whole and
+// gql`...` or graphql`...` literals out of JavaScript and TypeScript sources.
+// Clients must send each operation exactly as it is written there.
+//
+//	register -store postgres://... -schema graphql/schema.graphql ./app/src
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/fabian-emmanuel/go-ms/persisted"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/parser"
+	"github.com/vektah/gqlparser/v2/validator"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var literal = regexp.MustCompile("(?s)\\b(?:gql|graphql)\\s*(?:\\(\\s*)?`(.*?)`")
+
+type operation struct {
+	source string
+	names  []string
+	query  string
+}
+
+func main() {
+	store := flag.String("store", os.Getenv("PERSISTED_QUERIES"), "postgres:// URL or file path of the store; defaults to $PERSISTED_QUERIES")
+	schemaPath := flag.String("schema", "graphql/schema.graphql", "schema to validate operations against; empty to skip validation")
+	dryRun := flag.Bool("dry-run", false, "only list the operations that would be registered")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		log.Fatal("usage: register [flags] path...")
+	}
+	if *store == "" && !*dryRun {
+		log.Fatal("no store given, set -store or PERSISTED_QUERIES")
+	}
+
+	var schema *ast.Schema
+	if *schemaPath != "" {
+		body, err := os.ReadFile(*schemaPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if schema, err = gqlparser.LoadSchema(&ast.Source{Name: *schemaPath, Input: string(body)}); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var operations []operation
+	failed := false
+	for _, root := range flag.Args() {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if d.Name() == "node_modules" || (strings.HasPrefix(d.Name(), ".") && path != root) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			documents, err := extract(path)
+			if err != nil {
+				return err
+			}
+			for i, document := range documents {
+				source := path
+				if len(documents) > 1 {
+					source = fmt.Sprintf("%s#%d", path, i+1)
+				}
+				op, err := check(schema, source, document)
+				if err != nil {
+					log.Printf("%s: %v", source, err)
+					failed = true
+					continue
+				}
+				if op != nil {
+					operations = append(operations, *op)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Register nothing unless every operation is valid
+	if failed {
+		log.Fatal("some operations are invalid, nothing was registered")
+	}
+	if *dryRun {
+		for _, op := range operations {
+			fmt.Printf("%s  %s  %s\n", persisted.Hash(op.query), op.source, strings.Join(op.names, ","))
+		}
+		return
+	}
+
+	s, err := persisted.Open(*store)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	for _, op := range operations {
+		hash, err := s.Put(ctx, op.query)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%s  %s  %s\n", hash, op.source, strings.Join(op.names, ","))
+	}
+}
+
+// extract returns the GraphQL documents in the file at path.
+func extract(path string) ([]string, error) {
+	switch filepath.Ext(path) {
+	case ".graphql", ".gql":
+		body, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return []string{string(body)}, nil
+	case ".js", ".jsx", ".ts", ".tsx", ".mjs", ".cjs":
+		body, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var documents []string
+		for _, m := range literal.FindAllStringSubmatch(string(body), -1) {
+			documents = append(documents, m[1])
+		}
+		return documents, nil
+	default:
+		return nil, nil
+	}
+}
+
+// check parses and, given a schema, validates a document. Documents holding
+// only fragments are skipped, as clients cannot send them on their own.
+func check(schema *ast.Schema, source, document string) (*operation, error) {
+	if strings.Contains(document, "${") {
+		return nil, fmt.Errorf("interpolated documents cannot be registered, inline their fragments")
+	}
+
+	doc, err := parser.ParseQuery(&ast.Source{Name: source, Input: document})
+	if err != nil {
+		return nil, err
+	}
+	if len(doc.Operations) == 0 {
+		return nil, nil
+	}
+	if schema != nil {
+		if errs := validator.Validate(schema, doc); len(errs) > 0 {
+			return nil, errs
+		}
+	}
+
+	op := &operation{source: source, query: document}
+	for _, o := range doc.Operations {
+		op.names = append(op.names, o.Name)
+	}
+	return op, nil
+}
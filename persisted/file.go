@@ -0,0 +1,97 @@
+package persisted
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// fileStore keeps operations in a JSON object mapping hashes to queries. The
+// whole file is read when the store is opened, so operations registered by
+// another process show up after a restart.
+type fileStore struct {
+	path string
+
+	mu      sync.RWMutex
+	queries map[string]string
+}
+
+// NewFileStore opens the store in the file at path, which need not exist yet.
+func NewFileStore(path string) (Store, error) {
+	s := &fileStore{path: path, queries: map[string]string{}}
+
+	body, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read persisted queries: %w", err)
+	}
+	if err := json.Unmarshal(body, &s.queries); err != nil {
+		return nil, fmt.Errorf("failed to decode persisted queries: %w", err)
+	}
+	for hash, query := range s.queries {
+		if Hash(query) != hash {
+			return nil, fmt.Errorf("persisted query %s does not match its hash", hash)
+		}
+	}
+	return s, nil
+}
+
+func (s *fileStore) Get(_ context.Context, hash string) (string, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	query, ok := s.queries[hash]
+	return query, ok, nil
+}
+
+func (s *fileStore) Put(_ context.Context, query string) (string, error) {
+	hash := Hash(query)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.queries[hash]; ok {
+		return hash, nil
+	}
+	s.queries[hash] = query
+	if err := s.write(); err != nil {
+		delete(s.queries, hash)
+		return "", err
+	}
+	return hash, nil
+}
+
+// write replaces the file through a rename, so readers never see half of it.
+func (s *fileStore) write() error {
+	body, err := json.MarshalIndent(s.queries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode persisted queries: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write persisted queries: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(body, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write persisted queries: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write persisted queries: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write persisted queries: %w", err)
+	}
+	return nil
+}
+
+func (s *fileStore) Close() error {
+	return nil
+}
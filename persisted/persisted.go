@@ -0,0 +1,35 @@
+// Package persisted stores GraphQL operations under the SHA-256 hash of their
+// text, as sent by clients using persisted queries.
+package persisted
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Store holds registered operations by hash.
+type Store interface {
+	// Get returns the operation registered under hash, if any.
+	Get(ctx context.Context, hash string) (query string, ok bool, err error)
+	// Put registers query under its hash. Registering it again changes nothing.
+	Put(ctx context.Context, query string) (hash string, err error)
+	Close() error
+}
+
+// Hash is the key a query is stored under: its hex encoded SHA-256, matching
+// the sha256Hash clients send in the persistedQuery extension.
+func Hash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+// Open opens the store at url: a postgres:// URL, or otherwise the path of a
+// JSON file.
+func Open(url string) (Store, error) {
+	if strings.HasPrefix(url, "postgres://") || strings.HasPrefix(url, "postgresql://") {
+		return NewPostgresStore(url)
+	}
+	return NewFileStore(strings.TrimPrefix(url, "file://"))
+}
@@ -0,0 +1,59 @@
+package persisted
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/fabian-emmanuel/go-ms/tracing"
+	_ "github.com/lib/pq"
+)
+
+const createTable = `CREATE TABLE IF NOT EXISTS persisted_queries (
+	hash CHAR(64) PRIMARY KEY,
+	query TEXT NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+)`
+
+type postgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore opens the store in the database at url, creating its table
+// when missing.
+func NewPostgresStore(url string) (Store, error) {
+	db, err := tracing.OpenPostgres(url)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(createTable); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create persisted_queries: %w", err)
+	}
+	return &postgresStore{db}, nil
+}
+
+func (s *postgresStore) Get(ctx context.Context, hash string) (string, bool, error) {
+	var query string
+	err := s.db.QueryRowContext(ctx, "SELECT query FROM persisted_queries WHERE hash = $1", hash).Scan(&query)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get persisted query: %w", err)
+	}
+	return query, true, nil
+}
+
+func (s *postgresStore) Put(ctx context.Context, query string) (string, error) {
+	hash := Hash(query)
+	_, err := s.db.ExecContext(ctx, "INSERT INTO persisted_queries (hash, query) VALUES ($1, $2) ON CONFLICT (hash) DO NOTHING", hash, query)
+	if err != nil {
+		return "", fmt.Errorf("failed to put persisted query: %w", err)
+	}
+	return hash, nil
+}
+
+func (s *postgresStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,148 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/XSAM/otelsql"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc/filters"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Config selects where spans go. It is meant to be a field named Tracing in a
+// service's envconfig Config, which reads it from TRACING_EXPORTER,
+// TRACING_FILE and TRACING_SAMPLE_RATIO.
+//
+// Exporter is "otlp" (configured through the standard OTEL_EXPORTER_OTLP_*
+// variables), "stdout", "file" (written to File), or empty to record spans
+// without exporting them, which still gives every request a trace ID.
+type Config struct {
+	Exporter    string  `envconfig:"EXPORTER"`
+	File        string  `envconfig:"FILE"`
+	SampleRatio float64 `envconfig:"SAMPLE_RATIO" default:"1"`
+}
+
+// flushTimeout bounds how long exiting waits on a slow or unreachable collector.
+const flushTimeout = 5 * time.Second
+
+// Setup installs the global tracer provider for service and W3C trace-context
+// and baggage propagation. The returned function flushes buffered spans and
+// must be called before the process exits.
+func Setup(ctx context.Context, service string, c Config) (func(), error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(service),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(c.SampleRatio))),
+	}
+
+	var closeFile func() error
+	switch strings.ToLower(c.Exporter) {
+	case "", "none":
+	case "otlp":
+		exporter, err := otlptracegrpc.New(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	case "stdout":
+		exporter, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, sdktrace.WithSyncer(exporter))
+	case "file":
+		if c.File == "" {
+			return nil, fmt.Errorf("TRACING_FILE is required for the file exporter")
+		}
+		f, err := os.OpenFile(c.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+		closeFile = f.Close
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", c.Exporter)
+	}
+
+	provider := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(provider)
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+		defer cancel()
+		if err := provider.Shutdown(ctx); err != nil {
+			slog.Error("Error flushing traces", "err", err)
+		}
+		if closeFile != nil {
+			closeFile()
+		}
+	}, nil
+}
+
+// Tracer returns a tracer from the global provider.
+func Tracer(name string) trace.Tracer {
+	return otel.Tracer(name)
+}
+
+// ServerOption traces every RPC a gRPC server handles, except health checks.
+func ServerOption() grpc.ServerOption {
+	return grpc.StatsHandler(otelgrpc.NewServerHandler(
+		otelgrpc.WithFilter(filters.Not(filters.HealthCheck())),
+	))
+}
+
+// DialOption traces every RPC a gRPC client makes and propagates the trace
+// context to the server, except for health checks.
+func DialOption() grpc.DialOption {
+	return grpc.WithStatsHandler(otelgrpc.NewClientHandler(
+		otelgrpc.WithFilter(filters.Not(filters.HealthCheck())),
+	))
+}
+
+// OpenPostgres opens a database whose queries are traced.
+func OpenPostgres(url string) (*sql.DB, error) {
+	return otelsql.Open("postgres", url,
+		otelsql.WithAttributes(semconv.DBSystemPostgreSQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{OmitConnResetSession: true, OmitRows: true}),
+	)
+}
+
+// HTTPMiddleware continues a trace started by the caller, if its request
+// carries a traceparent header.
+func HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
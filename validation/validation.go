@@ -0,0 +1,154 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+	"github.com/fabian-emmanuel/go-ms/errs"
+	"github.com/fabian-emmanuel/go-ms/money"
+	moneypb "github.com/fabian-emmanuel/go-ms/money/pb"
+	"github.com/segmentio/ksuid"
+	"google.golang.org/grpc"
+	"strings"
+	"unicode/utf8"
+)
+
+// Validator checks one request, recording a violation for every bad field.
+type Validator func(req any, v *Violations)
+
+// For adapts a check written against a concrete request type.
+func For[T any](check func(req T, v *Violations)) Validator {
+	return func(req any, v *Violations) {
+		if r, ok := req.(T); ok {
+			check(r, v)
+		}
+	}
+}
+
+// UnaryServerInterceptor rejects requests that fail the validator registered
+// for their method with InvalidArgument, listing every violation at once.
+// Methods without a validator are passed through.
+func UnaryServerInterceptor(validators map[string]Validator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if validate, ok := validators[info.FullMethod]; ok {
+			var v Violations
+			validate(req, &v)
+			if err := v.Err(); err != nil {
+				return nil, err
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is UnaryServerInterceptor for streaming RPCs,
+// checking every message the client sends.
+func StreamServerInterceptor(validators map[string]Validator) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if validate, ok := validators[info.FullMethod]; ok {
+			ss = &serverStream{ServerStream: ss, validate: validate}
+		}
+		return handler(srv, ss)
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	validate Validator
+}
+
+func (s *serverStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	var v Violations
+	s.validate(m, &v)
+	return v.Err()
+}
+
+// Violations collects the problems found in a request. Field names follow the
+// protobuf field names, with list indexes, e.g. items[2].quantity.
+type Violations struct {
+	list []errs.FieldViolation
+}
+
+func (v *Violations) Add(field, description string) {
+	v.list = append(v.list, errs.FieldViolation{Field: field, Description: description})
+}
+
+// Err returns an InvalidArgument error listing every violation, or nil.
+func (v *Violations) Err() error {
+	if len(v.list) == 0 {
+		return nil
+	}
+	return errs.Invalid(v.list...)
+}
+
+func (v *Violations) Required(field, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.Add(field, "is required")
+	}
+}
+
+// Length checks the number of characters in value, after trimming spaces.
+func (v *Violations) Length(field, value string, min, max int) {
+	n := utf8.RuneCountInString(strings.TrimSpace(value))
+	switch {
+	case n == 0 && min > 0:
+		v.Add(field, "is required")
+	case n < min:
+		v.Add(field, fmt.Sprintf("must be at least %d characters", min))
+	case n > max:
+		v.Add(field, fmt.Sprintf("must be at most %d characters", max))
+	}
+}
+
+func (v *Violations) MaxLength(field, value string, max int) {
+	v.Length(field, value, 0, max)
+}
+
+// ID checks that value is a KSUID, the format of every entity ID.
+func (v *Violations) ID(field, value string) {
+	if value == "" {
+		v.Add(field, "is required")
+		return
+	}
+	if _, err := ksuid.Parse(value); err != nil {
+		v.Add(field, "must be a valid ID")
+	}
+}
+
+// OptionalID is ID for fields that may be left empty.
+func (v *Violations) OptionalID(field, value string) {
+	if value != "" {
+		v.ID(field, value)
+	}
+}
+
+// IDs checks every element of values with ID, and that there is at least one.
+func (v *Violations) IDs(field string, values []string) {
+	if len(values) == 0 {
+		v.Add(field, "must not be empty")
+		return
+	}
+	for i, value := range values {
+		v.ID(fmt.Sprintf("%s[%d]", field, i), value)
+	}
+}
+
+func (v *Violations) Currency(field, value string) {
+	if _, err := money.NormalizeCurrency(value); err != nil {
+		v.Add(field, "must be an ISO 4217 currency code")
+	}
+}
+
+// Price checks that m is present, in a known currency and not negative.
+func (v *Violations) Price(field string, m *moneypb.Money) {
+	if m == nil {
+		v.Add(field, "is required")
+		return
+	}
+	v.Currency(field+".currency", m.Currency)
+	if m.Amount < 0 {
+		v.Add(field+".amount", "must not be negative")
+	}
+}
@@ -0,0 +1,105 @@
+package validation
+
+import (
+	"context"
+	"github.com/fabian-emmanuel/go-ms/errs"
+	moneypb "github.com/fabian-emmanuel/go-ms/money/pb"
+	"github.com/segmentio/ksuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestViolations(t *testing.T) {
+	id := ksuid.New().String()
+
+	tests := []struct {
+		name  string
+		check func(v *Violations)
+		want  []errs.FieldViolation
+	}{
+		{"required", func(v *Violations) { v.Required("name", "Ada") }, nil},
+		{"required blank", func(v *Violations) { v.Required("name", "  ") }, []errs.FieldViolation{{Field: "name", Description: "is required"}}},
+		{"length", func(v *Violations) { v.Length("name", "Ada", 2, 3) }, nil},
+		{"length trims spaces", func(v *Violations) { v.Length("name", "  Ada  ", 2, 3) }, nil},
+		{"length counts characters", func(v *Violations) { v.Length("name", "Zoë", 3, 3) }, nil},
+		{"length empty", func(v *Violations) { v.Length("name", "", 2, 3) }, []errs.FieldViolation{{Field: "name", Description: "is required"}}},
+		{"length too short", func(v *Violations) { v.Length("name", "A", 2, 3) }, []errs.FieldViolation{{Field: "name", Description: "must be at least 2 characters"}}},
+		{"length too long", func(v *Violations) { v.Length("name", "Adam", 2, 3) }, []errs.FieldViolation{{Field: "name", Description: "must be at most 3 characters"}}},
+		{"max length empty", func(v *Violations) { v.MaxLength("reason", "", 3) }, nil},
+		{"max length too long", func(v *Violations) { v.MaxLength("reason", strings.Repeat("a", 4), 3) }, []errs.FieldViolation{{Field: "reason", Description: "must be at most 3 characters"}}},
+		{"id", func(v *Violations) { v.ID("id", id) }, nil},
+		{"id empty", func(v *Violations) { v.ID("id", "") }, []errs.FieldViolation{{Field: "id", Description: "is required"}}},
+		{"id malformed", func(v *Violations) { v.ID("id", "42") }, []errs.FieldViolation{{Field: "id", Description: "must be a valid ID"}}},
+		{"optional id empty", func(v *Violations) { v.OptionalID("id", "") }, nil},
+		{"optional id malformed", func(v *Violations) { v.OptionalID("id", "42") }, []errs.FieldViolation{{Field: "id", Description: "must be a valid ID"}}},
+		{"ids", func(v *Violations) { v.IDs("ids", []string{id, id}) }, nil},
+		{"ids empty", func(v *Violations) { v.IDs("ids", nil) }, []errs.FieldViolation{{Field: "ids", Description: "must not be empty"}}},
+		{"ids indexes", func(v *Violations) { v.IDs("ids", []string{id, "42"}) }, []errs.FieldViolation{{Field: "ids[1]", Description: "must be a valid ID"}}},
+		{"currency", func(v *Violations) { v.Currency("currency", "usd") }, nil},
+		{"currency malformed", func(v *Violations) { v.Currency("currency", "US$") }, []errs.FieldViolation{{Field: "currency", Description: "must be an ISO 4217 currency code"}}},
+		{"price", func(v *Violations) { v.Price("price", &moneypb.Money{Amount: 0, Currency: "EUR"}) }, nil},
+		{"price missing", func(v *Violations) { v.Price("price", nil) }, []errs.FieldViolation{{Field: "price", Description: "is required"}}},
+		{"price every problem", func(v *Violations) { v.Price("price", &moneypb.Money{Amount: -1, Currency: ""}) }, []errs.FieldViolation{
+			{Field: "price.currency", Description: "must be an ISO 4217 currency code"},
+			{Field: "price.amount", Description: "must not be negative"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v Violations
+			tt.check(&v)
+
+			err := v.Err()
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("err = %v, want nil", err)
+				}
+				return
+			}
+			e, ok := errs.As(err)
+			if !ok {
+				t.Fatalf("err = %v, want a domain error", err)
+			}
+			if e.Code != codes.InvalidArgument || !reflect.DeepEqual(e.Violations, tt.want) {
+				t.Errorf("err = %s %v, want %s %v", e.Code, e.Violations, codes.InvalidArgument, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	interceptor := UnaryServerInterceptor(map[string]Validator{
+		"/test/Checked": For(func(req *moneypb.Money, v *Violations) {
+			v.Currency("currency", req.Currency)
+		}),
+	})
+	handler := func(context.Context, any) (any, error) { return "handled", nil }
+
+	tests := []struct {
+		name    string
+		method  string
+		req     *moneypb.Money
+		invalid bool
+	}{
+		{"valid", "/test/Checked", &moneypb.Money{Currency: "USD"}, false},
+		{"invalid", "/test/Checked", &moneypb.Money{Currency: "dollars"}, true},
+		{"unchecked method", "/test/Unchecked", &moneypb.Money{Currency: "dollars"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := interceptor(context.Background(), tt.req, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+			if tt.invalid {
+				if e, ok := errs.As(err); !ok || e.Code != codes.InvalidArgument {
+					t.Errorf("err = %v, want InvalidArgument", err)
+				}
+				return
+			}
+			if err != nil || res != "handled" {
+				t.Errorf("got %v, %v; want the handler's response", res, err)
+			}
+		})
+	}
+}